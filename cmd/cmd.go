@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"log"
 	"os"
+	"servicetitan-to-dataset/httplog"
+	"servicetitan-to-dataset/redact"
 
 	"github.com/spf13/cobra"
 )
@@ -9,7 +12,11 @@ import (
 var version = ""
 
 func Setup() *cobra.Command {
-	var configPath string
+	var (
+		configPath       string
+		debugHTTP        bool
+		debugHTTPBodyMax int
+	)
 
 	root := &cobra.Command{
 		Use:   "servicetitan-to-dataset",
@@ -30,12 +37,24 @@ func Setup() *cobra.Command {
 		}
 	}
 
-	root.PersistentFlags().StringVar(&configPath, "config", "config.yml", "Path to the config file")
+	root.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if debugHTTP {
+			httplog.Enable(httplog.Options{MaxBodySize: debugHTTPBodyMax})
+		}
+	}
+
+	// Secrets from the config are never written to the logs
+	log.SetOutput(redact.Writer(os.Stderr))
+
+	root.PersistentFlags().StringVar(&configPath, "config", "config.yml", "Path to the config file or a directory of config files")
+	root.PersistentFlags().BoolVar(&debugHTTP, "debug-http", false, "Log every ServiceTitan and Geckoboard request and response, with the secrets redacted")
+	root.PersistentFlags().IntVar(&debugHTTPBodyMax, "debug-http-body-limit", httplog.DefaultMaxBodySize, "Bytes of each body logged by --debug-http, the rest is truncated, -1 doesn't log bodies")
 
 	root.AddCommand(VersionCommand())
 	root.AddCommand(ConfigCommand())
 	root.AddCommand(ReportsCommand())
 	root.AddCommand(PushDataCommand())
+	root.AddCommand(SandboxCommand())
 
 	return root
 }
@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/redact"
 
 	"github.com/spf13/cobra"
 	yaml "gopkg.in/yaml.v3"
@@ -13,8 +16,10 @@ import (
 
 func ConfigCommand() *cobra.Command {
 	var (
-		generate bool
-		validate bool
+		generate       bool
+		validate       bool
+		online         bool
+		printEffective bool
 	)
 
 	cmd := &cobra.Command{
@@ -34,10 +39,24 @@ func ConfigCommand() *cobra.Command {
 					log.Fatal(err)
 				}
 
+				// Printed before validating to help debug how entries were merged
+				if printEffective {
+					if err := printEffectiveEntries(cfg); err != nil {
+						log.Fatal(err)
+					}
+				}
+
 				if err := cfg.Validate(); err != nil {
 					log.Fatal(err)
 				}
 
+				if online {
+					log.Println("Checking geckoboard api keys...")
+					if err := cfg.ValidateOnline(context.Background()); err != nil {
+						log.Fatal(err)
+					}
+				}
+
 				log.Println("Config all valid...")
 			default:
 				err = errors.New("missing --generate or --validate switch")
@@ -51,10 +70,45 @@ func ConfigCommand() *cobra.Command {
 
 	cmd.Flags().BoolVar(&generate, "generate", false, "Generate a template config")
 	cmd.Flags().BoolVar(&validate, "validate", false, "Validate a config")
+	cmd.Flags().BoolVar(&online, "online", false, "Also check the geckoboard api keys against the API when validating")
+	cmd.Flags().BoolVar(&printEffective, "print-effective", false, "Print the entries after merging the defaults and templates when validating")
+
+	cmd.AddCommand(configSchemaCommand())
 
 	return cmd
 }
 
+// printEffectiveEntries prints the entries with the defaults and templates
+// merged, any secret values in them are redacted like they are in logs
+func printEffectiveEntries(cfg *config.Config) error {
+	out := struct {
+		Entries config.Entries `yaml:"entries"`
+	}{cfg.Entries}
+
+	b, err := yaml.Marshal(out)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(os.Stdout, redact.String(string(b)))
+	return err
+}
+
+func configSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the config file for editor autocomplete and validation",
+		Run: func(cmd *cobra.Command, args []string) {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+
+			if err := enc.Encode(config.JSONSchema()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+}
+
 func buildExampleConfig(filename string) error {
 	if _, err := os.Stat(filename); err == nil {
 		return fmt.Errorf("%s already exists... please rename or delete or use --config to specify a different output", filename)
@@ -4,42 +4,120 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
 	"servicetitan-to-dataset/config"
 	"servicetitan-to-dataset/processor"
+	"servicetitan-to-dataset/servicetitan"
+	"servicetitan-to-dataset/tracing"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
 func PushDataCommand() *cobra.Command {
+	var (
+		watchInterval time.Duration
+		traceExporter string
+	)
+
 	cmd := &cobra.Command{
 		Use:   "push",
 		Short: "Fetch data from a serviceTitan and push to Geckoboard",
 		Run: func(cmd *cobra.Command, args []string) {
-			cfg, err := loadAndValidateConfig(cmd.Flag("config").Value.String())
+			configPath := cmd.Flag("config").Value.String()
+			cfg, err := loadAndValidateConfig(configPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			clients, err := newClientPool(cfg)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			// Entries stop on SIGINT or SIGTERM so the spans are exported before exiting
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			shutdownTracing, err := tracing.Setup(ctx, traceExporter, version)
 			if err != nil {
 				log.Fatal(err)
 			}
 
+			holder := newConfigHolder(cfg)
+			limiter := newTenantRateLimiter(reportDataInterval)
+			run := func(ctx context.Context, key string) {
+				runEntry(ctx, holder, key, limiter, clients)
+			}
+
 			if cfg.RefreshTimeSec == 0 {
-				runAllEntries(context.Background(), cfg)
+				runAllEntries(ctx, holder, run)
 				log.Println("Completed pushing all entries")
-				os.Exit(0)
+
+				flushTraces(shutdownTracing)
+				return
+			}
+
+			reloader := newConfigReloader(configPath, holder)
+			go reloader.WatchSignal(ctx)
+			if watchInterval > 0 {
+				go reloader.WatchFiles(ctx, watchInterval)
 			}
 
 			// We use this instead of a ticker because we don't want
 			// tickers to pile up as we need to wait 5mins between every
 			// entry run
-			for {
-				runAllEntries(context.Background(), cfg)
-				time.Sleep(time.Duration(cfg.RefreshTimeSec) * time.Second)
+			for ctx.Err() == nil {
+				runAllEntries(ctx, holder, run)
+
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Duration(holder.Get().RefreshTimeSec) * time.Second):
+				}
 			}
+
+			log.Println("INF: Received a signal to stop, stopped pushing entries")
+			flushTraces(shutdownTracing)
 		},
 	}
 
+	cmd.Flags().StringVar(&traceExporter, "trace-exporter", "", "Export OpenTelemetry traces of each entry with otlp or stdout, the otlp endpoint is set with OTEL_EXPORTER_OTLP_ENDPOINT")
+	cmd.Flags().DurationVar(&watchInterval, "watch-interval", 10*time.Second, "How often to check the config files for changes to reload, 0 disables watching (SIGHUP always reloads)")
+
 	return cmd
 }
 
+// traceFlushTimeout is how long to wait on exporting the spans when exiting
+const traceFlushTimeout = 10 * time.Second
+
+// flushTraces exports the spans which haven't been exported yet, it
+// doesn't use the run context as that's cancelled when exiting on a signal
+func flushTraces(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancel()
+
+	if err := shutdown(ctx); err != nil {
+		log.Println("ERR: Unable to export traces", err)
+	}
+}
+
+// newClientPool returns the pool of ServiceTitan clients shared by every entry
+// run, the token cache directory is only read from the config at startup
+func newClientPool(cfg *config.Config) (*servicetitan.Pool, error) {
+	if cfg.TokenCacheDir == "" {
+		return servicetitan.NewPool(nil), nil
+	}
+
+	cache, err := servicetitan.NewFileTokenCache(cfg.TokenCacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return servicetitan.NewPool(cache), nil
+}
+
 func loadAndValidateConfig(path string) (*config.Config, error) {
 	cfg, err := config.LoadFile(path)
 	if err != nil {
@@ -49,21 +127,180 @@ func loadAndValidateConfig(path string) (*config.Config, error) {
 	return cfg, cfg.Validate()
 }
 
-func runAllEntries(ctx context.Context, cfg *config.Config) {
-	for idx, ent := range cfg.Entries {
-		proc := processor.New(cfg)
+// runAllEntries runs every entry once. The entries are read from the current
+// config before each entry so a reload takes effect within the same run,
+// entries removed are skipped and entries added are run
+func runAllEntries(ctx context.Context, holder *configHolder, run func(ctx context.Context, key string)) {
+	done := map[string]bool{}
 
-		log.Println("Processing entry...", idx)
-		if err := proc.Process(ctx, ent); err != nil {
-			log.Println("ERR: Unexpected error occurred", err)
-		} else {
-			log.Println("INF: Successfully processed and pushed")
+	for ctx.Err() == nil {
+		key, ok := nextEntryKey(holder.Get(), done)
+		if !ok {
+			return
 		}
 
-		// With every report request we make we have to wait another 5 minutes
-		// to request the next report data as rate limits are 2 per 5 minutes.
-		// Its a known issue that serviceTitan are working on resolving at some point
-		log.Println("INF: Waiting 5 minutes for serviceTitan rate limit")
-		time.Sleep(300 * time.Second)
+		done[key] = true
+		run(ctx, key)
+	}
+}
+
+func nextEntryKey(cfg *config.Config, done map[string]bool) (string, bool) {
+	for _, key := range entryKeys(cfg) {
+		if !done[key] {
+			return key, true
+		}
 	}
+
+	return "", false
+}
+
+func runEntry(ctx context.Context, holder *configHolder, key string, limiter *tenantRateLimiter, clients *servicetitan.Pool) {
+	cfg, idx, ent, ok := holder.Entry(key)
+	if !ok {
+		return
+	}
+
+	conns, dest, err := resolveEntry(cfg, ent)
+	if err != nil {
+		log.Println("ERR: Unable to process entry", idx, err)
+		return
+	}
+
+	labels := []string{}
+	for _, conn := range conns {
+		labels = append(labels, conn.Label())
+
+		// Only report data is rate limited, exports have much higher limits
+		if !ent.IsReport() {
+			continue
+		}
+
+		if wait := limiter.Wait(ctx, rateLimitKey(conn)); wait > 0 {
+			log.Printf("INF: [%s] Waited %s for serviceTitan rate limit", conn.Label(), wait.Round(time.Second))
+		}
+	}
+
+	// Stopped while waiting on the rate limit
+	if ctx.Err() != nil {
+		return
+	}
+
+	// The config could have been reloaded while waiting on the rate limit
+	if cfg != holder.Get() {
+		cfg, idx, ent, ok = holder.Entry(key)
+		if !ok {
+			log.Printf("INF: [%s] Entry was removed from the config, skipping", strings.Join(labels, ","))
+			return
+		}
+
+		if conns, dest, err = resolveEntry(cfg, ent); err != nil {
+			log.Println("ERR: Unable to process entry", idx, err)
+			return
+		}
+	}
+
+	tenant := strings.Join(labels, ",")
+	proc, err := processor.NewWithClients(cfg, dest, clients, conns...)
+	if err != nil {
+		log.Printf("ERR: [%s] Unable to process entry %d %v", tenant, idx, err)
+		return
+	}
+
+	// The entry is checked again as it's started, and stopped when a reload removes it while running
+	entryCtx, finish, ok := holder.Start(ctx, key)
+	if !ok {
+		log.Printf("INF: [%s] Entry was removed from the config, skipping", tenant)
+		return
+	}
+
+	log.Printf("[%s] Processing entry... %d", tenant, idx)
+	err = proc.Process(entryCtx, ent)
+	removed := entryCtx.Err() != nil && ctx.Err() == nil
+	finish()
+
+	if ent.IsReport() {
+		for _, conn := range conns {
+			limiter.Done(rateLimitKey(conn))
+		}
+	}
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		log.Printf("INF: [%s] Stopped while running", tenant)
+	case err != nil && removed:
+		log.Printf("INF: [%s] Entry was removed from the config while running, stopped", tenant)
+	case err != nil:
+		log.Printf("ERR: [%s] Unexpected error occurred %v", tenant, err)
+	default:
+		log.Printf("INF: [%s] Successfully processed and pushed", tenant)
+	}
+}
+
+func resolveEntry(cfg *config.Config, ent config.Entry) ([]config.ServiceTitan, config.Geckoboard, error) {
+	conns, err := cfg.EntryConnections(ent)
+	if err != nil {
+		return nil, config.Geckoboard{}, err
+	}
+
+	dest, err := cfg.GeckoboardDestination(ent.Destination)
+	if err != nil {
+		return nil, config.Geckoboard{}, err
+	}
+
+	return conns, dest, nil
+}
+
+// With every report request we make we have to wait another 5 minutes
+// to request the next report data as rate limits are 2 per 5 minutes.
+// Its a known issue that serviceTitan are working on resolving at some point
+const reportDataInterval = 300 * time.Second
+
+// rateLimitKey identifies the tenant for the rate limit, connections with
+// different names for the same tenant and environment share the limit
+func rateLimitKey(conn config.ServiceTitan) string {
+	return conn.TenantID + "|" + conn.BaseAPIURL()
+}
+
+// tenantRateLimiter tracks when each tenant last requested report
+// data, so entries for different tenants don't wait on each other
+type tenantRateLimiter struct {
+	interval time.Duration
+	lastRun  map[string]time.Time
+}
+
+func newTenantRateLimiter(interval time.Duration) *tenantRateLimiter {
+	return &tenantRateLimiter{
+		interval: interval,
+		lastRun:  map[string]time.Time{},
+	}
+}
+
+// Wait blocks until the tenant is allowed to request report data
+// again or the context is done, and returns how long it waited
+func (t *tenantRateLimiter) Wait(ctx context.Context, tenant string) time.Duration {
+	last, ok := t.lastRun[tenant]
+	if !ok {
+		return 0
+	}
+
+	wait := time.Until(last.Add(t.interval))
+	if wait <= 0 {
+		return 0
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+
+	return time.Since(start)
+}
+
+// Done records that the tenant has just requested report data
+func (t *tenantRateLimiter) Done(tenant string) {
+	t.lastRun[tenant] = time.Now()
 }
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"servicetitan-to-dataset/config"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTenantRateLimiter(t *testing.T) {
+	t.Run("doesn't wait for a tenant which hasn't requested data", func(t *testing.T) {
+		limiter := newTenantRateLimiter(time.Hour)
+		assert.Equal(t, limiter.Wait(context.Background(), "ten|https://api.servicetitan.io"), time.Duration(0))
+	})
+
+	t.Run("waits for the interval since the tenant requested data", func(t *testing.T) {
+		limiter := newTenantRateLimiter(50 * time.Millisecond)
+		limiter.Done("ten|https://api.servicetitan.io")
+
+		start := time.Now()
+		wait := limiter.Wait(context.Background(), "ten|https://api.servicetitan.io")
+
+		assert.Assert(t, wait > 0)
+		assert.Assert(t, time.Since(start) >= wait)
+	})
+
+	t.Run("stops waiting when the context is done", func(t *testing.T) {
+		limiter := newTenantRateLimiter(time.Hour)
+		limiter.Done("ten|https://api.servicetitan.io")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.Assert(t, limiter.Wait(ctx, "ten|https://api.servicetitan.io") < time.Minute)
+	})
+
+	t.Run("doesn't wait on other tenants", func(t *testing.T) {
+		limiter := newTenantRateLimiter(time.Hour)
+		limiter.Done("ten|https://api.servicetitan.io")
+
+		assert.Equal(t, limiter.Wait(context.Background(), "other|https://api.servicetitan.io"), time.Duration(0))
+	})
+
+	t.Run("doesn't wait once the interval has passed", func(t *testing.T) {
+		limiter := newTenantRateLimiter(time.Millisecond)
+		limiter.Done("ten|https://api.servicetitan.io")
+		time.Sleep(5 * time.Millisecond)
+
+		assert.Equal(t, limiter.Wait(context.Background(), "ten|https://api.servicetitan.io"), time.Duration(0))
+	})
+}
+
+func TestRateLimitKey(t *testing.T) {
+	t.Run("shares the key between connections to the same tenant", func(t *testing.T) {
+		a := config.ServiceTitan{Name: "franchise-a", TenantID: "ten"}
+		b := config.ServiceTitan{Name: "franchise-b", TenantID: "ten", Environment: config.EnvironmentProduction}
+
+		assert.Equal(t, rateLimitKey(a), rateLimitKey(b))
+	})
+
+	t.Run("returns a different key per environment", func(t *testing.T) {
+		prod := config.ServiceTitan{TenantID: "ten"}
+		integration := config.ServiceTitan{TenantID: "ten", Environment: config.EnvironmentIntegration}
+
+		assert.Assert(t, rateLimitKey(prod) != rateLimitKey(integration))
+	})
+}
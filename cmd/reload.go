@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"servicetitan-to-dataset/config"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var errRefreshTimeRemoved = errors.New("refresh_time can't be removed while pushing continuously, restart push to run once")
+
+// configHolder holds the current config which is swapped atomically when
+// the config is reloaded, entries running when they are removed are cancelled
+type configHolder struct {
+	mu      sync.RWMutex
+	cfg     *config.Config
+	running map[string]context.CancelFunc
+}
+
+func newConfigHolder(cfg *config.Config) *configHolder {
+	return &configHolder{cfg: cfg, running: map[string]context.CancelFunc{}}
+}
+
+func (h *configHolder) Get() *config.Config {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.cfg
+}
+
+// Swap replaces the current config returning the previous config
+func (h *configHolder) Swap(cfg *config.Config) *config.Config {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prev := h.cfg
+	h.cfg = cfg
+
+	for key, cancel := range h.running {
+		if !hasEntry(cfg, key) {
+			cancel()
+		}
+	}
+
+	return prev
+}
+
+// Start marks the entry as running, the context is cancelled when the entry
+// is removed from the config and finish must be called once it has run.
+// It returns false when the entry isn't in the current config
+func (h *configHolder) Start(ctx context.Context, key string) (context.Context, func(), bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !hasEntry(h.cfg, key) {
+		return nil, nil, false
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	h.running[key] = cancel
+
+	finish := func() {
+		h.mu.Lock()
+		delete(h.running, key)
+		h.mu.Unlock()
+
+		cancel()
+	}
+
+	return ctx, finish, true
+}
+
+// Entry returns the entry by its dataset key from the current config
+func (h *configHolder) Entry(key string) (*config.Config, int, config.Entry, bool) {
+	cfg := h.Get()
+
+	for idx, k := range entryKeys(cfg) {
+		if k == key {
+			return cfg, idx, cfg.Entries[idx], true
+		}
+	}
+
+	return cfg, 0, config.Entry{}, false
+}
+
+func hasEntry(cfg *config.Config, key string) bool {
+	for _, k := range entryKeys(cfg) {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// entryKeys returns the key identifying each entry across reloads, which is
+// its dataset key with the occurrence appended when entries in the same file
+// push to the same dataset
+func entryKeys(cfg *config.Config) []string {
+	keys := make([]string, len(cfg.Entries))
+	seen := map[string]int{}
+
+	for idx, ent := range cfg.Entries {
+		key := ent.DatasetKey()
+		seen[key]++
+
+		if seen[key] > 1 {
+			key = fmt.Sprintf("%s#%d", key, seen[key])
+		}
+
+		keys[idx] = key
+	}
+
+	return keys
+}
+
+// configReloader reloads the config on SIGHUP or when any of the config
+// files change, an invalid config is logged and the current config is kept
+type configReloader struct {
+	path   string
+	holder *configHolder
+
+	mu    sync.Mutex
+	files map[string]fileState
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func newConfigReloader(path string, holder *configHolder) *configReloader {
+	r := &configReloader{path: path, holder: holder}
+	r.files = r.snapshotFiles(holder.Get())
+
+	return r
+}
+
+func (r *configReloader) WatchSignal(ctx context.Context) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			r.Reload("received SIGHUP")
+		}
+	}
+}
+
+// WatchFiles polls the config files and the directories they are in, so
+// new files matching an include or in a config directory are noticed
+func (r *configReloader) WatchFiles(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			changed := !sameFiles(r.files, r.snapshotFiles(r.holder.Get()))
+			r.mu.Unlock()
+
+			if changed {
+				r.Reload("config files changed")
+			}
+		}
+	}
+}
+
+func (r *configReloader) Reload(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log.Printf("INF: Reloading config, %s", reason)
+
+	cfg, err := loadAndValidateConfig(r.path)
+
+	// Remember the files even when invalid so we don't retry until they change again
+	if cfg != nil {
+		r.files = r.snapshotFiles(cfg)
+	} else {
+		r.files = r.snapshotFiles(r.holder.Get())
+	}
+
+	if err == nil && cfg.RefreshTimeSec == 0 {
+		err = errRefreshTimeRemoved
+	}
+
+	if err != nil {
+		log.Printf("ERR: Config reload failed, still using the previous config: %v", err)
+		return
+	}
+
+	prev := r.holder.Swap(cfg)
+	logEntryChanges(prev, cfg)
+}
+
+func (r *configReloader) snapshotFiles(cfg *config.Config) map[string]fileState {
+	paths := append([]string{r.path}, cfg.Files()...)
+	for _, p := range cfg.Files() {
+		paths = append(paths, filepath.Dir(p))
+	}
+
+	files := map[string]fileState{}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			files[p] = fileState{}
+			continue
+		}
+
+		files[p] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	return files
+}
+
+func sameFiles(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for path, state := range a {
+		if other, ok := b[path]; !ok || !other.modTime.Equal(state.modTime) || other.size != state.size {
+			return false
+		}
+	}
+
+	return true
+}
+
+func logEntryChanges(prev, next *config.Config) {
+	prevKeys := map[string]bool{}
+	for _, key := range entryKeys(prev) {
+		prevKeys[key] = true
+	}
+
+	var added, kept int
+	for _, key := range entryKeys(next) {
+		if prevKeys[key] {
+			kept++
+			delete(prevKeys, key)
+		} else {
+			added++
+		}
+	}
+
+	log.Printf("INF: Config reloaded, %d entries added, %d removed and %d kept", added, len(prevKeys), kept)
+}
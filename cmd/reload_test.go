@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"servicetitan-to-dataset/config"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const reloadConfig = `
+refresh_time: %d
+servicetitan:
+  app_id: app
+  tenant_id: ten
+  client_id: id
+  client_secret: st-secret-123
+geckoboard:
+  api_key: api123
+entries:
+%s`
+
+// reportEntries returns the yaml of a report entry for each id
+func reportEntries(ids ...string) string {
+	var b strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&b, "  - report: {id: %q, category_id: cat-1}\n    dataset: {required_fields: [Name]}\n", id)
+	}
+
+	return b.String()
+}
+
+func writeReloadConfig(t *testing.T, path string, refreshTime int, entries string) {
+	t.Helper()
+	assert.NilError(t, os.WriteFile(path, []byte(fmt.Sprintf(reloadConfig, refreshTime, entries)), 0600))
+}
+
+// newReloader returns a reloader of a config with the report entries
+func newReloader(t *testing.T, ids ...string) (*configReloader, string) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeReloadConfig(t, path, 60, reportEntries(ids...))
+
+	cfg, err := loadAndValidateConfig(path)
+	assert.NilError(t, err)
+
+	return newConfigReloader(path, newConfigHolder(cfg)), path
+}
+
+func reportKey(id string) string {
+	return config.Entry{Report: config.Report{ID: id, CategoryID: "cat-1"}}.DatasetKey()
+}
+
+func reportConfig(ids ...string) *config.Config {
+	cfg := &config.Config{}
+	for _, id := range ids {
+		cfg.Entries = append(cfg.Entries, config.Entry{Report: config.Report{ID: id, CategoryID: "cat-1"}})
+	}
+
+	return cfg
+}
+
+func TestConfigReloader_Reload(t *testing.T) {
+	t.Run("swaps the config when the new config is valid", func(t *testing.T) {
+		reloader, path := newReloader(t, "1")
+		prev := reloader.holder.Get()
+
+		writeReloadConfig(t, path, 120, reportEntries("1"))
+		reloader.Reload("test")
+
+		cfg := reloader.holder.Get()
+		assert.Assert(t, cfg != prev)
+		assert.Equal(t, cfg.RefreshTimeSec, 120)
+	})
+
+	t.Run("adds and removes entries", func(t *testing.T) {
+		reloader, path := newReloader(t, "1", "2")
+
+		writeReloadConfig(t, path, 60, reportEntries("2", "3"))
+		reloader.Reload("test")
+
+		assert.DeepEqual(t, entryKeys(reloader.holder.Get()), []string{reportKey("2"), reportKey("3")})
+	})
+
+	t.Run("keeps the previous config when the new config is invalid", func(t *testing.T) {
+		reloader, path := newReloader(t, "1")
+		prev := reloader.holder.Get()
+
+		writeReloadConfig(t, path, 60, "  - report: {category_id: cat-1}\n    dataset: {required_fields: [Name]}\n")
+		reloader.Reload("test")
+
+		assert.Assert(t, reloader.holder.Get() == prev)
+	})
+
+	t.Run("keeps the previous config when the new config can't be loaded", func(t *testing.T) {
+		reloader, path := newReloader(t, "1")
+		prev := reloader.holder.Get()
+
+		assert.NilError(t, os.WriteFile(path, []byte("entries: [\n"), 0600))
+		reloader.Reload("test")
+
+		assert.Assert(t, reloader.holder.Get() == prev)
+	})
+
+	t.Run("keeps the previous config when refresh_time is removed", func(t *testing.T) {
+		reloader, path := newReloader(t, "1")
+		prev := reloader.holder.Get()
+
+		writeReloadConfig(t, path, 0, reportEntries("1", "2"))
+		reloader.Reload("test")
+
+		assert.Assert(t, reloader.holder.Get() == prev)
+	})
+}
+
+func TestRunAllEntries(t *testing.T) {
+	t.Run("runs every entry once", func(t *testing.T) {
+		holder := newConfigHolder(reportConfig("1", "2"))
+
+		ran := []string{}
+		runAllEntries(context.Background(), holder, func(ctx context.Context, key string) {
+			ran = append(ran, key)
+		})
+
+		assert.DeepEqual(t, ran, []string{reportKey("1"), reportKey("2")})
+	})
+
+	t.Run("runs entries in the same file pushing to the same dataset", func(t *testing.T) {
+		holder := newConfigHolder(reportConfig("1", "1"))
+
+		ran := []string{}
+		runAllEntries(context.Background(), holder, func(ctx context.Context, key string) {
+			ran = append(ran, key)
+		})
+
+		assert.DeepEqual(t, ran, []string{reportKey("1"), reportKey("1") + "#2"})
+	})
+
+	t.Run("runs entries added and skips entries removed by a reload", func(t *testing.T) {
+		holder := newConfigHolder(reportConfig("1", "2", "3"))
+
+		ran := []string{}
+		runAllEntries(context.Background(), holder, func(ctx context.Context, key string) {
+			ran = append(ran, key)
+
+			if key == reportKey("1") {
+				holder.Swap(reportConfig("1", "3", "4"))
+			}
+		})
+
+		assert.DeepEqual(t, ran, []string{reportKey("1"), reportKey("3"), reportKey("4")})
+	})
+}
+
+func TestNextEntryKey(t *testing.T) {
+	cfg := reportConfig("1", "2")
+
+	t.Run("returns the first entry not done", func(t *testing.T) {
+		key, ok := nextEntryKey(cfg, map[string]bool{reportKey("1"): true})
+		assert.Assert(t, ok)
+		assert.Equal(t, key, reportKey("2"))
+	})
+
+	t.Run("returns false when every entry is done", func(t *testing.T) {
+		_, ok := nextEntryKey(cfg, map[string]bool{reportKey("1"): true, reportKey("2"): true})
+		assert.Assert(t, !ok)
+	})
+}
+
+func TestConfigHolder_Start(t *testing.T) {
+	t.Run("cancels a running entry when a reload removes it", func(t *testing.T) {
+		holder := newConfigHolder(reportConfig("1", "2"))
+
+		ctx, finish, ok := holder.Start(context.Background(), reportKey("1"))
+		assert.Assert(t, ok)
+		defer finish()
+
+		holder.Swap(reportConfig("2"))
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	})
+
+	t.Run("doesn't cancel a running entry kept by a reload", func(t *testing.T) {
+		holder := newConfigHolder(reportConfig("1", "2"))
+
+		ctx, finish, ok := holder.Start(context.Background(), reportKey("1"))
+		assert.Assert(t, ok)
+		defer finish()
+
+		holder.Swap(reportConfig("1"))
+		assert.NilError(t, ctx.Err())
+	})
+
+	t.Run("returns false for an entry not in the config", func(t *testing.T) {
+		holder := newConfigHolder(reportConfig("1"))
+
+		_, _, ok := holder.Start(context.Background(), reportKey("2"))
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("stops tracking the entry once finished", func(t *testing.T) {
+		holder := newConfigHolder(reportConfig("1"))
+
+		_, finish, _ := holder.Start(context.Background(), reportKey("1"))
+		finish()
+
+		assert.Equal(t, len(holder.running), 0)
+	})
+}
@@ -0,0 +1,159 @@
+package report
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/servicetitan"
+	"sync"
+	"time"
+)
+
+const catalogueCacheDir = "servicetitan-to-dataset"
+
+type categoryReportEntry struct {
+	Reports  []servicetitan.Report `json:"reports"`
+	Category servicetitan.Category `json:"category"`
+}
+
+// catalogue is the list of reports per category which is
+// stored on disk to save refetching everything on every run
+type catalogue struct {
+	FetchedAt time.Time             `json:"fetched_at"`
+	Entries   []categoryReportEntry `json:"entries"`
+}
+
+type catalogueCache struct {
+	path string
+	ttl  time.Duration
+}
+
+// newCatalogueCache returns the cache of the tenant, which is kept per
+// api url as the same tenant id has different reports in each environment
+func newCatalogueCache(cfg config.ServiceTitan, ttl time.Duration) (*catalogueCache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(cfg.BaseAPIURL()))
+	name := fmt.Sprintf("reports-%s-%x.json", cfg.TenantID, sum[:4])
+
+	return &catalogueCache{
+		path: filepath.Join(dir, catalogueCacheDir, name),
+		ttl:  ttl,
+	}, nil
+}
+
+// Load returns the cached catalogue, a nil catalogue is returned
+// when the cache doesn't exist or has expired
+func (c *catalogueCache) Load() (*catalogue, error) {
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	cat := &catalogue{}
+	if err := json.Unmarshal(b, cat); err != nil {
+		// A corrupt cache shouldn't stop us listing reports
+		log.Println("WARN: ignoring unreadable reports cache", c.path, err)
+		return nil, nil
+	}
+
+	if time.Since(cat.FetchedAt) > c.ttl {
+		return nil, nil
+	}
+
+	return cat, nil
+}
+
+func (c *catalogueCache) Save(cat *catalogue) error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(cat)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.path, b, 0o600)
+}
+
+// fetchCatalogue fetches the reports for every category with at
+// most concurrency requests in flight. The entries are returned in
+// the same order as the categories from ServiceTitan
+func fetchCatalogue(ctx context.Context, c *servicetitan.Client, concurrency int) (*catalogue, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	log.Println("Fetching categories...")
+	categories, err := servicetitan.Collect(servicetitan.Categories(ctx, c.ReportService, listPageOptions))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+
+		entries = make([]categoryReportEntry, len(categories))
+		sem     = make(chan struct{}, concurrency)
+	)
+
+	for idx, ctg := range categories {
+		wg.Add(1)
+
+		go func(idx int, ctg servicetitan.Category) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Println("Fetching reports for category", ctg.Name, "...")
+			rpt, err := fetchReportsForCategory(ctx, c, ctg)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("fetching reports for category %q: %w", ctg.ID, err)
+					cancel()
+				})
+				return
+			}
+
+			entries[idx] = categoryReportEntry{
+				Reports:  rpt,
+				Category: ctg,
+			}
+		}(idx, ctg)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return &catalogue{
+		FetchedAt: time.Now().UTC(),
+		Entries:   entries,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/servicetitan"
+	"servicetitan-to-dataset/servicetitan/servicetitantest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCatalogueCache(t *testing.T) {
+	newCache := func(t *testing.T, ttl time.Duration) *catalogueCache {
+		return &catalogueCache{path: filepath.Join(t.TempDir(), "reports.json"), ttl: ttl}
+	}
+
+	t.Run("returns the saved catalogue", func(t *testing.T) {
+		cache := newCache(t, time.Hour)
+		cat := &catalogue{
+			FetchedAt: time.Now().UTC().Truncate(time.Second),
+			Entries:   []categoryReportEntry{{Category: servicetitan.Category{ID: "operations"}}},
+		}
+
+		assert.NilError(t, cache.Save(cat))
+
+		got, err := cache.Load()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, cat)
+	})
+
+	t.Run("returns no catalogue once the ttl has passed", func(t *testing.T) {
+		cache := newCache(t, time.Hour)
+		assert.NilError(t, cache.Save(&catalogue{FetchedAt: time.Now().Add(-2 * time.Hour)}))
+
+		got, err := cache.Load()
+		assert.NilError(t, err)
+		assert.Assert(t, got == nil)
+	})
+
+	t.Run("returns no catalogue when the cache doesn't exist", func(t *testing.T) {
+		got, err := newCache(t, time.Hour).Load()
+		assert.NilError(t, err)
+		assert.Assert(t, got == nil)
+	})
+
+	t.Run("returns no catalogue when the cache is unreadable", func(t *testing.T) {
+		cache := newCache(t, time.Hour)
+		assert.NilError(t, os.WriteFile(cache.path, []byte("{"), 0o600))
+
+		got, err := cache.Load()
+		assert.NilError(t, err)
+		assert.Assert(t, got == nil)
+	})
+}
+
+func TestNewCatalogueCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	path := func(cfg config.ServiceTitan) string {
+		cache, err := newCatalogueCache(cfg, time.Hour)
+		assert.NilError(t, err)
+		return cache.path
+	}
+
+	production := config.ServiceTitan{TenantID: "1234"}
+
+	t.Run("keeps a cache for each environment of the tenant", func(t *testing.T) {
+		integration := production
+		integration.Environment = config.EnvironmentIntegration
+
+		assert.Assert(t, path(production) != path(integration))
+	})
+
+	t.Run("keeps a cache for each api url of the tenant", func(t *testing.T) {
+		custom := production
+		custom.APIURL = "https://st.example.com"
+
+		assert.Assert(t, path(production) != path(custom))
+	})
+
+	t.Run("keeps a cache for each tenant", func(t *testing.T) {
+		other := production
+		other.TenantID = "5678"
+
+		assert.Assert(t, path(production) != path(other))
+	})
+}
+
+func TestFetchCatalogue(t *testing.T) {
+	newServer := func(t *testing.T, categories int) *servicetitantest.Server {
+		s := servicetitantest.NewServer(t)
+		for i := 1; i <= categories; i++ {
+			s.AddCategory(fmt.Sprintf("cat-%d", i), fmt.Sprintf("Category %d", i)).
+				AddReport(i, fmt.Sprintf("Report %d", i))
+		}
+
+		return s
+	}
+
+	t.Run("returns the reports of every category in order", func(t *testing.T) {
+		s := newServer(t, 10)
+
+		cat, err := fetchCatalogue(context.Background(), s.Client(t), 4)
+		assert.NilError(t, err)
+		assert.Equal(t, len(cat.Entries), 10)
+
+		for idx, ent := range cat.Entries {
+			assert.Equal(t, ent.Category.ID, fmt.Sprintf("cat-%d", idx+1))
+			assert.DeepEqual(t, ent.Reports, []servicetitan.Report{{ID: idx + 1, Name: fmt.Sprintf("Report %d", idx+1)}})
+		}
+	})
+
+	t.Run("fetches one category at a time without concurrency", func(t *testing.T) {
+		s := newServer(t, 3)
+
+		cat, err := fetchCatalogue(context.Background(), s.Client(t), 0)
+		assert.NilError(t, err)
+		assert.Equal(t, len(cat.Entries), 3)
+	})
+
+	t.Run("returns error with the category whose reports failed", func(t *testing.T) {
+		s := newServer(t, 5)
+		s.FailPath("/reporting/v2/tenant/*/report-category/cat-3/reports", 1, http.StatusBadRequest)
+
+		_, err := fetchCatalogue(context.Background(), s.Client(t), 2)
+		assert.ErrorContains(t, err, `fetching reports for category "cat-3"`)
+	})
+}
@@ -2,24 +2,32 @@ package report
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"regexp"
 	"servicetitan-to-dataset/config"
 	"servicetitan-to-dataset/servicetitan"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
-type categoryReportEntry struct {
-	reports  []servicetitan.Report
-	category servicetitan.Category
+// listPageOptions fetches every page of categories and reports, with
+// larger pages than the default so a tenant takes fewer requests
+var listPageOptions = servicetitan.PageOptions{PageSize: 200}
+
+type listOptions struct {
+	filter      string
+	concurrency int
+	cacheTTL    time.Duration
+	refresh     bool
 }
 
 func ListCommand() *cobra.Command {
-	var reportsFilter string
+	opts := listOptions{}
 
 	cmd := &cobra.Command{
 		Use:   "list",
@@ -30,89 +38,127 @@ func ListCommand() *cobra.Command {
 				log.Fatal(err)
 			}
 
-			if err := fetchAndPrintReports(cfg.ServiceTitan, reportsFilter); err != nil {
+			conn, err := cfg.ServiceTitanConnection(cmd.Flag("connection").Value.String())
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if err := fetchAndPrintReports(conn, opts); err != nil {
 				log.Fatal(err)
 			}
 		},
 	}
 
-	cmd.Flags().StringVar(&reportsFilter, "filter", "", "Filter list of reports containing the specific phrase")
+	cmd.Flags().StringVar(&opts.filter, "filter", "", "Filter reports by a case-insensitive phrase or regex matching the report name, report ID or category name")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 4, "Number of categories to fetch reports for at the same time")
+	cmd.Flags().DurationVar(&opts.cacheTTL, "cache-ttl", 24*time.Hour, "How long the fetched list of reports is cached for, use 0 to disable the cache")
+	cmd.Flags().BoolVar(&opts.refresh, "refresh", false, "Ignore the cached list of reports and fetch them again")
 
 	return cmd
 }
 
-func fetchAndPrintReports(cfg config.ServiceTitan, filterTerm string) error {
-	c, err := servicetitan.New(cfg)
+func fetchAndPrintReports(cfg config.ServiceTitan, opts listOptions) error {
+	filter, err := buildReportFilter(opts.filter)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Fetching categories...")
-	cat, err := c.ReportService.GetCategories(context.Background(), nil)
+	cat, err := loadCatalogue(cfg, opts)
 	if err != nil {
 		return err
 	}
 
-	entries := []categoryReportEntry{}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Report ID", "Category ID", "Category Name", "Report Name"})
+	table.AppendBulk(reportRows(cat, filter))
 
-	for _, ctg := range cat.Items {
-		log.Println("Fetching reports for category", ctg.Name, "...")
-		rpt, err := fetchReportsForCategory(c, ctg)
-		if err != nil {
-			return err
-		}
+	table.SetRowLine(true)
+	table.Render()
 
-		entries = append(entries, categoryReportEntry{
-			reports:  rpt,
-			category: ctg,
-		})
-	}
+	return nil
+}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Report ID", "Category ID", "Category Name", "Report Name"})
+// reportRows returns the table rows of the reports matching the filter
+func reportRows(cat *catalogue, filter *regexp.Regexp) [][]string {
+	rows := [][]string{}
+
+	for _, ent := range cat.Entries {
+		for _, rpt := range ent.Reports {
+			reportID := strconv.Itoa(rpt.ID)
 
-	for _, ent := range entries {
-		for _, rpt := range ent.reports {
-			if filterTerm != "" && !strings.Contains(rpt.Name, filterTerm) {
+			if filter != nil && !filter.MatchString(rpt.Name) &&
+				!filter.MatchString(reportID) && !filter.MatchString(ent.Category.Name) {
 				continue
 			}
 
-			table.Append([]string{
-				strconv.Itoa(rpt.ID),
-				ent.category.ID,
-				ent.category.Name,
+			rows = append(rows, []string{
+				reportID,
+				ent.Category.ID,
+				ent.Category.Name,
 				rpt.Name,
 			})
 		}
 	}
 
-	table.SetRowLine(true)
-	table.Render()
-
-	return nil
+	return rows
 }
 
-func fetchReportsForCategory(c *servicetitan.Client, category servicetitan.Category) ([]servicetitan.Report, error) {
-	options := &servicetitan.PaginationOptions{
-		Page:     1,
-		PageSize: 200,
-	}
-
-	reports := []servicetitan.Report{}
+func loadCatalogue(cfg config.ServiceTitan, opts listOptions) (*catalogue, error) {
+	var cache *catalogueCache
 
-	for {
-		col, err := c.ReportService.GetReports(context.Background(), category, options)
-		if err != nil {
+	if opts.cacheTTL > 0 {
+		var err error
+		if cache, err = newCatalogueCache(cfg, opts.cacheTTL); err != nil {
 			return nil, err
 		}
-		reports = append(reports, col.Items...)
 
-		if !col.HasMore {
-			break
+		if !opts.refresh {
+			cat, err := cache.Load()
+			if err != nil {
+				return nil, err
+			}
+
+			if cat != nil {
+				log.Println("Using cached reports from", cat.FetchedAt.Local().Format(time.RFC1123), "use --refresh to fetch again")
+				return cat, nil
+			}
+		}
+	}
+
+	c, err := servicetitan.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cat, err := fetchCatalogue(context.Background(), c, opts.concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		if err := cache.Save(cat); err != nil {
+			log.Println("WARN: failed to cache reports", err)
 		}
+	}
+
+	return cat, nil
+}
 
-		options.Page = col.Page + 1
+// buildReportFilter compiles the filter term as a case-insensitive
+// regex, a nil regex is returned when there is no filter term
+func buildReportFilter(term string) (*regexp.Regexp, error) {
+	if term == "" {
+		return nil, nil
 	}
 
-	return reports, nil
+	re, err := regexp.Compile("(?i)" + term)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --filter %q: %w", term, err)
+	}
+
+	return re, nil
+}
+
+func fetchReportsForCategory(ctx context.Context, c *servicetitan.Client, category servicetitan.Category) ([]servicetitan.Report, error) {
+	return servicetitan.Collect(servicetitan.Reports(ctx, c.ReportService, category, listPageOptions))
 }
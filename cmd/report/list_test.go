@@ -0,0 +1,134 @@
+package report
+
+import (
+	"servicetitan-to-dataset/servicetitan"
+	"servicetitan-to-dataset/servicetitan/servicetitantest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBuildReportFilter(t *testing.T) {
+	t.Run("returns no filter without a term", func(t *testing.T) {
+		filter, err := buildReportFilter("")
+		assert.NilError(t, err)
+		assert.Assert(t, filter == nil)
+	})
+
+	t.Run("matches the term case-insensitively", func(t *testing.T) {
+		filter, err := buildReportFilter("tech.*perf")
+		assert.NilError(t, err)
+		assert.Assert(t, filter.MatchString("Technician Performance"))
+		assert.Assert(t, !filter.MatchString("Performance by technician"))
+	})
+
+	t.Run("returns error for an invalid regex", func(t *testing.T) {
+		_, err := buildReportFilter("tech(")
+		assert.ErrorContains(t, err, `invalid --filter "tech("`)
+	})
+}
+
+func TestReportRows(t *testing.T) {
+	cat := &catalogue{Entries: []categoryReportEntry{
+		{
+			Category: servicetitan.Category{ID: "operations", Name: "Operations"},
+			Reports:  []servicetitan.Report{{ID: 1001, Name: "Technician performance"}, {ID: 1002, Name: "Job costing"}},
+		},
+		{
+			Category: servicetitan.Category{ID: "marketing", Name: "Marketing"},
+			Reports:  []servicetitan.Report{{ID: 2001, Name: "Leads by campaign"}},
+		},
+	}}
+
+	specs := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{"returns every report without a filter", "", []string{"1001", "1002", "2001"}},
+		{"matches the report name", "^job", []string{"1002"}},
+		{"matches the report id", "^200", []string{"2001"}},
+		{"matches the category name", "operations", []string{"1001", "1002"}},
+		{"returns no rows when nothing matches", "payroll", []string{}},
+	}
+
+	for _, tc := range specs {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := buildReportFilter(tc.filter)
+			assert.NilError(t, err)
+
+			ids := []string{}
+			for _, row := range reportRows(cat, filter) {
+				ids = append(ids, row[0])
+			}
+
+			assert.DeepEqual(t, ids, tc.want)
+		})
+	}
+
+	t.Run("returns the report and category of each row", func(t *testing.T) {
+		rows := reportRows(cat, nil)
+		assert.DeepEqual(t, rows[2], []string{"2001", "marketing", "Marketing", "Leads by campaign"})
+	})
+}
+
+func TestLoadCatalogue(t *testing.T) {
+	newServer := func(t *testing.T) *servicetitantest.Server {
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+		t.Setenv("HOME", t.TempDir())
+
+		s := servicetitantest.NewServer(t)
+		s.AddCategory("operations", "Operations").AddReport(1001, "Technician performance")
+		return s
+	}
+
+	opts := listOptions{concurrency: 2, cacheTTL: time.Hour}
+
+	t.Run("uses the cached reports until they expire", func(t *testing.T) {
+		s := newServer(t)
+
+		cat, err := loadCatalogue(s.Config(), opts)
+		assert.NilError(t, err)
+		assert.Equal(t, len(cat.Entries), 1)
+
+		s.AddCategory("marketing", "Marketing")
+
+		cat, err = loadCatalogue(s.Config(), opts)
+		assert.NilError(t, err)
+		assert.Equal(t, len(cat.Entries), 1)
+		assert.Equal(t, s.TokenRequests(), 1)
+	})
+
+	t.Run("fetches the reports again with refresh", func(t *testing.T) {
+		s := newServer(t)
+
+		_, err := loadCatalogue(s.Config(), opts)
+		assert.NilError(t, err)
+
+		s.AddCategory("marketing", "Marketing")
+
+		refresh := opts
+		refresh.refresh = true
+
+		cat, err := loadCatalogue(s.Config(), refresh)
+		assert.NilError(t, err)
+		assert.Equal(t, len(cat.Entries), 2)
+	})
+
+	t.Run("fetches the reports every time without a cache", func(t *testing.T) {
+		s := newServer(t)
+
+		noCache := opts
+		noCache.cacheTTL = 0
+
+		_, err := loadCatalogue(s.Config(), noCache)
+		assert.NilError(t, err)
+
+		s.AddCategory("marketing", "Marketing")
+
+		cat, err := loadCatalogue(s.Config(), noCache)
+		assert.NilError(t, err)
+		assert.Equal(t, len(cat.Entries), 2)
+	})
+}
@@ -33,7 +33,12 @@ func ParametersCommand() *cobra.Command {
 				log.Fatal(err)
 			}
 
-			if err := fetchAndDisplayParameters(cfg.ServiceTitan, categoryID, reportID); err != nil {
+			conn, err := cfg.ServiceTitanConnection(cmd.Flag("connection").Value.String())
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if err := fetchAndDisplayParameters(conn, categoryID, reportID); err != nil {
 				log.Fatal(err)
 			}
 
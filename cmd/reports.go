@@ -12,6 +12,8 @@ func ReportsCommand() *cobra.Command {
 		Short: "List reports and print specific report parameters required for the config",
 	}
 
+	cmd.PersistentFlags().String("connection", "", "Name of the servicetitan connection to use when there are multiple tenants")
+
 	cmd.AddCommand(report.ListCommand())
 	cmd.AddCommand(report.ParametersCommand())
 
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"servicetitan-to-dataset/sandbox"
+
+	"github.com/spf13/cobra"
+)
+
+func SandboxCommand() *cobra.Command {
+	var (
+		fixturesPath     string
+		recordPath       string
+		serviceTitanAddr string
+		geckoboardAddr   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sandbox",
+		Short: "Run local servers emulating the ServiceTitan and Geckoboard APIs to try a config without real accounts",
+		Run: func(cmd *cobra.Command, args []string) {
+			fixtures, err := sandbox.LoadFixtures(fixturesPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var record io.Writer
+			if recordPath != "" {
+				f, err := os.OpenFile(recordPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				defer f.Close()
+				record = f
+			}
+
+			sb := sandbox.New(fixtures, record)
+
+			stListener, err := net.Listen("tcp", serviceTitanAddr)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			gbListener, err := net.Listen("tcp", geckoboardAddr)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			printSandboxConfig(os.Stdout, fixtures, stListener.Addr().String(), gbListener.Addr().String())
+
+			errs := make(chan error, 2)
+			go func() { errs <- http.Serve(stListener, sb.ServiceTitan()) }()
+			go func() { errs <- http.Serve(gbListener, sb.Geckoboard()) }()
+
+			log.Fatal(<-errs)
+		},
+	}
+
+	cmd.Flags().StringVar(&fixturesPath, "fixtures", "", "YAML file of the reports, rate limit and faults, defaults to the built in fixtures")
+	cmd.Flags().StringVar(&recordPath, "record", "", "File to append every push to the Geckoboard datasets to as json lines")
+	cmd.Flags().StringVar(&serviceTitanAddr, "servicetitan-addr", sandbox.ServiceTitanAddr, "Address of the ServiceTitan sandbox")
+	cmd.Flags().StringVar(&geckoboardAddr, "geckoboard-addr", sandbox.GeckoboardAddr, "Address of the Geckoboard sandbox")
+
+	return cmd
+}
+
+// printSandboxConfig prints the config to push to the sandbox, the
+// credentials and tenant are the ones the fixtures accept
+func printSandboxConfig(w io.Writer, fixtures sandbox.Fixtures, serviceTitanAddr, geckoboardAddr string) {
+	log.Printf("ServiceTitan sandbox listening on http://%s", serviceTitanAddr)
+	log.Printf("Geckoboard sandbox listening on http://%s", geckoboardAddr)
+
+	appID, tenantID := "sandbox-app", "1234"
+	if fixtures.AppID != "" {
+		appID = fixtures.AppID
+	}
+
+	if len(fixtures.TenantIDs) > 0 {
+		tenantID = fixtures.TenantIDs[0]
+	}
+
+	clientID, clientSecret, apiKey := "sandbox-client", "sandbox-secret", "sandbox-api-key"
+	if fixtures.ClientID != "" {
+		clientID, clientSecret = fixtures.ClientID, fixtures.ClientSecret
+	}
+
+	if len(fixtures.GeckoboardAPIKeys) > 0 {
+		apiKey = fixtures.GeckoboardAPIKeys[0]
+	}
+
+	fmt.Fprintf(w, `Use these settings in the config to push to the sandbox:
+
+servicetitan:
+  app_id: %[6]s
+  tenant_id: %[7]q
+  client_id: %[3]s
+  client_secret: %[4]s
+  auth_url: http://%[1]s
+  api_url: http://%[1]s
+geckoboard:
+  api_key: %[5]s
+  url: http://%[2]s
+
+`, serviceTitanAddr, geckoboardAddr, clientID, clientSecret, apiKey, appID, tenantID)
+}
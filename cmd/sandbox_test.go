@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/sandbox"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestPrintSandboxConfig(t *testing.T) {
+	parse := func(t *testing.T, fixtures sandbox.Fixtures) config.Config {
+		var out strings.Builder
+		printSandboxConfig(&out, fixtures, "localhost:4010", "localhost:4011")
+
+		_, settings, _ := strings.Cut(out.String(), "\n\n")
+
+		var cfg config.Config
+		assert.NilError(t, yaml.Unmarshal([]byte(settings), &cfg))
+		return cfg
+	}
+
+	t.Run("prints the default credentials", func(t *testing.T) {
+		cfg := parse(t, sandbox.Fixtures{})
+
+		assert.Equal(t, cfg.ServiceTitan.AppID, "sandbox-app")
+		assert.Equal(t, cfg.ServiceTitan.TenantID, "1234")
+		assert.Equal(t, cfg.ServiceTitan.APIURL, "http://localhost:4010")
+		assert.Equal(t, cfg.Geckoboard.URL, "http://localhost:4011")
+	})
+
+	t.Run("prints the app and tenant the fixtures accept", func(t *testing.T) {
+		cfg := parse(t, sandbox.Fixtures{
+			AppID:             "my-app",
+			TenantIDs:         []string{"5678", "9012"},
+			ClientID:          "my-client",
+			ClientSecret:      "my-secret",
+			GeckoboardAPIKeys: []string{"my-api-key"},
+		})
+
+		assert.Equal(t, cfg.ServiceTitan.AppID, "my-app")
+		assert.Equal(t, cfg.ServiceTitan.TenantID, "5678")
+		assert.Equal(t, cfg.ServiceTitan.ClientID, "my-client")
+		assert.Equal(t, cfg.ServiceTitan.ClientSecret, "my-secret")
+		assert.Equal(t, cfg.Geckoboard.APIKey, "my-api-key")
+	})
+}
@@ -1,66 +1,201 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"regexp"
+	"reflect"
+	"servicetitan-to-dataset/keyword"
 	"time"
-
-	yaml "gopkg.in/yaml.v3"
 )
 
-var interpolateRegex = regexp.MustCompile(`{{\s*([a-zA-Z0-9_]+)\s*}}`)
-
 type Config struct {
-	TimeLocation   string       `yaml:"time_location"`
-	ServiceTitan   ServiceTitan `yaml:"servicetitan"`
-	Geckoboard     Geckoboard   `yaml:"geckoboard"`
-	RefreshTimeSec int          `yaml:"refresh_time"`
-	Entries        Entries      `yaml:"entries"`
-
-	cachedTimeLocation *time.Location
+	TimeLocation            string                  `yaml:"time_location" desc:"IANA time zone used for date keywords and dates, such as Europe/London. Defaults to the local time zone"`
+	ServiceTitan            ServiceTitan            `yaml:"servicetitan,omitempty" desc:"The ServiceTitan tenant used by entries without a connection"`
+	ServiceTitanConnections ServiceTitanConnections `yaml:"servicetitan_connections,omitempty" desc:"Named ServiceTitan tenants which entries refer to by name"`
+	Geckoboard              Geckoboard              `yaml:"geckoboard,omitempty" desc:"The Geckoboard account used by entries without a destination"`
+	GeckoboardDestinations  GeckoboardDestinations  `yaml:"geckoboard_destinations,omitempty" desc:"Named Geckoboard accounts which entries refer to by name"`
+	RefreshTimeSec          int                     `yaml:"refresh_time" desc:"Seconds to wait between pushing all the entries, omit to push once and exit"`
+	TokenCacheDir           string                  `yaml:"token_cache_dir,omitempty" desc:"Directory to cache ServiceTitan tokens in, encrypted with the client secret, so each run reuses a token until it expires"`
+	StateDir                string                  `yaml:"state_dir,omitempty" desc:"Directory to keep the continuation token of each export entry in, defaults to state"`
+	Entries                 Entries                 `yaml:"entries" desc:"The reports to push to Geckoboard datasets"`
+	// Defaults are merged into every entry for the fields the entry
+	// or the template it extends doesn't set
+	Defaults *Entry `yaml:"defaults,omitempty" desc:"Settings used by every entry that doesn't set them"`
+	// Templates are partial entries which entries can extend by name
+	Templates map[string]Entry `yaml:"templates,omitempty" desc:"Named partial entries which entries can extend"`
+	// Variables are named values which report parameters can refer to by name,
+	// their values can use keywords, functions and other variables
+	Variables map[string]string `yaml:"variables,omitempty" desc:"Named values which report parameters can use by name"`
+	Secrets   Secrets           `yaml:"secrets,omitempty" desc:"Settings for the secret providers"`
+	// Include is a list of files or globs relative to the config
+	// file, whose entries are added to the entries in this file
+	Include []string `yaml:"include,omitempty" desc:"Files or globs relative to this file whose entries are added to the config"`
+
+	cachedTimeLocation  *time.Location
+	interpolationErrors Errors
+	templateErrors      Errors
+	secretErrors        Errors
+	files               []string
+	// positions are where each section and field is in the config files
+	positions map[string]position
 }
 
+// LoadFile reads the config from the file along with any included files,
+// the path can also be a directory of config files which are merged
 func LoadFile(path string) (*Config, error) {
-	f, err := os.Open(path)
+	l := newLoader()
+
+	conf, err := l.load(path)
 	if err != nil {
 		return nil, err
 	}
 
-	defer f.Close()
-
-	conf := &Config{}
-	if err := yaml.NewDecoder(f).Decode(conf); err != nil {
-		return nil, fmt.Errorf("%s: %w", "Reading file contents failed", err)
-	}
-
+	conf.files = l.files
+	conf.positions = l.positions
+	conf.templateErrors = conf.applyTemplates()
 	conf.ExtractValuesFromEnv()
+	conf.ResolveSecrets(context.Background())
 	return conf, nil
 }
 
+// ExtractValuesFromEnv replaces {{VAR}} references in every string value
+// of the config with the environment variable. Any required variables
+// which aren't set are returned as errors by Validate
 func (c *Config) ExtractValuesFromEnv() {
-	c.ServiceTitan.replaceInterpolatedValues()
-	c.Geckoboard.replaceInterpolatedValues()
+	in := &interpolator{lookupEnv: os.LookupEnv}
+	walkStrings(reflect.ValueOf(c).Elem(), "", false, func(value, path string, _ bool) string {
+		return in.replace(value, path)
+	})
+
+	c.interpolationErrors = in.errors
 }
 
+// Validate returns the errors from every invalid section of the config,
+// the errors point to the file and line of the section or field when known
 func (c *Config) Validate() error {
+	var errs Errors
+
+	// The interpolation and secret errors are scoped to the field
+	errs = append(errs, c.interpolationErrors...)
+	errs = append(errs, c.secretErrors...)
+	errs = append(errs, c.templateErrors...)
+
 	if err := c.loadAndValidateTimeLocation(); err != nil {
-		return fmt.Errorf("Config time_location error: %v", err)
+		errs = append(errs, Error{
+			scope:    "time_location",
+			messages: []string{err.Error()},
+		})
 	}
 
-	if err := c.ServiceTitan.Validate(); err != nil {
-		return err
+	// The servicetitan block is optional when named connections are used
+	if c.hasDefaultServiceTitan() || len(c.ServiceTitanConnections) == 0 {
+		errs = errs.add(c.ServiceTitan.Validate())
 	}
 
-	if err := c.Geckoboard.Validate(); err != nil {
-		return err
+	errs = errs.add(c.ServiceTitanConnections.Validate())
+
+	// The geckoboard block is optional when named destinations are used
+	if c.hasDefaultGeckoboard() || len(c.GeckoboardDestinations) == 0 {
+		errs = errs.add(c.Geckoboard.Validate())
 	}
 
-	if err := c.Entries.Validate(); err != nil {
-		return err
+	errs = errs.add(c.GeckoboardDestinations.Validate())
+
+	if msgs := keyword.ValidateVariables(c.Variables); len(msgs) > 0 {
+		errs = append(errs, Error{scope: "variables", messages: msgs})
 	}
 
-	return nil
+	errs = errs.add(c.Entries.Validate())
+	errs = errs.add(c.validateParameterValues())
+
+	// Only check the references once the entries themselves are valid
+	if len(errs) == 0 {
+		errs = errs.add(c.validateEntryReferences())
+	}
+
+	for idx := range errs {
+		if pos, ok := c.positions[errs[idx].scope]; ok {
+			errs[idx].pos = &pos
+		}
+	}
+
+	return errs.err()
+}
+
+// validateEntryReferences checks the servicetitan connections
+// and geckoboard destination used by each entry exist
+func (c *Config) validateEntryReferences() error {
+	var errs Errors
+
+	for idx, entry := range c.Entries {
+		var msgs []string
+
+		if _, err := c.EntryConnections(entry); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+
+		if _, err := c.GeckoboardDestination(entry.Destination); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+
+		if len(msgs) > 0 {
+			errs = append(errs, Error{
+				scope:    entry.scope(idx),
+				messages: msgs,
+			})
+		}
+	}
+
+	return errs.err()
+}
+
+// validateParameterValues checks the functions and variables used by
+// each entry's parameters exist, as plain values are also allowed
+// only values using a function can refer to an unknown name
+func (c *Config) validateParameterValues() error {
+	var errs Errors
+
+	for idx, entry := range c.Entries {
+		var msgs []string
+
+		for _, p := range entry.Report.Parameters {
+			value, ok := p.Value.(string)
+			if !ok {
+				continue
+			}
+
+			if err := keyword.ValidateValue(value, c.Variables); err != nil {
+				msgs = append(msgs, fmt.Sprintf("parameter %q %v", p.Name, err))
+			}
+		}
+
+		if len(msgs) > 0 {
+			errs = append(errs, Error{
+				scope:    entry.scope(idx),
+				messages: msgs,
+			})
+		}
+	}
+
+	return errs.err()
+}
+
+// Files returns every config file that was loaded including the included files
+func (c *Config) Files() []string {
+	return c.files
+}
+
+// DefaultStateDir is where the export continuation tokens are kept by default
+const DefaultStateDir = "state"
+
+// ExportStateDir returns the directory to keep the export continuation tokens in
+func (c *Config) ExportStateDir() string {
+	if c.StateDir == "" {
+		return DefaultStateDir
+	}
+
+	return c.StateDir
 }
 
 func (c *Config) TimeLoc() *time.Location {
@@ -80,17 +215,3 @@ func (c *Config) loadAndValidateTimeLocation() error {
 	c.cachedTimeLocation = loc
 	return nil
 }
-
-func convertEnvToValue(value string) string {
-	if value == "" {
-		return ""
-	}
-
-	keys := interpolateRegex.FindStringSubmatch(value)
-
-	if len(keys) != 2 {
-		return value
-	}
-
-	return os.Getenv(keys[1])
-}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"gotest.tools/v3/assert"
 )
@@ -82,6 +85,92 @@ func TestConfig_ExtractValuesFromEnv(t *testing.T) {
 	})
 }
 
+func TestConfig_ExtractValuesFromEnvAnywhere(t *testing.T) {
+	t.Setenv("ENV_TENANT", "acme")
+	t.Setenv("ENV_DATE", "2022-10-01")
+	t.Setenv("ENV_EMPTY", "")
+
+	t.Run("replaces values in entries and urls", func(t *testing.T) {
+		in := Config{
+			ServiceTitan: ServiceTitan{
+				APIURL: "https://{{ENV_TENANT}}.example.com/api",
+			},
+			Entries: Entries{
+				{
+					Report: Report{
+						ID: "{{ENV_REPORT:-123}}",
+						Parameters: []Parameter{
+							{Name: "From", Value: "{{ENV_DATE}}"},
+							{Name: "Tags", Value: []interface{}{"{{ENV_TENANT}}", 5}},
+						},
+					},
+					Dataset: Dataset{
+						Name:           "sales-{{ ENV_TENANT }}-{{ENV_EMPTY:-daily}}",
+						RequiredFields: []string{"{{ENV_FIELD:-Name}}"},
+					},
+				},
+			},
+		}
+
+		in.ExtractValuesFromEnv()
+
+		assert.Equal(t, in.ServiceTitan.APIURL, "https://acme.example.com/api")
+		assert.Equal(t, in.Entries[0].Report.ID, "123")
+		assert.DeepEqual(t, in.Entries[0].Report.Parameters, []Parameter{
+			{Name: "From", Value: "2022-10-01"},
+			{Name: "Tags", Value: []interface{}{"acme", 5}},
+		})
+		assert.Equal(t, in.Entries[0].Dataset.Name, "sales-acme-daily")
+		assert.DeepEqual(t, in.Entries[0].Dataset.RequiredFields, []string{"Name"})
+		assert.Assert(t, in.interpolationErrors == nil)
+	})
+
+	t.Run("returns validation errors for required variables", func(t *testing.T) {
+		in := Config{
+			ServiceTitan: ServiceTitan{
+				ClientSecret: "{{ENV_SECRET:?set the servicetitan client secret}}",
+			},
+			GeckoboardDestinations: GeckoboardDestinations{
+				{Name: "sales", APIKey: "{{ENV_EMPTY:?}}"},
+			},
+		}
+
+		in.ExtractValuesFromEnv()
+
+		want := Errors{
+			{scope: "servicetitan.client_secret", messages: []string{`variable "ENV_SECRET" is required: set the servicetitan client secret`}},
+			{scope: "geckoboard_destinations[1].api_key", messages: []string{`variable "ENV_EMPTY" is required`}},
+		}
+
+		// The other sections are still validated
+		err := in.Validate()
+		assert.ErrorContains(t, err, "Config section \"entries\" errors:\n - at least one entry is required")
+
+		var errs Errors
+		assert.Assert(t, errors.As(err, &errs))
+		assert.DeepEqual(t, errs[:2], want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns validation errors for variables which aren't set", func(t *testing.T) {
+		in := Config{
+			ServiceTitan: ServiceTitan{
+				ClientID:     "{{ENV_EMPTY}}",
+				ClientSecret: "{{ENV_UNSET}}",
+			},
+		}
+
+		in.ExtractValuesFromEnv()
+		assert.Equal(t, in.ServiceTitan.ClientID, "")
+		assert.Equal(t, in.ServiceTitan.ClientSecret, "")
+
+		want := Errors{
+			{scope: "servicetitan.client_secret", messages: []string{`variable "ENV_UNSET" is not set, use {{ENV_UNSET:-}} to default to an empty value`}},
+		}
+
+		assert.DeepEqual(t, in.interpolationErrors, want, cmp.AllowUnexported(Error{}))
+	})
+}
+
 func TestConfig_Validate(t *testing.T) {
 	t.Run("returns errors for servicetitan", func(t *testing.T) {
 		in := Config{
@@ -97,7 +186,7 @@ func TestConfig_Validate(t *testing.T) {
 
 	t.Run("returns error when invalid time location", func(t *testing.T) {
 		in := Config{TimeLocation: "fake"}
-		assert.ErrorContains(t, in.Validate(), "Config time_location error: unknown time zone fake")
+		assert.ErrorContains(t, in.Validate(), "Config section \"time_location\" errors:\n - unknown time zone fake")
 	})
 
 	t.Run("returns errors for geckoboard", func(t *testing.T) {
@@ -162,6 +251,85 @@ func TestConfig_Validate(t *testing.T) {
 		assert.ErrorContains(t, in.Validate(), "Config section \"entries[2]\" errors:\n - at least one dataset required_field is required, please use the report field name as the identifier\n - category_id is required")
 	})
 
+	t.Run("returns error when an entry uses an unknown connection", func(t *testing.T) {
+		in := Config{
+			ServiceTitanConnections: ServiceTitanConnections{
+				{Name: "franchise-a", AppID: "app", TenantID: "ten", ClientID: "id", ClientSecret: "secret"},
+			},
+			Geckoboard: Geckoboard{
+				APIKey: "api123",
+			},
+			Entries: Entries{
+				{
+					Connection: "franchise-b",
+					Dataset: Dataset{
+						RequiredFields: []string{"Name"},
+					},
+					Report: Report{
+						ID:         "rpt-1",
+						CategoryID: "cat-1",
+					},
+				},
+			},
+		}
+
+		assert.ErrorContains(t, in.Validate(), "Config section \"entries[1]\" errors:\n - unknown servicetitan connection \"franchise-b\"")
+	})
+
+	t.Run("returns error when an entry uses an unknown destination", func(t *testing.T) {
+		in := Config{
+			ServiceTitan: ServiceTitan{
+				AppID:        "app",
+				TenantID:     "ten",
+				ClientID:     "id",
+				ClientSecret: "secret",
+			},
+			GeckoboardDestinations: GeckoboardDestinations{
+				{Name: "sales", APIKey: "api123"},
+			},
+			Entries: Entries{
+				{
+					Destination: "ops",
+					Dataset: Dataset{
+						RequiredFields: []string{"Name"},
+					},
+					Report: Report{
+						ID:         "rpt-1",
+						CategoryID: "cat-1",
+					},
+				},
+			},
+		}
+
+		assert.ErrorContains(t, in.Validate(), "Config section \"entries[1]\" errors:\n - unknown geckoboard destination \"ops\"")
+	})
+
+	t.Run("allows named connections without the servicetitan block", func(t *testing.T) {
+		in := Config{
+			ServiceTitanConnections: ServiceTitanConnections{
+				{Name: "franchise-a", AppID: "app", TenantID: "ten", ClientID: "id", ClientSecret: "secret"},
+				{Name: "franchise-b", AppID: "app", TenantID: "ten2", ClientID: "id2", ClientSecret: "secret2"},
+			},
+			Geckoboard: Geckoboard{
+				APIKey: "api123",
+			},
+			Entries: Entries{
+				{
+					Connection: "franchise-b",
+					Dataset: Dataset{
+						RequiredFields: []string{"Name"},
+					},
+					Report: Report{
+						ID:         "rpt-1",
+						CategoryID: "cat-1",
+					},
+				},
+			},
+		}
+
+		assert.NilError(t, in.Validate())
+	})
+
 	t.Run("allows empty time location with valid config", func(t *testing.T) {
 		in := Config{
 			ServiceTitan: ServiceTitan{
@@ -217,3 +385,176 @@ func TestConfig_Validate(t *testing.T) {
 		assert.NilError(t, in.Validate())
 	})
 }
+
+func TestLoadFile_Strict(t *testing.T) {
+	t.Run("returns error for unknown keys with the line", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + `  - report: {id: "2", category_id: cat-2}
+    dataset:
+      requried_fields: [Name]
+      field_override:
+        - {name: Col1, type: Number}
+`,
+		})
+
+		_, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.ErrorContains(t, err, "line 17: field requried_fields not found in type config.Dataset")
+		assert.ErrorContains(t, err, "line 18: field field_override not found in type config.Dataset")
+	})
+
+	t.Run("allows an empty included file", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include: [empty.yml]\n",
+			"empty.yml":  "",
+		})
+
+		cfg, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.NilError(t, err)
+		assert.NilError(t, cfg.Validate())
+	})
+}
+
+func TestConfig_ValidateAllErrors(t *testing.T) {
+	t.Run("returns errors for every invalid section with their position", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": `
+time_location: fake
+servicetitan:
+  app_id: app
+  tenant_id: ten
+  client_secret: st-secret-123
+geckoboard:
+  api_key: api123
+entries:
+  - report: {id: "1"}
+    dataset: {required_fields: [Name]}
+  - report: {id: "2", category_id: cat-2}
+    dataset: {required_fields: [Name]}
+  - report: {category_id: cat-3}
+    dataset: {required_fields: [Name]}
+`,
+		})
+
+		path := filepath.Join(dir, "config.yml")
+		cfg, err := LoadFile(path)
+		assert.NilError(t, err)
+
+		err = cfg.Validate()
+
+		var errs Errors
+		assert.Assert(t, errors.As(err, &errs))
+		assert.Equal(t, len(errs), 4)
+
+		want := []struct {
+			scope        string
+			line, column int
+		}{
+			{"time_location", 2, 1},
+			{"servicetitan", 3, 1},
+			{"entries[1]", 10, 5},
+			{"entries[3]", 14, 5},
+		}
+
+		for idx, w := range want {
+			file, line, column := errs[idx].Position()
+			assert.Equal(t, errs[idx].scope, w.scope)
+			assert.Equal(t, file, path)
+			assert.Equal(t, line, w.line)
+			assert.Equal(t, column, w.column)
+		}
+
+		assert.ErrorContains(t, err, path+":14:5: Config section \"entries[3]\" errors:\n - report id is required")
+	})
+
+	t.Run("points list items at their first key", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + `  - {report: {id: "2"}, dataset: {required_fields: [Name]}}
+`,
+		})
+
+		path := filepath.Join(dir, "config.yml")
+		cfg, err := LoadFile(path)
+		assert.NilError(t, err)
+
+		assert.ErrorContains(t, cfg.Validate(), path+":15:6: Config section \"entries[2]\" errors:\n - category_id is required")
+	})
+
+	t.Run("points the variable and secret errors at their field", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": `
+servicetitan:
+  app_id: app
+  tenant_id: ten
+  client_id: file:missing-client-id
+  client_secret: "{{ST_TEST_UNSET_SECRET:?}}"
+geckoboard:
+  api_key: api123
+templates:
+  sales:
+    report:
+      category_id: "{{ST_TEST_UNSET_CATEGORY:?}}"
+entries:
+  - extends: sales
+    report: {id: "1"}
+    dataset: {required_fields: [Name]}
+`,
+		})
+
+		path := filepath.Join(dir, "config.yml")
+		cfg, err := LoadFile(path)
+		assert.NilError(t, err)
+
+		var errs Errors
+		assert.Assert(t, errors.As(cfg.Validate(), &errs))
+
+		lines := map[string]int{}
+		for _, err := range errs {
+			_, line, _ := err.Position()
+			lines[err.scope] = line
+		}
+
+		assert.Equal(t, lines["servicetitan.client_id"], 5)
+		assert.Equal(t, lines["servicetitan.client_secret"], 6)
+		assert.Equal(t, lines["templates.sales.report.category_id"], 12)
+
+		// The entry's category is from the template so it isn't in the file
+		line, ok := lines["entries[1].report.category_id"]
+		assert.Assert(t, ok)
+		assert.Equal(t, line, 0)
+	})
+}
+
+func TestConfig_ValidateVariables(t *testing.T) {
+	t.Run("returns errors for invalid variables and parameters", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + `  - report:
+      id: "2"
+      category_id: cat-2
+      parameters:
+        - {name: From, value: start_of_week(SEASON)}
+        - {name: To, value: LAST_WEEK}
+    dataset: {required_fields: [Name]}
+variables:
+  LAST_WEEK: start_of_week(NOW-7d)
+  LOOP: start_of_month(LOOP)
+`,
+		})
+
+		cfg, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.NilError(t, err)
+
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, "Config section \"variables\" errors:\n - variable \"LOOP\" refers to itself through LOOP -> LOOP")
+		assert.ErrorContains(t, err, "Config section \"entries[2]\" errors:\n - parameter \"From\" uses unknown name \"SEASON\"")
+	})
+
+	t.Run("returns error when a variable is defined in more than one file", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include: [team.yml]\nvariables:\n  SEASON: 2026-03-01\n",
+			"team.yml":   "variables:\n  SEASON: 2026-04-01\n",
+		})
+
+		_, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.Error(t, err, filepath.Join(dir, "team.yml")+": variable \"SEASON\" is already defined in the main config")
+	})
+}
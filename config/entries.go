@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"golang.org/x/exp/slices"
 )
@@ -10,36 +12,117 @@ import (
 // Other types might be added in the future as required
 var validReportFieldTypes = []string{"Date", "Datetime", "Number", "Boolean", "String", "Percentage"}
 
+// validFileFormats are the formats of file sources
+var validFileFormats = []string{"csv", "json"}
+
+// validDatasetTypes are how the data is pushed to the dataset, replace is the default
+var validDatasetTypes = []string{"replace", "append"}
+
 type Report struct {
-	ID         string      `yaml:"id"`
-	CategoryID string      `yaml:"category_id"`
-	Parameters []Parameter `yaml:"parameters"`
+	ID         string      `yaml:"id" desc:"The ServiceTitan report id"`
+	CategoryID string      `yaml:"category_id" desc:"The ServiceTitan report category id"`
+	Parameters []Parameter `yaml:"parameters" desc:"Values for the report parameters"`
 }
 
 type Dataset struct {
-	Name           string        `yaml:"name"`
-	Type           string        `yaml:"type"`
-	RequiredFields []string      `yaml:"required_fields"`
-	FieldOverrides []ReportField `yaml:"field_overrides"`
+	Name           string        `yaml:"name" desc:"Overrides the dataset name which defaults to the report name"`
+	Type           string        `yaml:"type" desc:"Whether to replace the dataset data or append to it, defaults to replace"`
+	RequiredFields []string      `yaml:"required_fields" desc:"Report field names which are always present and make a row unique"`
+	FieldOverrides []ReportField `yaml:"field_overrides" desc:"Overrides the type of report fields"`
 }
 
 type Entries []Entry
 
 type Entry struct {
-	Report  Report  `yaml:"report"`
-	Dataset Dataset `yaml:"dataset"`
+	// Connection is the name of the servicetitan connection to
+	// fetch the report from, which is optional with a single tenant
+	Connection string `yaml:"connection,omitempty" desc:"Name of the servicetitan connection to fetch the report from"`
+	// Connections fetches the same report from each of the named
+	// servicetitan connections and merges them into one dataset
+	Connections []string `yaml:"connections,omitempty" desc:"Names of the servicetitan connections to fetch the report from and merge into one dataset"`
+	// Destination is the name of the geckoboard destination to push
+	// the dataset to, which is optional with a single account
+	Destination string `yaml:"destination,omitempty" desc:"Name of the geckoboard destination to push the dataset to"`
+	// Extends is the name of the template the entry is based on
+	Extends string `yaml:"extends,omitempty" desc:"Name of the template the entry is based on"`
+	Report  Report `yaml:"report" desc:"The ServiceTitan report to fetch"`
+	// Export pulls a ServiceTitan export feed instead of a report,
+	// only the records changed since the last run are fetched
+	Export *Export `yaml:"export,omitempty" desc:"The ServiceTitan export feed to pull incrementally instead of a report"`
+	// File reads the rows from a local file instead of ServiceTitan,
+	// such as manual targets or a lookup spreadsheet
+	File    *File   `yaml:"file,omitempty" desc:"The local csv or json file to read the rows from instead of a report"`
+	Dataset Dataset `yaml:"dataset" desc:"The Geckoboard dataset settings"`
+
+	source *entrySource
+}
+
+// Export is a ServiceTitan export endpoint such as jpm/jobs, which
+// returns every record changed since the continuation token
+type Export struct {
+	Resource string        `yaml:"resource" desc:"The export as module/name, such as jpm/jobs, accounting/invoices or crm/customers"`
+	Fields   []SourceField `yaml:"fields" desc:"The record fields which become the dataset fields"`
+	// IncludeRecentChanges also returns the records changed in the last few
+	// minutes, which ServiceTitan otherwise holds back until they settle
+	IncludeRecentChanges bool `yaml:"include_recent_changes,omitempty" desc:"Also fetch records changed in the last few minutes"`
+}
+
+// File is a csv file with a header row or a json file with an array of
+// objects, the path is relative to the directory the app is run from
+type File struct {
+	Path   string        `yaml:"path" desc:"Path to the csv or json file"`
+	Format string        `yaml:"format,omitempty" desc:"The file format, defaults to the file extension"`
+	Fields []SourceField `yaml:"fields" desc:"The columns or object fields which become the dataset fields"`
+}
+
+// SourceField picks a field of an export record or file row and declares its
+// type, nested fields of json objects are joined with a dot
+type SourceField struct {
+	Name  string `yaml:"name" desc:"The field or column name, nested fields are joined with a dot such as location.name"`
+	Label string `yaml:"label,omitempty" desc:"The dataset field name, defaults to the field name"`
+	Type  string `yaml:"type" desc:"The dataset field type"`
+}
+
+// IsExport returns whether the entry pulls an export feed instead of a report
+func (e Entry) IsExport() bool {
+	return e.Export != nil
+}
+
+// IsFile returns whether the entry reads a local file instead of a report
+func (e Entry) IsFile() bool {
+	return e.File != nil
+}
+
+// IsReport returns whether the entry fetches ServiceTitan report data,
+// which unlike the other sources is rate limited to a few calls every 5 minutes
+func (e Entry) IsReport() bool {
+	return !e.IsExport() && !e.IsFile()
+}
+
+// FileFormat returns the format of the file, which defaults to the file extension
+func (f File) FileFormat() string {
+	if f.Format != "" {
+		return strings.ToLower(f.Format)
+	}
+
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(f.Path), "."))
+}
+
+// IsRollUp returns whether the entry merges the report from multiple tenants
+func (e Entry) IsRollUp() bool {
+	return len(e.Connections) > 0
 }
 
 // ReportField allows overriding a field type of a report.
 // Such as mapping a Number type to percentage
 type ReportField struct {
-	Name string `yaml:"name"`
-	Type string `yaml:"type"`
+	Name string `yaml:"name" desc:"The report field name"`
+	Type string `yaml:"type" desc:"The dataset field type to use"`
 }
 
 type Parameter struct {
-	Name  string      `yaml:"name"`
-	Value interface{} `yaml:"value"`
+	Name  string      `yaml:"name" desc:"The report parameter name"`
+	Value interface{} `yaml:"value" desc:"The parameter value which can be a date keyword"`
 }
 
 func (e Entries) Validate() error {
@@ -50,19 +133,83 @@ func (e Entries) Validate() error {
 		}
 	}
 
+	var errs Errors
+	datasets := map[string][]int{}
+
 	for idx, entry := range e {
 		msgs := entry.Dataset.validate()
-		msgs = append(msgs, entry.Report.validate()...)
+
+		if entry.sourceCount() > 1 {
+			msgs = append(msgs, "only one of report, export or file can be set")
+		}
+
+		switch {
+		case entry.IsExport():
+			msgs = append(msgs, entry.validateExport()...)
+		case entry.IsFile():
+			msgs = append(msgs, entry.validateFile()...)
+		default:
+			msgs = append(msgs, entry.Report.validate()...)
+		}
+
+		// Entries in the same file have always been allowed to push to the same
+		// dataset, it's only an error when an included file clashes with another file
+		key := entry.DatasetKey()
+		for _, prev := range datasets[key] {
+			if e[prev].sourceFile() != entry.sourceFile() {
+				msgs = append(msgs, fmt.Sprintf("pushes to the same dataset as %s, please set a different dataset name", e[prev].scope(prev)))
+				break
+			}
+		}
+		datasets[key] = append(datasets[key], idx)
 
 		if len(msgs) > 0 {
-			return Error{
-				scope:    fmt.Sprintf("entries[%d]", idx+1),
+			errs = append(errs, Error{
+				scope:    entry.scope(idx),
 				messages: msgs,
-			}
+			})
 		}
 	}
 
-	return nil
+	return errs.err()
+}
+
+// scope identifies the entry in errors, entries from an
+// included file are identified by the file and their position
+func (e Entry) scope(idx int) string {
+	if e.source != nil {
+		return fmt.Sprintf("%s entries[%d]", e.source.file, e.source.index)
+	}
+
+	return fmt.Sprintf("entries[%d]", idx+1)
+}
+
+// sourceFile returns the included file the entry is from, or
+// an empty string for an entry from the main config
+func (e Entry) sourceFile() string {
+	if e.source == nil {
+		return ""
+	}
+
+	return e.source.file
+}
+
+// DatasetKey identifies the dataset the entry pushes to, which without
+// a dataset name is implied from the report and the tenants it's from
+func (e Entry) DatasetKey() string {
+	if e.Dataset.Name != "" {
+		return strings.Join([]string{e.Destination, "name", e.Dataset.Name}, "|")
+	}
+
+	tenants := append([]string{e.Connection}, e.Connections...)
+	switch {
+	case e.IsExport():
+		return strings.Join([]string{e.Destination, "export", strings.Join(tenants, ","), e.Export.Resource}, "|")
+	case e.IsFile():
+		return strings.Join([]string{e.Destination, "file", e.File.Path}, "|")
+	}
+
+	return strings.Join([]string{e.Destination, "report", strings.Join(tenants, ","), e.Report.CategoryID, e.Report.ID}, "|")
 }
 
 func (d Dataset) validate() []string {
@@ -72,6 +219,11 @@ func (d Dataset) validate() []string {
 		msgs = append(msgs, "at least one dataset required_field is required, please use the report field name as the identifier")
 	}
 
+	// The type is compared ignoring case like when the data is pushed
+	if d.Type != "" && !slices.Contains(validDatasetTypes, strings.ToLower(d.Type)) {
+		msgs = append(msgs, fmt.Sprintf("dataset type %q is invalid only %q are valid types", d.Type, validDatasetTypes))
+	}
+
 	for _, f := range d.FieldOverrides {
 		if !slices.Contains(validReportFieldTypes, f.Type) {
 			msg := fmt.Sprintf("field override %q type is invalid only %q are valid types", f.Name, validReportFieldTypes)
@@ -95,3 +247,85 @@ func (r Report) validate() []string {
 
 	return msgs
 }
+
+// sourceCount returns how many of the report, export and file are set
+func (e Entry) sourceCount() int {
+	count := 0
+	for _, set := range []bool{e.Report.isSet(), e.IsExport(), e.IsFile()} {
+		if set {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (r Report) isSet() bool {
+	return r.ID != "" || r.CategoryID != "" || len(r.Parameters) > 0
+}
+
+func (e Entry) validateExport() []string {
+	var msgs []string
+
+	// Each run only pushes the records changed since the last run
+	if strings.ToLower(e.Dataset.Type) != "append" {
+		msgs = append(msgs, "export entries only fetch the changed records, please use dataset type append")
+	}
+
+	if parts := strings.Split(e.Export.Resource, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		msgs = append(msgs, fmt.Sprintf("export resource %q is invalid, please use module/name such as jpm/jobs", e.Export.Resource))
+	}
+
+	// The required fields are what Geckoboard uses to update the row of a changed
+	// record, without them every change to a record is appended as another row
+	unique := 0
+	for _, rf := range e.Dataset.RequiredFields {
+		if slices.IndexFunc(e.Export.Fields, func(f SourceField) bool { return f.Name == rf }) >= 0 {
+			unique++
+		} else {
+			msgs = append(msgs, fmt.Sprintf("dataset required_field %q isn't an export field", rf))
+		}
+	}
+
+	if unique == 0 {
+		msgs = append(msgs, "export entries need dataset required_fields identifying the record such as id, otherwise every change to a record is appended as another row")
+	}
+
+	return append(msgs, validateSourceFields("export", e.Export.Fields)...)
+}
+
+func (e Entry) validateFile() []string {
+	var msgs []string
+
+	if e.IsRollUp() {
+		msgs = append(msgs, "file entries can't use connections as the file isn't fetched from a tenant")
+	}
+
+	if e.File.Path == "" {
+		msgs = append(msgs, "file path is required")
+	} else if format := e.File.FileFormat(); !slices.Contains(validFileFormats, format) {
+		msgs = append(msgs, fmt.Sprintf("file format %q is invalid only %q are valid formats", format, validFileFormats))
+	}
+
+	return append(msgs, validateSourceFields("file", e.File.Fields)...)
+}
+
+func validateSourceFields(kind string, fields []SourceField) []string {
+	var msgs []string
+
+	if len(fields) == 0 {
+		msgs = append(msgs, fmt.Sprintf("at least one %s field is required", kind))
+	}
+
+	for _, f := range fields {
+		if f.Name == "" {
+			msgs = append(msgs, fmt.Sprintf("%s field name is required", kind))
+		}
+
+		if !slices.Contains(validReportFieldTypes, f.Type) {
+			msgs = append(msgs, fmt.Sprintf("%s field %q type is invalid only %q are valid types", kind, f.Name, validReportFieldTypes))
+		}
+	}
+
+	return msgs
+}
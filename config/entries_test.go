@@ -19,40 +19,40 @@ func TestEntries_Validate(t *testing.T) {
 	})
 
 	t.Run("returns all errors from dataset and report", func(t *testing.T) {
-		want := Error{
+		want := Errors{{
 			scope: "entries[1]",
 			messages: []string{
 				"at least one dataset required_field is required, please use the report field name as the identifier",
 				"report id is required",
 				"category_id is required",
 			},
-		}
+		}}
 
 		in := Entries{{}}
 		assert.DeepEqual(t, in.Validate(), want, cmp.AllowUnexported(Error{}))
 	})
 
 	t.Run("returns specific errors report", func(t *testing.T) {
-		want := Error{
+		want := Errors{{
 			scope: "entries[1]",
 			messages: []string{
 				"report id is required",
 				"category_id is required",
 			},
-		}
+		}}
 
 		in := Entries{{Dataset: Dataset{RequiredFields: []string{"Name"}}}}
 		assert.DeepEqual(t, in.Validate(), want, cmp.AllowUnexported(Error{}))
 	})
 
 	t.Run("returns field override type errors", func(t *testing.T) {
-		want := Error{
+		want := Errors{{
 			scope: "entries[1]",
 			messages: []string{
 				`field override "Col2" type is invalid only ["Date" "Datetime" "Number" "Boolean" "String" "Percentage"] are valid types`,
 				`field override "Col3" type is invalid only ["Date" "Datetime" "Number" "Boolean" "String" "Percentage"] are valid types`,
 			},
-		}
+		}}
 
 		in := Entries{{
 			Report: Report{
@@ -80,13 +80,34 @@ func TestEntries_Validate(t *testing.T) {
 		assert.DeepEqual(t, in.Validate(), want, cmp.AllowUnexported(Error{}))
 	})
 
+	t.Run("returns error for an invalid dataset type", func(t *testing.T) {
+		want := Errors{{
+			scope:    "entries[1]",
+			messages: []string{`dataset type "apend" is invalid only ["replace" "append"] are valid types`},
+		}}
+
+		in := Entries{{
+			Report:  Report{ID: "rpt1", CategoryID: "cat1"},
+			Dataset: Dataset{Type: "apend", RequiredFields: []string{"Name"}},
+		}}
+		assert.DeepEqual(t, in.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("accepts the dataset type in any case", func(t *testing.T) {
+		in := Entries{{
+			Report:  Report{ID: "rpt1", CategoryID: "cat1"},
+			Dataset: Dataset{Type: "Append", RequiredFields: []string{"Name"}},
+		}}
+		assert.NilError(t, in.Validate())
+	})
+
 	t.Run("returns error for later entry", func(t *testing.T) {
-		want := Error{
+		want := Errors{{
 			scope: "entries[3]",
 			messages: []string{
 				"at least one dataset required_field is required, please use the report field name as the identifier",
 			},
-		}
+		}}
 
 		in := Entries{
 			{
@@ -152,3 +173,142 @@ func TestEntries_Validate(t *testing.T) {
 		assert.NilError(t, in.Validate())
 	})
 }
+
+func TestEntries_ValidateExport(t *testing.T) {
+	valid := func() Entry {
+		return Entry{
+			Export: &Export{
+				Resource: "jpm/jobs",
+				Fields:   []SourceField{{Name: "id", Type: "Number"}, {Name: "location.name", Type: "String"}},
+			},
+			Dataset: Dataset{Type: "append", RequiredFields: []string{"id"}},
+		}
+	}
+
+	t.Run("returns all errors from the export", func(t *testing.T) {
+		want := Errors{{
+			scope: "entries[1]",
+			messages: []string{
+				"only one of report, export or file can be set",
+				"export entries only fetch the changed records, please use dataset type append",
+				`export resource "jobs" is invalid, please use module/name such as jpm/jobs`,
+				`export field "id" type is invalid only ["Date" "Datetime" "Number" "Boolean" "String" "Percentage"] are valid types`,
+				"export field name is required",
+			},
+		}}
+
+		in := valid()
+		in.Report.ID = "rpt-1"
+		in.Dataset.Type = ""
+		in.Export.Resource = "jobs"
+		in.Export.Fields = []SourceField{{Name: "id", Type: "Integer"}, {Type: "String"}}
+
+		assert.DeepEqual(t, Entries{in}.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns error without required fields identifying the record", func(t *testing.T) {
+		want := Errors{{
+			scope: "entries[1]",
+			messages: []string{
+				"at least one dataset required_field is required, please use the report field name as the identifier",
+				"export entries need dataset required_fields identifying the record such as id, otherwise every change to a record is appended as another row",
+			},
+		}}
+
+		in := valid()
+		in.Dataset.RequiredFields = nil
+
+		assert.DeepEqual(t, Entries{in}.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns error for required fields which aren't export fields", func(t *testing.T) {
+		want := Errors{{
+			scope: "entries[1]",
+			messages: []string{
+				`dataset required_field "ID" isn't an export field`,
+				"export entries need dataset required_fields identifying the record such as id, otherwise every change to a record is appended as another row",
+			},
+		}}
+
+		in := valid()
+		in.Dataset.RequiredFields = []string{"ID"}
+
+		assert.DeepEqual(t, Entries{in}.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns error without export fields", func(t *testing.T) {
+		in := valid()
+		in.Export.Fields = nil
+
+		assert.ErrorContains(t, Entries{in}.Validate(), "at least one export field is required")
+	})
+
+	t.Run("returns no errors", func(t *testing.T) {
+		assert.NilError(t, Entries{valid()}.Validate())
+	})
+}
+
+func TestEntries_ValidateFile(t *testing.T) {
+	valid := func() Entry {
+		return Entry{
+			File:    &File{Path: "targets.csv", Fields: []SourceField{{Name: "Month", Type: "Date"}, {Name: "Target", Type: "Number"}}},
+			Dataset: Dataset{RequiredFields: []string{"Month"}},
+		}
+	}
+
+	t.Run("returns all errors from the file", func(t *testing.T) {
+		want := Errors{{
+			scope: "entries[1]",
+			messages: []string{
+				"only one of report, export or file can be set",
+				"file entries can't use connections as the file isn't fetched from a tenant",
+				`file format "xlsx" is invalid only ["csv" "json"] are valid formats`,
+				"at least one file field is required",
+			},
+		}}
+
+		in := valid()
+		in.File = &File{Path: "targets.xlsx"}
+		in.Report.CategoryID = "cat-1"
+		in.Connections = []string{"franchise-a", "franchise-b"}
+
+		assert.DeepEqual(t, Entries{in}.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns error without a path", func(t *testing.T) {
+		in := valid()
+		in.File.Path = ""
+
+		assert.ErrorContains(t, Entries{in}.Validate(), "file path is required")
+	})
+
+	t.Run("returns no errors with the format set", func(t *testing.T) {
+		in := valid()
+		in.File.Path = "targets"
+		in.File.Format = "JSON"
+
+		assert.NilError(t, Entries{in}.Validate())
+		assert.Equal(t, in.File.FileFormat(), "json")
+	})
+
+	t.Run("returns no errors", func(t *testing.T) {
+		assert.NilError(t, Entries{valid()}.Validate())
+	})
+}
+
+func TestEntry_DatasetKey(t *testing.T) {
+	t.Run("returns the key of the export", func(t *testing.T) {
+		in := Entry{Connection: "franchise-a", Export: &Export{Resource: "jpm/jobs"}}
+		assert.Equal(t, in.DatasetKey(), "|export|franchise-a|jpm/jobs")
+	})
+
+	t.Run("returns the key of the file", func(t *testing.T) {
+		in := Entry{File: &File{Path: "targets.csv"}}
+		assert.Equal(t, in.DatasetKey(), "|file|targets.csv")
+	})
+
+	t.Run("returns the key of the dataset name", func(t *testing.T) {
+		in := Entry{Export: &Export{Resource: "jpm/jobs"}, Dataset: Dataset{Name: "jobs"}}
+		assert.Equal(t, in.DatasetKey(), "|name|jobs")
+	})
+}
@@ -8,12 +8,83 @@ import (
 type Error struct {
 	scope    string
 	messages []string
+	// pos is where the section is in the config file when known
+	pos *position
+}
+
+// position is where a section starts in the config file
+type position struct {
+	file   string
+	line   int
+	column int
 }
 
 func (e Error) Exists() bool {
 	return len(e.messages) > 0
 }
 
+// Position returns the file, line and column of the config section,
+// the line is 0 when the section wasn't loaded from a file
+func (e Error) Position() (file string, line, column int) {
+	if e.pos == nil {
+		return "", 0, 0
+	}
+
+	return e.pos.file, e.pos.line, e.pos.column
+}
+
 func (e Error) Error() string {
-	return fmt.Sprintf("Config section %q errors:\n - %s", e.scope, strings.Join(e.messages, "\n - "))
+	msg := fmt.Sprintf("Config section %q errors:\n - %s", e.scope, strings.Join(e.messages, "\n - "))
+	if e.pos == nil {
+		return msg
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", e.pos.file, e.pos.line, e.pos.column, msg)
+}
+
+// Errors are the errors from every invalid config section
+// so they can all be fixed at once rather than one at a time
+type Errors []Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for idx, err := range e {
+		msgs[idx] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// add appends the err which is either an Error or Errors
+func (e Errors) add(err error) Errors {
+	switch v := err.(type) {
+	case nil:
+		return e
+	case Error:
+		return append(e, v)
+	case Errors:
+		return append(e, v...)
+	default:
+		return append(e, Error{scope: "config", messages: []string{err.Error()}})
+	}
+}
+
+// addField appends the message to the error for the field path, the
+// messages for the same field are grouped together in one error
+func (e Errors) addField(path, msg string) Errors {
+	if n := len(e); n > 0 && e[n-1].scope == path {
+		e[n-1].messages = append(e[n-1].messages, msg)
+		return e
+	}
+
+	return append(e, Error{scope: path, messages: []string{msg}})
+}
+
+// err returns nil when there are no errors to avoid a typed nil error
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
 }
@@ -1,20 +1,114 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const defaultGeckoboardURL = "https://api.geckoboard.com"
+
 type Geckoboard struct {
-	APIKey string `yaml:"api_key"`
+	Name   string `yaml:"name,omitempty" desc:"Name of the destination which entries refer to"`
+	APIKey string `yaml:"api_key" secret:"true" desc:"The Geckoboard API key from the account details page"`
+	// URL overrides the Geckoboard API url such as for a mock server
+	URL string `yaml:"url,omitempty" desc:"Overrides the Geckoboard API url"`
 }
 
+type GeckoboardDestinations []Geckoboard
+
 func (gb *Geckoboard) Validate() error {
-	if gb.APIKey == "" {
+	if msgs := gb.validate(); len(msgs) > 0 {
 		return Error{
 			scope:    "geckoboard",
-			messages: []string{"missing api_key"},
+			messages: msgs,
 		}
 	}
 
 	return nil
 }
 
-func (gb *Geckoboard) replaceInterpolatedValues() {
-	gb.APIKey = convertEnvToValue(gb.APIKey)
+func (gb *Geckoboard) validate() []string {
+	var msgs []string
+
+	if gb.APIKey == "" {
+		msgs = append(msgs, "missing api_key")
+	}
+
+	return append(msgs, validateURL("url", gb.URL)...)
+}
+
+// BaseURL returns the Geckoboard API url unless overridden
+func (gb Geckoboard) BaseURL() string {
+	if gb.URL != "" {
+		return strings.TrimSuffix(gb.URL, "/")
+	}
+
+	return defaultGeckoboardURL
+}
+
+func (gd GeckoboardDestinations) Validate() error {
+	var errs Errors
+	seen := map[string]bool{}
+
+	for idx, dest := range gd {
+		msgs := dest.validate()
+
+		switch {
+		case dest.Name == "":
+			msgs = append([]string{"missing name"}, msgs...)
+		case seen[dest.Name]:
+			msgs = append([]string{fmt.Sprintf("name %q is already used by another destination", dest.Name)}, msgs...)
+		}
+
+		seen[dest.Name] = true
+
+		if len(msgs) > 0 {
+			errs = append(errs, Error{
+				scope:    fmt.Sprintf("geckoboard_destinations[%d]", idx+1),
+				messages: msgs,
+			})
+		}
+	}
+
+	return errs.err()
+}
+
+func (gd GeckoboardDestinations) lookup(name string) (Geckoboard, bool) {
+	for _, dest := range gd {
+		if dest.Name == name {
+			return dest, true
+		}
+	}
+
+	return Geckoboard{}, false
+}
+
+// GeckoboardDestination returns the geckoboard destination by name.
+// An empty name returns the geckoboard block or the only named
+// destination when that is the only one configured
+func (c *Config) GeckoboardDestination(name string) (Geckoboard, error) {
+	if name != "" {
+		dest, ok := c.GeckoboardDestinations.lookup(name)
+		if !ok {
+			return Geckoboard{}, fmt.Errorf("unknown geckoboard destination %q", name)
+		}
+
+		return dest, nil
+	}
+
+	switch {
+	case c.hasDefaultGeckoboard():
+		return c.Geckoboard, nil
+	case len(c.GeckoboardDestinations) == 1:
+		return c.GeckoboardDestinations[0], nil
+	case len(c.GeckoboardDestinations) > 1:
+		return Geckoboard{}, errors.New("destination is required when there are multiple geckoboard_destinations")
+	}
+
+	return c.Geckoboard, nil
+}
+
+func (c *Config) hasDefaultGeckoboard() bool {
+	return c.Geckoboard != Geckoboard{}
 }
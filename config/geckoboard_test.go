@@ -23,3 +23,89 @@ func TestGeckoboard_Validate(t *testing.T) {
 		assert.NilError(t, in.Validate())
 	})
 }
+
+func TestGeckoboardDestinations_Validate(t *testing.T) {
+	t.Run("returns error when name is missing", func(t *testing.T) {
+		want := Errors{{
+			scope:    "geckoboard_destinations[1]",
+			messages: []string{"missing name", "missing api_key"},
+		}}
+
+		in := GeckoboardDestinations{{}}
+		assert.DeepEqual(t, in.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns error when name is duplicated", func(t *testing.T) {
+		want := Errors{{
+			scope:    "geckoboard_destinations[2]",
+			messages: []string{`name "sales" is already used by another destination`},
+		}}
+
+		in := GeckoboardDestinations{{Name: "sales", APIKey: "a"}, {Name: "sales", APIKey: "b"}}
+		assert.DeepEqual(t, in.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns no error when all valid", func(t *testing.T) {
+		in := GeckoboardDestinations{{Name: "sales", APIKey: "a"}, {Name: "ops", APIKey: "b"}}
+		assert.NilError(t, in.Validate())
+	})
+}
+
+func TestConfig_GeckoboardDestination(t *testing.T) {
+	defaultDest := Geckoboard{APIKey: "api_1"}
+	sales := Geckoboard{Name: "sales", APIKey: "api_2"}
+	ops := Geckoboard{Name: "ops", APIKey: "api_3"}
+
+	t.Run("returns the geckoboard block when name is empty", func(t *testing.T) {
+		cfg := Config{Geckoboard: defaultDest, GeckoboardDestinations: GeckoboardDestinations{sales}}
+
+		got, err := cfg.GeckoboardDestination("")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, defaultDest)
+	})
+
+	t.Run("returns the only named destination when name is empty", func(t *testing.T) {
+		cfg := Config{GeckoboardDestinations: GeckoboardDestinations{sales}}
+
+		got, err := cfg.GeckoboardDestination("")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, sales)
+	})
+
+	t.Run("returns the named destination", func(t *testing.T) {
+		cfg := Config{Geckoboard: defaultDest, GeckoboardDestinations: GeckoboardDestinations{sales, ops}}
+
+		got, err := cfg.GeckoboardDestination("ops")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, ops)
+	})
+
+	t.Run("returns error when name is empty with multiple destinations", func(t *testing.T) {
+		cfg := Config{GeckoboardDestinations: GeckoboardDestinations{sales, ops}}
+
+		_, err := cfg.GeckoboardDestination("")
+		assert.Error(t, err, "destination is required when there are multiple geckoboard_destinations")
+	})
+
+	t.Run("returns error when the destination doesn't exist", func(t *testing.T) {
+		cfg := Config{GeckoboardDestinations: GeckoboardDestinations{sales}}
+
+		_, err := cfg.GeckoboardDestination("finance")
+		assert.Error(t, err, `unknown geckoboard destination "finance"`)
+	})
+}
+
+func TestGeckoboard_BaseURL(t *testing.T) {
+	t.Run("returns the default url", func(t *testing.T) {
+		assert.Equal(t, Geckoboard{}.BaseURL(), "https://api.geckoboard.com")
+	})
+
+	t.Run("returns the overridden url", func(t *testing.T) {
+		assert.Equal(t, Geckoboard{URL: "http://localhost:8080/"}.BaseURL(), "http://localhost:8080")
+	})
+
+	t.Run("returns error for an invalid url", func(t *testing.T) {
+		in := Geckoboard{APIKey: "api123", URL: "localhost"}
+		assert.ErrorContains(t, in.Validate(), `url "localhost" is not a valid http or https url`)
+	})
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// entrySource records which included file an entry was loaded
+// from so errors can point to the file that needs fixing
+type entrySource struct {
+	file  string
+	index int
+}
+
+// loader reads the config file or directory of files along
+// with any included files, merging them into a single config
+type loader struct {
+	visited map[string]bool
+	files   []string
+	// setBy records which file set each of the top level settings
+	setBy map[string]string
+	// positions records where each section and field is by its error scope
+	positions map[string]position
+}
+
+func newLoader() *loader {
+	return &loader{
+		visited:   map[string]bool{},
+		setBy:     map[string]string{},
+		positions: map[string]position{},
+	}
+}
+
+// load returns the merged config from the path, a directory
+// loads every .yml and .yaml file within it in name order
+func (l *loader) load(path string) (*Config, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		conf, doc, err := l.loadFile(path, true)
+		if err != nil {
+			return nil, err
+		}
+
+		l.recordPositions(conf, path, doc, listOffsets{})
+		return conf, l.loadIncludes(conf, filepath.Dir(path), conf.Include)
+	}
+
+	conf := &Config{}
+	return conf, l.loadIncludes(conf, path, []string{"*.yml", "*.yaml"})
+}
+
+// loadFile strictly decodes the file so any unknown keys such as typos are
+// rejected, the parsed document is returned to look up section positions
+func (l *loader) loadFile(path string, root bool) (*Config, *yaml.Node, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l.visited[abs] {
+		return nil, nil, fmt.Errorf("%s: is included more than once", path)
+	}
+
+	l.visited[abs] = true
+	l.files = append(l.files, path)
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conf := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(conf); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("Reading file %s contents failed: %w", path, err)
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, nil, fmt.Errorf("Reading file %s contents failed: %w", path, err)
+	}
+
+	if !root {
+		for idx := range conf.Entries {
+			conf.Entries[idx].source = &entrySource{file: path, index: idx + 1}
+		}
+	}
+
+	return conf, doc, nil
+}
+
+// listOffsets are the lengths of the merged lists before the file
+// was merged, which is where the file's list items start
+type listOffsets struct {
+	connections  int
+	destinations int
+	entries      int
+}
+
+// recordPositions records the line and column of the key of each top
+// level setting and list item in the file keyed by their error scope,
+// along with every field within them keyed by their path
+func (l *loader) recordPositions(conf *Config, file string, doc *yaml.Node, offsets listOffsets) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+
+	at := func(n *yaml.Node) position {
+		// A list item points to its first key rather than the - or {
+		if n.Kind == yaml.MappingNode && len(n.Content) > 0 {
+			n = n.Content[0]
+		}
+
+		return position{file: file, line: n.Line, column: n.Column}
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		switch key.Value {
+		case "servicetitan_connections":
+			for idx, item := range value.Content {
+				scope := fmt.Sprintf("servicetitan_connections[%d]", offsets.connections+idx+1)
+				l.positions[scope] = at(item)
+				l.recordFields(scope, item, at)
+			}
+		case "geckoboard_destinations":
+			for idx, item := range value.Content {
+				scope := fmt.Sprintf("geckoboard_destinations[%d]", offsets.destinations+idx+1)
+				l.positions[scope] = at(item)
+				l.recordFields(scope, item, at)
+			}
+		case "entries":
+			l.positions["entries"] = at(key)
+
+			for idx, item := range value.Content {
+				if n := offsets.entries + idx; n < len(conf.Entries) {
+					l.positions[conf.Entries[n].scope(n)] = at(item)
+					l.recordFields(fmt.Sprintf("entries[%d]", n+1), item, at)
+				}
+			}
+		default:
+			l.positions[key.Value] = at(key)
+			l.recordFields(key.Value, value, at)
+		}
+	}
+}
+
+// recordFields records the position of each field within the node keyed by
+// its path, which is the path the interpolation and secret errors are scoped to
+func (l *loader) recordFields(path string, n *yaml.Node, at func(*yaml.Node) position) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			field := joinPath(path, n.Content[i].Value)
+			l.positions[field] = at(n.Content[i])
+			l.recordFields(field, n.Content[i+1], at)
+		}
+	case yaml.SequenceNode:
+		for idx, item := range n.Content {
+			field := fmt.Sprintf("%s[%d]", path, idx+1)
+			if item.Kind == yaml.ScalarNode {
+				l.positions[field] = at(item)
+			}
+
+			l.recordFields(field, item, at)
+		}
+	}
+}
+
+// loadIncludes merges the files matching each pattern relative to the
+// directory into the config, included files can include other files
+func (l *loader) loadIncludes(conf *Config, dir string, patterns []string) error {
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid include %q: %w", pattern, err)
+		}
+
+		// A path without a glob must exist rather than silently being ignored
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return fmt.Errorf("included file %s does not exist", pattern)
+		}
+
+		sort.Strings(matches)
+
+		for _, path := range matches {
+			inc, doc, err := l.loadFile(path, false)
+			if err != nil {
+				return err
+			}
+
+			offsets := listOffsets{
+				connections:  len(conf.ServiceTitanConnections),
+				destinations: len(conf.GeckoboardDestinations),
+				entries:      len(conf.Entries),
+			}
+
+			if err := l.merge(conf, inc, path); err != nil {
+				return err
+			}
+
+			l.recordPositions(conf, path, doc, offsets)
+
+			if err := l.loadIncludes(conf, filepath.Dir(path), inc.Include); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeSettings are the top level settings which can only be set by one file
+var mergeSettings = []struct {
+	key   string
+	isSet func(*Config) bool
+	apply func(dst, src *Config)
+}{
+	{"time_location", func(c *Config) bool { return c.TimeLocation != "" }, func(dst, src *Config) { dst.TimeLocation = src.TimeLocation }},
+	{"servicetitan", (*Config).hasDefaultServiceTitan, func(dst, src *Config) { dst.ServiceTitan = src.ServiceTitan }},
+	{"geckoboard", (*Config).hasDefaultGeckoboard, func(dst, src *Config) { dst.Geckoboard = src.Geckoboard }},
+	{"refresh_time", func(c *Config) bool { return c.RefreshTimeSec != 0 }, func(dst, src *Config) { dst.RefreshTimeSec = src.RefreshTimeSec }},
+	{"token_cache_dir", func(c *Config) bool { return c.TokenCacheDir != "" }, func(dst, src *Config) { dst.TokenCacheDir = src.TokenCacheDir }},
+	{"state_dir", func(c *Config) bool { return c.StateDir != "" }, func(dst, src *Config) { dst.StateDir = src.StateDir }},
+	{"secrets", func(c *Config) bool { return c.Secrets != Secrets{} }, func(dst, src *Config) { dst.Secrets = src.Secrets }},
+	{"defaults", func(c *Config) bool { return c.Defaults != nil }, func(dst, src *Config) { dst.Defaults = src.Defaults }},
+}
+
+// merge appends the lists from the included config, top level
+// settings can only be set by one file to avoid any surprises
+func (l *loader) merge(dst, src *Config, file string) error {
+	for _, s := range mergeSettings {
+		if !s.isSet(src) {
+			continue
+		}
+
+		if s.isSet(dst) {
+			prev, ok := l.setBy[s.key]
+			if !ok {
+				prev = "the main config"
+			}
+
+			return fmt.Errorf("%s: %s is already set in %s", file, s.key, prev)
+		}
+
+		l.setBy[s.key] = file
+		s.apply(dst, src)
+	}
+
+	if err := mergeDefinitions(l, "template", dst.Templates, src.Templates, file, func(m map[string]Entry) { dst.Templates = m }); err != nil {
+		return err
+	}
+
+	if err := mergeDefinitions(l, "variable", dst.Variables, src.Variables, file, func(m map[string]string) { dst.Variables = m }); err != nil {
+		return err
+	}
+
+	dst.ServiceTitanConnections = append(dst.ServiceTitanConnections, src.ServiceTitanConnections...)
+	dst.GeckoboardDestinations = append(dst.GeckoboardDestinations, src.GeckoboardDestinations...)
+	dst.Entries = append(dst.Entries, src.Entries...)
+
+	return nil
+}
+
+// mergeDefinitions adds the named items from the src map to the dst map, set is
+// called with the map when dst is nil. A name can only be defined by one file
+func mergeDefinitions[T any](l *loader, kind string, dst, src map[string]T, file string, set func(map[string]T)) error {
+	if len(src) == 0 {
+		return nil
+	}
+
+	if dst == nil {
+		dst = map[string]T{}
+		set(dst)
+	}
+
+	for name, item := range src {
+		key := kind + "." + name
+
+		if _, ok := dst[name]; ok {
+			prev, ok := l.setBy[key]
+			if !ok {
+				prev = "the main config"
+			}
+
+			return fmt.Errorf("%s: %s %q is already defined in %s", file, kind, name, prev)
+		}
+
+		l.setBy[key] = file
+		dst[name] = item
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		assert.NilError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	return dir
+}
+
+const mainConfig = `
+servicetitan:
+  app_id: app
+  tenant_id: ten
+  client_id: id
+  client_secret: st-secret-123
+geckoboard:
+  api_key: api123
+entries:
+  - report:
+      id: "1"
+      category_id: cat-1
+    dataset:
+      required_fields: [Name]
+`
+
+func TestLoadFile_Include(t *testing.T) {
+	t.Run("appends the entries from included files in order", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include:\n  - teams/*.yml\n  - extra.yaml\n",
+			"teams/b.yml": `
+entries:
+  - report: {id: "3", category_id: cat-3}
+    dataset: {required_fields: [Name]}
+`,
+			"teams/a.yml": `
+geckoboard_destinations:
+  - name: sales
+    api_key: api456
+entries:
+  - report: {id: "2", category_id: cat-2}
+    destination: sales
+    dataset: {required_fields: [Name]}
+`,
+			"extra.yaml": `
+entries:
+  - report: {id: "4", category_id: cat-4}
+    dataset: {required_fields: [Name]}
+`,
+		})
+
+		cfg, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.NilError(t, err)
+
+		ids := []string{}
+		for _, e := range cfg.Entries {
+			ids = append(ids, e.Report.ID)
+		}
+
+		assert.DeepEqual(t, ids, []string{"1", "2", "3", "4"})
+		assert.Equal(t, cfg.GeckoboardDestinations[0].Name, "sales")
+		assert.DeepEqual(t, cfg.Files(), []string{
+			filepath.Join(dir, "config.yml"),
+			filepath.Join(dir, "teams/a.yml"),
+			filepath.Join(dir, "teams/b.yml"),
+			filepath.Join(dir, "extra.yaml"),
+		})
+		assert.NilError(t, cfg.Validate())
+	})
+
+	t.Run("loads every file in a directory", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"00-main.yml": mainConfig,
+			"10-sales.yaml": `
+entries:
+  - report: {id: "2", category_id: cat-2}
+    dataset: {required_fields: [Name]}
+`,
+			"notes.txt": "not a config",
+		})
+
+		cfg, err := LoadFile(dir)
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(cfg.Entries), 2)
+		assert.Equal(t, cfg.ServiceTitan.TenantID, "ten")
+		assert.NilError(t, cfg.Validate())
+	})
+
+	t.Run("returns error naming the file and entry that is invalid", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include: [sales.yml]\n",
+			"sales.yml": `
+entries:
+  - report: {id: "2", category_id: cat-2}
+    dataset: {required_fields: [Name]}
+  - report: {id: "3"}
+    dataset: {required_fields: [Name]}
+`,
+		})
+
+		cfg, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.NilError(t, err)
+
+		file := filepath.Join(dir, "sales.yml")
+		want := file + ":5:5: Config section \"" + file + " entries[2]\" errors:\n - category_id is required"
+		assert.Error(t, cfg.Validate(), want)
+	})
+
+	t.Run("returns error naming both entries pushing to the same dataset", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include: [sales.yml]\n",
+			"sales.yml": `
+entries:
+  - report: {id: "1", category_id: cat-1}
+    dataset: {required_fields: [Name]}
+`,
+		})
+
+		cfg, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.NilError(t, err)
+
+		file := filepath.Join(dir, "sales.yml")
+		want := file + ":3:5: Config section \"" + file + " entries[1]\" errors:\n - pushes to the same dataset as entries[1], please set a different dataset name"
+		assert.Error(t, cfg.Validate(), want)
+	})
+
+	t.Run("allows entries in the same file pushing to the same dataset", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + `  - report: {id: "1", category_id: cat-1}
+    dataset: {required_fields: [Name]}
+include: [sales.yml]
+`,
+			"sales.yml": `
+entries:
+  - report: {id: "2", category_id: cat-2}
+    dataset: {required_fields: [Name]}
+  - report: {id: "2", category_id: cat-2}
+    dataset: {required_fields: [Name]}
+`,
+		})
+
+		cfg, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.NilError(t, err)
+		assert.NilError(t, cfg.Validate())
+	})
+
+	t.Run("returns error when a setting is set by more than one file", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include: [a.yml, b.yml]\n",
+			"a.yml":      "refresh_time: 60\n",
+			"b.yml":      "refresh_time: 120\n",
+		})
+
+		_, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.Error(t, err, filepath.Join(dir, "b.yml")+": refresh_time is already set in "+filepath.Join(dir, "a.yml"))
+	})
+
+	t.Run("returns error when a setting is already in the main config", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include: [a.yml]\n",
+			"a.yml":      "geckoboard:\n  api_key: other\n",
+		})
+
+		_, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.Error(t, err, filepath.Join(dir, "a.yml")+": geckoboard is already set in the main config")
+	})
+
+	t.Run("returns error when an included file doesn't exist", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include: [missing.yml, none/*.yml]\n",
+		})
+
+		_, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.Error(t, err, "included file "+filepath.Join(dir, "missing.yml")+" does not exist")
+	})
+
+	t.Run("returns error when a file is included more than once", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include: [a.yml]\n",
+			"a.yml":      "include: [config.yml]\n",
+		})
+
+		_, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.Error(t, err, filepath.Join(dir, "config.yml")+": is included more than once")
+	})
+
+	t.Run("returns error naming the file that can't be parsed", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": mainConfig + "include: [a.yml]\n",
+			"a.yml":      "entries: [",
+		})
+
+		_, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.ErrorContains(t, err, "Reading file "+filepath.Join(dir, "a.yml")+" contents failed")
+	})
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Matches {{VAR}}, {{VAR:-default}} and {{VAR:?message}}, a variable
+// without a default must be set although it can be set to empty
+var interpolateRegex = regexp.MustCompile(`{{\s*([a-zA-Z0-9_]+)(?::([-?])([^}]*))?\s*}}`)
+
+type interpolator struct {
+	lookupEnv func(string) (string, bool)
+	// errors are scoped to the path of the field with the variable
+	errors Errors
+}
+
+func (i *interpolator) replace(value, path string) string {
+	return interpolateRegex.ReplaceAllStringFunc(value, func(match string) string {
+		parts := interpolateRegex.FindStringSubmatch(match)
+		name, op, arg := parts[1], parts[2], strings.TrimSpace(parts[3])
+
+		val, set := i.lookupEnv(name)
+		if val != "" {
+			return val
+		}
+
+		switch op {
+		case "":
+			// An empty value must be set explicitly rather than a missing
+			// variable silently leaving the setting empty
+			if !set {
+				i.errors = i.errors.addField(path, fmt.Sprintf("variable %q is not set, use {{%s:-}} to default to an empty value", name, name))
+			}
+		case "-":
+			return arg
+		case "?":
+			msg := fmt.Sprintf("variable %q is required", name)
+			if arg != "" {
+				msg += ": " + arg
+			}
+
+			i.errors = i.errors.addField(path, msg)
+		}
+
+		return ""
+	})
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ValidateOnline checks every geckoboard api key is accepted by the
+// Geckoboard API, the config is expected to be valid. Every destination
+// is checked so all the rejected keys are returned at once
+func (c *Config) ValidateOnline(ctx context.Context) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	var errs Errors
+
+	if c.hasDefaultGeckoboard() {
+		if err := checkGeckoboardAPIKey(ctx, client, c.Geckoboard.BaseURL(), c.Geckoboard.APIKey); err != nil {
+			errs = append(errs, Error{
+				scope:    "geckoboard",
+				messages: []string{err.Error()},
+			})
+		}
+	}
+
+	for idx, dest := range c.GeckoboardDestinations {
+		if err := checkGeckoboardAPIKey(ctx, client, dest.BaseURL(), dest.APIKey); err != nil {
+			errs = append(errs, Error{
+				scope:    fmt.Sprintf("geckoboard_destinations[%d]", idx+1),
+				messages: []string{err.Error()},
+			})
+		}
+	}
+
+	for idx := range errs {
+		if pos, ok := c.positions[errs[idx].scope]; ok {
+			errs[idx].pos = &pos
+		}
+	}
+
+	return errs.err()
+}
+
+func checkGeckoboardAPIKey(ctx context.Context, client *http.Client, baseURL, apiKey string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/", nil)
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(apiKey, "")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to reach geckoboard: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("api_key was rejected by geckoboard")
+	default:
+		return fmt.Errorf("unexpected response code %d from geckoboard checking the api_key", resp.StatusCode)
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestConfig_ValidateOnline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _, _ := r.BasicAuth()
+
+		switch key {
+		case "good-key":
+			w.Write([]byte("{}"))
+		case "broken-key":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("returns no error when all keys are accepted", func(t *testing.T) {
+		in := Config{
+			Geckoboard: Geckoboard{APIKey: "good-key", URL: server.URL},
+			GeckoboardDestinations: GeckoboardDestinations{
+				{Name: "sales", APIKey: "good-key", URL: server.URL},
+			},
+		}
+
+		assert.NilError(t, in.ValidateOnline(context.Background()))
+	})
+
+	t.Run("returns error when the geckoboard key is rejected", func(t *testing.T) {
+		want := Errors{{
+			scope:    "geckoboard",
+			messages: []string{"api_key was rejected by geckoboard"},
+		}}
+
+		in := Config{Geckoboard: Geckoboard{APIKey: "bad-key", URL: server.URL}}
+		assert.DeepEqual(t, in.ValidateOnline(context.Background()), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns error for the destination with a rejected key", func(t *testing.T) {
+		want := Errors{{
+			scope:    "geckoboard_destinations[2]",
+			messages: []string{"api_key was rejected by geckoboard"},
+		}}
+
+		in := Config{
+			GeckoboardDestinations: GeckoboardDestinations{
+				{Name: "sales", APIKey: "good-key", URL: server.URL},
+				{Name: "ops", APIKey: "bad-key", URL: server.URL},
+			},
+		}
+		assert.DeepEqual(t, in.ValidateOnline(context.Background()), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns errors for every destination with a rejected key", func(t *testing.T) {
+		want := Errors{
+			{scope: "geckoboard", messages: []string{"api_key was rejected by geckoboard"}},
+			{scope: "geckoboard_destinations[1]", messages: []string{"api_key was rejected by geckoboard"}},
+			{scope: "geckoboard_destinations[3]", messages: []string{"unexpected response code 500 from geckoboard checking the api_key"}},
+		}
+
+		in := Config{
+			Geckoboard: Geckoboard{APIKey: "bad-key", URL: server.URL},
+			GeckoboardDestinations: GeckoboardDestinations{
+				{Name: "sales", APIKey: "other-bad-key", URL: server.URL},
+				{Name: "ops", APIKey: "good-key", URL: server.URL},
+				{Name: "support", APIKey: "broken-key", URL: server.URL},
+			},
+		}
+		assert.DeepEqual(t, in.ValidateOnline(context.Background()), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns error for an unexpected response", func(t *testing.T) {
+		in := Config{Geckoboard: Geckoboard{APIKey: "broken-key", URL: server.URL}}
+		assert.ErrorContains(t, in.ValidateOnline(context.Background()), "unexpected response code 500 from geckoboard checking the api_key")
+	})
+}
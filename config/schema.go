@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"servicetitan-to-dataset/keyword"
+	"strings"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON Schema document describing the config file
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties is false or the schema of the values of a map
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// schemaEnums are the valid values of fields keyed by their struct
+// and field name, using the same values the config is validated with
+var schemaEnums = map[reflect.Type]map[string][]string{
+	reflect.TypeOf(ServiceTitan{}): {"Environment": {EnvironmentProduction, EnvironmentIntegration, EnvironmentSandbox}},
+	reflect.TypeOf(ReportField{}):  {"Type": validReportFieldTypes},
+	reflect.TypeOf(SourceField{}):  {"Type": validReportFieldTypes},
+	reflect.TypeOf(File{}):         {"Format": validFileFormats},
+}
+
+// schemaCaseInsensitiveEnums are the valid values of fields which
+// are compared ignoring case, such as the dataset type Append
+var schemaCaseInsensitiveEnums = map[reflect.Type]map[string][]string{
+	reflect.TypeOf(Dataset{}): {"Type": validDatasetTypes},
+}
+
+const secretDescription = "Can be a secret reference such as file:/path, exec:command or vault:path#key"
+
+// JSONSchema returns the JSON Schema of the config file generated from
+// the config types and the registered keywords and functions
+func JSONSchema() *Schema {
+	g := &schemaGenerator{
+		definitions: map[string]*Schema{},
+	}
+
+	root := g.structSchema(reflect.TypeOf(Config{}))
+	root.Schema = jsonSchemaDraft
+	root.Title = "servicetitan-to-dataset config"
+	root.Definitions = g.definitions
+
+	return root
+}
+
+type schemaGenerator struct {
+	definitions map[string]*Schema
+}
+
+// typeSchema returns the schema of the type, structs are added
+// to the definitions once and referenced everywhere they are used
+func (g *schemaGenerator) typeSchema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// Reserve the name first in case the struct refers to itself
+			g.definitions[t.Name()] = nil
+			g.definitions[t.Name()] = g.structSchema(t)
+		}
+
+		return &Schema{Ref: "#/definitions/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.typeSchema(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.typeSchema(t.Elem())}
+	}
+
+	// Interfaces accept any value
+	return &Schema{}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		key := yamlKey(field)
+		if key == "-" {
+			continue
+		}
+
+		s.Properties[key] = g.fieldSchema(t, field)
+	}
+
+	return s
+}
+
+func (g *schemaGenerator) fieldSchema(parent reflect.Type, field reflect.StructField) *Schema {
+	var fs *Schema
+
+	switch {
+	case parent == reflect.TypeOf(Parameter{}) && field.Name == "Value":
+		fs = g.parameterValueSchema()
+	case schemaEnums[parent][field.Name] != nil:
+		fs = &Schema{Type: "string", Enum: schemaEnums[parent][field.Name]}
+	case schemaCaseInsensitiveEnums[parent][field.Name] != nil:
+		fs = &Schema{Type: "string", Pattern: caseInsensitivePattern(schemaCaseInsensitiveEnums[parent][field.Name])}
+	default:
+		fs = g.typeSchema(field.Type)
+	}
+
+	desc := field.Tag.Get("desc")
+	if field.Tag.Get("secret") == "true" {
+		desc = fmt.Sprintf("%s. %s", desc, secretDescription)
+	}
+
+	// Keywords next to a $ref are ignored so the reference is wrapped
+	if fs.Ref != "" {
+		return &Schema{Description: desc, AllOf: []*Schema{fs}}
+	}
+
+	fs.Description = desc
+	return fs
+}
+
+// caseInsensitivePattern returns a pattern matching any of the values ignoring
+// case, JSON Schema patterns don't support flags so each letter is a class
+func caseInsensitivePattern(values []string) string {
+	alternatives := []string{}
+	for _, v := range values {
+		var b strings.Builder
+		for _, r := range v {
+			if lower, upper := strings.ToLower(string(r)), strings.ToUpper(string(r)); lower != upper {
+				fmt.Fprintf(&b, "[%s%s]", lower, upper)
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+
+		alternatives = append(alternatives, b.String())
+	}
+
+	return fmt.Sprintf("^(%s)$", strings.Join(alternatives, "|"))
+}
+
+// parameterValueSchema documents the keyword grammar as patterns
+// while still allowing any other value a report parameter accepts
+func (g *schemaGenerator) parameterValueSchema() *Schema {
+	s := &Schema{}
+
+	for _, kw := range keyword.Registered() {
+		s.AnyOf = append(s.AnyOf, &Schema{
+			Type:        "string",
+			Pattern:     kw.Pattern,
+			Description: kw.Description,
+		})
+	}
+
+	functions := keyword.FunctionNames()
+	s.AnyOf = append(s.AnyOf, &Schema{
+		Type:        "string",
+		Pattern:     fmt.Sprintf(`^(%s)\(.+\)$`, strings.Join(functions, "|")),
+		Description: fmt.Sprintf("A date function such as %s(NOW-7d), the argument is a keyword, variable or YYYY-MM-DD date", functions[0]),
+	})
+
+	for _, typ := range []string{"string", "number", "boolean", "array"} {
+		s.AnyOf = append(s.AnyOf, &Schema{Type: typ})
+	}
+
+	return s
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"regexp"
+	"servicetitan-to-dataset/keyword"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestJSONSchema(t *testing.T) {
+	t.Run("describes every config key", func(t *testing.T) {
+		s := JSONSchema()
+
+		var check func(path string, s *Schema)
+		check = func(path string, s *Schema) {
+			for key, prop := range s.Properties {
+				assert.Assert(t, prop.Description != "", "%s.%s is missing a desc tag", path, key)
+			}
+		}
+
+		check("config", s)
+		for name, def := range s.Definitions {
+			check(name, def)
+		}
+	})
+
+	t.Run("rejects unknown keys", func(t *testing.T) {
+		s := JSONSchema()
+
+		assert.Equal(t, s.AdditionalProperties, false)
+		for _, def := range s.Definitions {
+			assert.Equal(t, def.AdditionalProperties, false)
+		}
+	})
+
+	t.Run("returns enums from the valid values", func(t *testing.T) {
+		s := JSONSchema()
+
+		datasetType := regexp.MustCompile(s.Definitions["Dataset"].Properties["type"].Pattern)
+		for _, value := range []string{"replace", "append", "Append", "REPLACE"} {
+			assert.Assert(t, datasetType.MatchString(value), value)
+		}
+		for _, value := range []string{"apend", "append data", ""} {
+			assert.Assert(t, !datasetType.MatchString(value), value)
+		}
+
+		assert.DeepEqual(t, s.Definitions["ReportField"].Properties["type"].Enum, validReportFieldTypes)
+		assert.DeepEqual(t, s.Definitions["ServiceTitan"].Properties["environment"].Enum, []string{"production", "integration", "sandbox"})
+	})
+
+	t.Run("documents secret fields and keywords", func(t *testing.T) {
+		s := JSONSchema()
+
+		assert.Equal(t, s.Definitions["Geckoboard"].Properties["api_key"].Description,
+			"The Geckoboard API key from the account details page. "+secretDescription)
+
+		value := s.Definitions["Parameter"].Properties["value"]
+		for idx, kw := range keyword.Registered() {
+			assert.Equal(t, value.AnyOf[idx].Pattern, kw.Pattern)
+			assert.Equal(t, value.AnyOf[idx].Description, kw.Description)
+		}
+
+		functions := value.AnyOf[len(keyword.Registered())]
+		assert.Assert(t, regexp.MustCompile(functions.Pattern).MatchString("start_of_week(NOW-7d)"))
+	})
+
+	t.Run("references structs by definition", func(t *testing.T) {
+		b, err := json.Marshal(JSONSchema())
+		assert.NilError(t, err)
+
+		got := map[string]interface{}{}
+		assert.NilError(t, json.Unmarshal(b, &got))
+
+		props := got["properties"].(map[string]interface{})
+		entries := props["entries"].(map[string]interface{})
+		assert.DeepEqual(t, entries["items"], map[string]interface{}{"$ref": "#/definitions/Entry"})
+
+		templates := props["templates"].(map[string]interface{})
+		assert.DeepEqual(t, templates["additionalProperties"], map[string]interface{}{"$ref": "#/definitions/Entry"})
+	})
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"reflect"
+	"servicetitan-to-dataset/redact"
+	"strings"
+	"time"
+)
+
+const secretTimeout = 30 * time.Second
+
+// SecretProvider resolves the reference of a secret such as the
+// path for file:/run/secrets/client_secret to the secret value
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+type Secrets struct {
+	Vault Vault `yaml:"vault,omitempty" desc:"The HashiCorp Vault server used to resolve vault: secrets"`
+}
+
+// Vault is the HashiCorp Vault server used to resolve vault: secrets
+// the address and token default to the VAULT_ADDR and VAULT_TOKEN envs
+type Vault struct {
+	Address string `yaml:"address,omitempty" desc:"The Vault server address, defaults to VAULT_ADDR"`
+	Token   string `yaml:"token,omitempty" secret:"true" desc:"The Vault token, defaults to VAULT_TOKEN"`
+}
+
+// ResolveSecrets replaces the secret references in fields tagged as secret
+// with the value from the provider, such as file:, exec: or vault:.
+// Every secret value is redacted from logs and errors returned by Validate
+func (c *Config) ResolveSecrets(ctx context.Context) {
+	providers := c.secretProviders()
+	var errs Errors
+
+	resolve := func(value, path string, secret bool) string {
+		if !secret {
+			return value
+		}
+
+		scheme, ref, ok := strings.Cut(value, ":")
+		provider, found := providers[scheme]
+
+		if !ok || !found {
+			redact.Add(value)
+			return value
+		}
+
+		val, err := provider.Resolve(ctx, ref)
+		if err != nil {
+			errs = errs.addField(path, fmt.Sprintf("unable to resolve %s secret: %v", scheme, err))
+			return ""
+		}
+
+		redact.Add(val)
+		return val
+	}
+
+	// The vault token can itself be a file or exec secret
+	walkStrings(reflect.ValueOf(&c.Secrets).Elem(), "secrets", false, resolve)
+	walkStrings(reflect.ValueOf(c).Elem(), "", false, func(value, path string, secret bool) string {
+		// The secrets section is already resolved, resolving the values
+		// again would treat a resolved token such as a:b as a reference
+		if strings.HasPrefix(path, "secrets.") {
+			return value
+		}
+
+		return resolve(value, path, secret)
+	})
+
+	c.secretErrors = errs
+}
+
+func (c *Config) secretProviders() map[string]SecretProvider {
+	return map[string]SecretProvider{
+		"file":  fileSecretProvider{},
+		"exec":  execSecretProvider{},
+		"vault": vaultSecretProvider{vault: &c.Secrets.Vault},
+	}
+}
+
+// fileSecretProvider reads the secret from a file such
+// as a docker or kubernetes secret mounted in the container
+type fileSecretProvider struct{}
+
+func (fileSecretProvider) Resolve(_ context.Context, path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// execSecretProvider runs the command and uses its output as the
+// secret, such as a password manager cli. The command is split on
+// spaces and run directly without a shell
+type execSecretProvider struct{}
+
+func (execSecretProvider) Resolve(ctx context.Context, command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("missing command")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, secretTimeout)
+	defer cancel()
+
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return "", err
+	}
+
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// vaultSecretProvider reads the secret from a Vault KV secrets engine
+// using token auth. The reference is the secret path and key such as
+// secret/data/servicetitan#client_secret for both KV version 1 and 2
+type vaultSecretProvider struct {
+	vault *Vault
+}
+
+func (v vaultSecretProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("reference %q must be in the format path#key", ref)
+	}
+
+	address := v.vault.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+
+	token := v.vault.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+		redact.Add(token)
+	}
+
+	if address == "" || token == "" {
+		return "", errors.New("vault address and token are required, set secrets.vault or VAULT_ADDR and VAULT_TOKEN")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, secretTimeout)
+	defer cancel()
+
+	url := strings.TrimSuffix(address, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("X-Vault-Token", token)
+
+	client := &http.Client{Timeout: secretTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("got response code %d for path %q", resp.StatusCode, path)
+	}
+
+	body := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	data := body.Data
+
+	// KV version 2 nests the secret under another data key
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	val, ok := data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %q not found at path %q", key, path)
+	}
+
+	return val, nil
+}
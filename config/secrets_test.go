@@ -0,0 +1,179 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"servicetitan-to-dataset/redact"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestConfig_ResolveSecrets(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token-123" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/servicetitan":
+			w.Write([]byte(`{"data": {"data": {"client_secret": "vault-secret-kv2"}, "metadata": {"version": 1}}}`))
+		case "/v1/kv/geckoboard":
+			w.Write([]byte(`{"data": {"api_key": "vault-apikey-kv1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "client_id")
+	assert.NilError(t, os.WriteFile(secretFile, []byte("file-client-id\n"), 0o600))
+
+	tokenFile := filepath.Join(dir, "vault_token")
+	assert.NilError(t, os.WriteFile(tokenFile, []byte("vault-token-123"), 0o600))
+
+	t.Run("resolves file, exec and vault secrets", func(t *testing.T) {
+		in := Config{
+			ServiceTitan: ServiceTitan{
+				AppID:        "exec:echo exec-app-id",
+				TenantID:     "file:not-a-secret-field",
+				ClientID:     "file:" + secretFile,
+				ClientSecret: "vault:secret/data/servicetitan#client_secret",
+			},
+			Geckoboard: Geckoboard{
+				APIKey: "vault:kv/geckoboard#api_key",
+			},
+			Secrets: Secrets{
+				Vault: Vault{Address: vault.URL, Token: "file:" + tokenFile},
+			},
+		}
+
+		in.ResolveSecrets(context.Background())
+
+		assert.Assert(t, len(in.secretErrors) == 0, in.secretErrors)
+		assert.DeepEqual(t, in.ServiceTitan, ServiceTitan{
+			AppID:        "exec-app-id",
+			TenantID:     "file:not-a-secret-field",
+			ClientID:     "file-client-id",
+			ClientSecret: "vault-secret-kv2",
+		})
+		assert.Equal(t, in.Geckoboard.APIKey, "vault-apikey-kv1")
+	})
+
+	t.Run("resolves the secrets section once", func(t *testing.T) {
+		execTokenFile := filepath.Join(dir, "exec_token")
+		assert.NilError(t, os.WriteFile(execTokenFile, []byte("exec:echo other-token"), 0o600))
+
+		in := Config{
+			Secrets: Secrets{
+				Vault: Vault{Address: vault.URL, Token: "file:" + execTokenFile},
+			},
+		}
+
+		in.ResolveSecrets(context.Background())
+
+		assert.Assert(t, len(in.secretErrors) == 0, in.secretErrors)
+		assert.Equal(t, in.Secrets.Vault.Token, "exec:echo other-token")
+	})
+
+	t.Run("redacts the vault token from the environment", func(t *testing.T) {
+		t.Setenv("VAULT_ADDR", vault.URL)
+		t.Setenv("VAULT_TOKEN", "env-vault-token-789")
+
+		in := Config{
+			ServiceTitan: ServiceTitan{ClientSecret: "vault:secret/data/servicetitan#client_secret"},
+		}
+
+		in.ResolveSecrets(context.Background())
+
+		// The token is redacted even when the vault rejects it
+		assert.Equal(t, len(in.secretErrors), 1)
+		assert.Equal(t, redact.String("token=env-vault-token-789"), "token=[REDACTED]")
+	})
+
+	t.Run("redacts resolved and literal secrets", func(t *testing.T) {
+		in := Config{
+			ServiceTitan: ServiceTitan{
+				ClientID:     "file:" + secretFile,
+				ClientSecret: "literal-secret-456",
+			},
+		}
+
+		in.ResolveSecrets(context.Background())
+
+		got := redact.String("id=file-client-id secret=literal-secret-456")
+		assert.Equal(t, got, "id=[REDACTED] secret=[REDACTED]")
+	})
+
+	t.Run("returns validation errors for secrets that fail to resolve", func(t *testing.T) {
+		in := Config{
+			ServiceTitan: ServiceTitan{
+				ClientID:     "file:" + filepath.Join(dir, "missing"),
+				ClientSecret: "vault:secret/data/servicetitan",
+			},
+			GeckoboardDestinations: GeckoboardDestinations{
+				{Name: "sales", APIKey: "vault:secret/data/missing#api_key"},
+			},
+			Secrets: Secrets{
+				Vault: Vault{Address: vault.URL, Token: "vault-token-123"},
+			},
+		}
+
+		in.ResolveSecrets(context.Background())
+
+		want := Errors{
+			{scope: "servicetitan.client_id", messages: []string{"unable to resolve file secret: open " + filepath.Join(dir, "missing") + ": no such file or directory"}},
+			{scope: "servicetitan.client_secret", messages: []string{`unable to resolve vault secret: reference "secret/data/servicetitan" must be in the format path#key`}},
+			{scope: "geckoboard_destinations[1].api_key", messages: []string{`unable to resolve vault secret: got response code 404 for path "secret/data/missing"`}},
+		}
+
+		// The other sections are still validated
+		err := in.Validate()
+		assert.ErrorContains(t, err, "Config section \"entries\" errors:\n - at least one entry is required")
+
+		var errs Errors
+		assert.Assert(t, errors.As(err, &errs))
+		assert.DeepEqual(t, errs[:3], want, cmp.AllowUnexported(Error{}))
+	})
+}
+
+func TestExecSecretProvider_Resolve(t *testing.T) {
+	t.Run("returns error with the command output when it fails", func(t *testing.T) {
+		_, err := execSecretProvider{}.Resolve(context.Background(), "ls /does/not/exist")
+		assert.ErrorContains(t, err, "exit status")
+		assert.ErrorContains(t, err, "No such file or directory")
+	})
+
+	t.Run("returns error when the command is missing", func(t *testing.T) {
+		_, err := execSecretProvider{}.Resolve(context.Background(), " ")
+		assert.Error(t, err, "missing command")
+	})
+}
+
+func TestVaultSecretProvider_Resolve(t *testing.T) {
+	t.Run("returns error without an address or token", func(t *testing.T) {
+		t.Setenv("VAULT_ADDR", "")
+		t.Setenv("VAULT_TOKEN", "")
+
+		_, err := vaultSecretProvider{vault: &Vault{}}.Resolve(context.Background(), "secret/data/st#key")
+		assert.Error(t, err, "vault address and token are required, set secrets.vault or VAULT_ADDR and VAULT_TOKEN")
+	})
+
+	t.Run("returns error when the key doesn't exist", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data": {"data": {"other": "value"}}}`))
+		}))
+		defer server.Close()
+
+		provider := vaultSecretProvider{vault: &Vault{Address: server.URL, Token: "tok"}}
+		_, err := provider.Resolve(context.Background(), "secret/data/st#key")
+		assert.Error(t, err, `key "key" not found at path "secret/data/st"`)
+	})
+}
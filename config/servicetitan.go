@@ -1,13 +1,73 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	EnvironmentProduction  = "production"
+	EnvironmentIntegration = "integration"
+	// EnvironmentSandbox is the sandbox command listening on its default address
+	EnvironmentSandbox = "sandbox"
+)
+
+var serviceTitanEnvironments = map[string]struct{ authURL, apiURL string }{
+	EnvironmentProduction: {
+		authURL: "https://auth.servicetitan.io",
+		apiURL:  "https://api.servicetitan.io",
+	},
+	EnvironmentIntegration: {
+		authURL: "https://auth-integration.servicetitan.io",
+		apiURL:  "https://api-integration.servicetitan.io",
+	},
+	EnvironmentSandbox: {
+		authURL: "http://localhost:8081",
+		apiURL:  "http://localhost:8081",
+	},
+}
+
 type ServiceTitan struct {
-	AppID        string `yaml:"app_id"`
-	TenantID     string `yaml:"tenant_id"`
-	ClientID     string `yaml:"client_id"`
-	ClientSecret string `yaml:"client_secret"`
+	Name         string `yaml:"name,omitempty" desc:"Name of the connection which entries refer to"`
+	AppID        string `yaml:"app_id" secret:"true" desc:"The application key from the ServiceTitan developer portal"`
+	TenantID     string `yaml:"tenant_id" desc:"The ServiceTitan tenant id"`
+	ClientID     string `yaml:"client_id" secret:"true" desc:"The client id of the app in the ServiceTitan integrations settings"`
+	ClientSecret string `yaml:"client_secret" secret:"true" desc:"The client secret of the app in the ServiceTitan integrations settings"`
+
+	// Environment is either production (the default), integration or sandbox
+	// the auth and api urls override the environment urls when set
+	Environment string `yaml:"environment,omitempty" desc:"The ServiceTitan environment, defaults to production"`
+	AuthURL     string `yaml:"auth_url,omitempty" desc:"Overrides the auth url of the environment"`
+	APIURL      string `yaml:"api_url,omitempty" desc:"Overrides the api url of the environment"`
+	ProxyURL    string `yaml:"proxy_url,omitempty" desc:"HTTP proxy to send ServiceTitan requests through"`
+	CACertFile  string `yaml:"ca_cert_file,omitempty" desc:"PEM file of CA certificates to trust for ServiceTitan requests"`
 }
 
+type ServiceTitanConnections []ServiceTitan
+
 func (st *ServiceTitan) Validate() error {
+	if msgs := st.validate(); len(msgs) > 0 {
+		return Error{
+			scope:    "servicetitan",
+			messages: msgs,
+		}
+	}
+
+	return nil
+}
+
+// Label returns a name to identify the tenant in logs, falling
+// back to the tenant id when the connection isn't named
+func (st ServiceTitan) Label() string {
+	if st.Name != "" {
+		return st.Name
+	}
+
+	return st.TenantID
+}
+
+func (st *ServiceTitan) validate() []string {
 	var msgs []string
 
 	if st.AppID == "" {
@@ -26,19 +86,147 @@ func (st *ServiceTitan) Validate() error {
 		msgs = append(msgs, "missing client_secret")
 	}
 
-	if len(msgs) > 0 {
-		return Error{
-			scope:    "servicetitan",
-			messages: msgs,
+	if _, ok := serviceTitanEnvironments[st.Environment]; !ok && st.Environment != "" {
+		msgs = append(msgs, fmt.Sprintf("environment %q is invalid only %q, %q and %q are valid", st.Environment, EnvironmentProduction, EnvironmentIntegration, EnvironmentSandbox))
+	}
+
+	msgs = append(msgs, validateURL("auth_url", st.AuthURL)...)
+	msgs = append(msgs, validateURL("api_url", st.APIURL)...)
+	msgs = append(msgs, validateURL("proxy_url", st.ProxyURL)...)
+
+	if st.CACertFile != "" {
+		if _, err := LoadCACertPool(st.CACertFile); err != nil {
+			msgs = append(msgs, fmt.Sprintf("ca_cert_file %v", err))
 		}
 	}
 
-	return nil
+	return msgs
+}
+
+// BaseAuthURL returns the auth url for the environment unless overridden
+func (st ServiceTitan) BaseAuthURL() string {
+	if st.AuthURL != "" {
+		return strings.TrimSuffix(st.AuthURL, "/")
+	}
+
+	return st.environmentURLs().authURL
+}
+
+// BaseAPIURL returns the api url for the environment unless overridden
+func (st ServiceTitan) BaseAPIURL() string {
+	if st.APIURL != "" {
+		return strings.TrimSuffix(st.APIURL, "/")
+	}
+
+	return st.environmentURLs().apiURL
+}
+
+func (st ServiceTitan) environmentURLs() struct{ authURL, apiURL string } {
+	if urls, ok := serviceTitanEnvironments[st.Environment]; ok {
+		return urls
+	}
+
+	return serviceTitanEnvironments[EnvironmentProduction]
+}
+
+func (sc ServiceTitanConnections) Validate() error {
+	var errs Errors
+	seen := map[string]bool{}
+
+	for idx, conn := range sc {
+		msgs := conn.validate()
+
+		switch {
+		case conn.Name == "":
+			msgs = append([]string{"missing name"}, msgs...)
+		case seen[conn.Name]:
+			msgs = append([]string{fmt.Sprintf("name %q is already used by another connection", conn.Name)}, msgs...)
+		}
+
+		seen[conn.Name] = true
+
+		if len(msgs) > 0 {
+			errs = append(errs, Error{
+				scope:    fmt.Sprintf("servicetitan_connections[%d]", idx+1),
+				messages: msgs,
+			})
+		}
+	}
+
+	return errs.err()
+}
+
+func (sc ServiceTitanConnections) lookup(name string) (ServiceTitan, bool) {
+	for _, conn := range sc {
+		if conn.Name == name {
+			return conn, true
+		}
+	}
+
+	return ServiceTitan{}, false
+}
+
+// ServiceTitanConnection returns the servicetitan connection by name.
+// An empty name returns the servicetitan block or the only named
+// connection when that is the only one configured
+func (c *Config) ServiceTitanConnection(name string) (ServiceTitan, error) {
+	if name != "" {
+		conn, ok := c.ServiceTitanConnections.lookup(name)
+		if !ok {
+			return ServiceTitan{}, fmt.Errorf("unknown servicetitan connection %q", name)
+		}
+
+		return conn, nil
+	}
+
+	switch {
+	case c.hasDefaultServiceTitan():
+		return c.ServiceTitan, nil
+	case len(c.ServiceTitanConnections) == 1:
+		return c.ServiceTitanConnections[0], nil
+	case len(c.ServiceTitanConnections) > 1:
+		return ServiceTitan{}, errors.New("connection is required when there are multiple servicetitan_connections")
+	}
+
+	return c.ServiceTitan, nil
+}
+
+// EntryConnections returns the servicetitan connections the entry
+// fetches the report from, which is more than one for a roll-up entry
+func (c *Config) EntryConnections(entry Entry) ([]ServiceTitan, error) {
+	if !entry.IsRollUp() {
+		conn, err := c.ServiceTitanConnection(entry.Connection)
+		if err != nil {
+			return nil, err
+		}
+
+		return []ServiceTitan{conn}, nil
+	}
+
+	if entry.Connection != "" {
+		return nil, errors.New("only one of connection or connections can be set")
+	}
+
+	conns := []ServiceTitan{}
+	seen := map[string]bool{}
+
+	for _, name := range entry.Connections {
+		if seen[name] {
+			return nil, fmt.Errorf("servicetitan connection %q is listed more than once", name)
+		}
+		seen[name] = true
+
+		conn, ok := c.ServiceTitanConnections.lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown servicetitan connection %q", name)
+		}
+
+		conns = append(conns, conn)
+	}
+
+	return conns, nil
 }
 
-func (st *ServiceTitan) replaceInterpolatedValues() {
-	st.AppID = convertEnvToValue(st.AppID)
-	st.TenantID = convertEnvToValue(st.TenantID)
-	st.ClientID = convertEnvToValue(st.ClientID)
-	st.ClientSecret = convertEnvToValue(st.ClientSecret)
+func (c *Config) hasDefaultServiceTitan() bool {
+	return c.ServiceTitan != ServiceTitan{}
 }
@@ -87,3 +87,211 @@ func TestServiceTitan_Validate(t *testing.T) {
 		assert.NilError(t, in.Validate())
 	})
 }
+
+func TestServiceTitanConnections_Validate(t *testing.T) {
+	valid := func(name string) ServiceTitan {
+		return ServiceTitan{Name: name, AppID: "ap_3", TenantID: "te_14", ClientID: "cl_15", ClientSecret: "sec_9"}
+	}
+
+	t.Run("returns error when name is missing", func(t *testing.T) {
+		want := Errors{{
+			scope:    "servicetitan_connections[2]",
+			messages: []string{"missing name", "missing tenant_id"},
+		}}
+
+		in := ServiceTitanConnections{
+			valid("franchise-a"),
+			{AppID: "ap_3", ClientID: "cl_15", ClientSecret: "sec_9"},
+		}
+		assert.DeepEqual(t, in.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns error when name is duplicated", func(t *testing.T) {
+		want := Errors{{
+			scope:    "servicetitan_connections[2]",
+			messages: []string{`name "franchise-a" is already used by another connection`},
+		}}
+
+		in := ServiceTitanConnections{valid("franchise-a"), valid("franchise-a")}
+		assert.DeepEqual(t, in.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns no error when all valid", func(t *testing.T) {
+		in := ServiceTitanConnections{valid("franchise-a"), valid("franchise-b")}
+		assert.NilError(t, in.Validate())
+	})
+}
+
+func TestConfig_ServiceTitanConnection(t *testing.T) {
+	defaultConn := ServiceTitan{AppID: "ap_1", TenantID: "te_1", ClientID: "cl_1", ClientSecret: "sec_1"}
+	connA := ServiceTitan{Name: "franchise-a", AppID: "ap_2", TenantID: "te_2", ClientID: "cl_2", ClientSecret: "sec_2"}
+	connB := ServiceTitan{Name: "franchise-b", AppID: "ap_3", TenantID: "te_3", ClientID: "cl_3", ClientSecret: "sec_3"}
+
+	t.Run("returns the servicetitan block when name is empty", func(t *testing.T) {
+		cfg := Config{ServiceTitan: defaultConn, ServiceTitanConnections: ServiceTitanConnections{connA}}
+
+		got, err := cfg.ServiceTitanConnection("")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, defaultConn)
+	})
+
+	t.Run("returns the only named connection when name is empty", func(t *testing.T) {
+		cfg := Config{ServiceTitanConnections: ServiceTitanConnections{connA}}
+
+		got, err := cfg.ServiceTitanConnection("")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, connA)
+	})
+
+	t.Run("returns the named connection", func(t *testing.T) {
+		cfg := Config{ServiceTitan: defaultConn, ServiceTitanConnections: ServiceTitanConnections{connA, connB}}
+
+		got, err := cfg.ServiceTitanConnection("franchise-b")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, connB)
+	})
+
+	t.Run("returns error when name is empty with multiple connections", func(t *testing.T) {
+		cfg := Config{ServiceTitanConnections: ServiceTitanConnections{connA, connB}}
+
+		_, err := cfg.ServiceTitanConnection("")
+		assert.Error(t, err, "connection is required when there are multiple servicetitan_connections")
+	})
+
+	t.Run("returns error when the connection doesn't exist", func(t *testing.T) {
+		cfg := Config{ServiceTitanConnections: ServiceTitanConnections{connA}}
+
+		_, err := cfg.ServiceTitanConnection("franchise-c")
+		assert.Error(t, err, `unknown servicetitan connection "franchise-c"`)
+	})
+}
+
+func TestServiceTitan_Label(t *testing.T) {
+	t.Run("returns the name", func(t *testing.T) {
+		assert.Equal(t, ServiceTitan{Name: "franchise-a", TenantID: "te_1"}.Label(), "franchise-a")
+	})
+
+	t.Run("returns the tenant id when name is empty", func(t *testing.T) {
+		assert.Equal(t, ServiceTitan{TenantID: "te_1"}.Label(), "te_1")
+	})
+}
+
+func TestConfig_EntryConnections(t *testing.T) {
+	connA := ServiceTitan{Name: "franchise-a", AppID: "ap_2", TenantID: "te_2", ClientID: "cl_2", ClientSecret: "sec_2"}
+	connB := ServiceTitan{Name: "franchise-b", AppID: "ap_3", TenantID: "te_3", ClientID: "cl_3", ClientSecret: "sec_3"}
+	cfg := Config{ServiceTitanConnections: ServiceTitanConnections{connA, connB}}
+
+	t.Run("returns the single entry connection", func(t *testing.T) {
+		got, err := cfg.EntryConnections(Entry{Connection: "franchise-b"})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, []ServiceTitan{connB})
+	})
+
+	t.Run("returns every roll-up connection in order", func(t *testing.T) {
+		got, err := cfg.EntryConnections(Entry{Connections: []string{"franchise-b", "franchise-a"}})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, []ServiceTitan{connB, connA})
+	})
+
+	t.Run("returns error when both connection and connections are set", func(t *testing.T) {
+		_, err := cfg.EntryConnections(Entry{Connection: "franchise-a", Connections: []string{"franchise-b"}})
+		assert.Error(t, err, "only one of connection or connections can be set")
+	})
+
+	t.Run("returns error when a connection is repeated", func(t *testing.T) {
+		_, err := cfg.EntryConnections(Entry{Connections: []string{"franchise-a", "franchise-a"}})
+		assert.Error(t, err, `servicetitan connection "franchise-a" is listed more than once`)
+	})
+
+	t.Run("returns error when a connection doesn't exist", func(t *testing.T) {
+		_, err := cfg.EntryConnections(Entry{Connections: []string{"franchise-a", "franchise-c"}})
+		assert.Error(t, err, `unknown servicetitan connection "franchise-c"`)
+	})
+}
+
+func TestServiceTitan_ValidateEnvironment(t *testing.T) {
+	valid := func() ServiceTitan {
+		return ServiceTitan{AppID: "ap_3", TenantID: "te_14", ClientID: "cl_15", ClientSecret: "sec_9"}
+	}
+
+	t.Run("returns error for an unknown environment", func(t *testing.T) {
+		in := valid()
+		in.Environment = "staging"
+
+		assert.ErrorContains(t, in.Validate(), `environment "staging" is invalid only "production", "integration" and "sandbox" are valid`)
+	})
+
+	t.Run("returns error for invalid urls", func(t *testing.T) {
+		in := valid()
+		in.AuthURL = "auth.example.com"
+		in.APIURL = "ftp://api.example.com"
+		in.ProxyURL = "http://"
+
+		want := Error{
+			scope: "servicetitan",
+			messages: []string{
+				`auth_url "auth.example.com" is not a valid http or https url`,
+				`api_url "ftp://api.example.com" is not a valid http or https url`,
+				`proxy_url "http://" is not a valid http or https url`,
+			},
+		}
+		assert.DeepEqual(t, in.Validate(), want, cmp.AllowUnexported(Error{}))
+	})
+
+	t.Run("returns error when the ca cert file is missing", func(t *testing.T) {
+		in := valid()
+		in.CACertFile = "/does/not/exist.pem"
+
+		assert.ErrorContains(t, in.Validate(), "ca_cert_file open /does/not/exist.pem: no such file or directory")
+	})
+
+	t.Run("returns no error with environment and urls", func(t *testing.T) {
+		in := valid()
+		in.Environment = "integration"
+		in.AuthURL = "http://localhost:8080"
+		in.ProxyURL = "http://proxy.internal:3128"
+
+		assert.NilError(t, in.Validate())
+	})
+}
+
+func TestServiceTitan_BaseURLs(t *testing.T) {
+	specs := []struct {
+		name        string
+		in          ServiceTitan
+		wantAuthURL string
+		wantAPIURL  string
+	}{
+		{
+			name:        "defaults to production",
+			in:          ServiceTitan{},
+			wantAuthURL: "https://auth.servicetitan.io",
+			wantAPIURL:  "https://api.servicetitan.io",
+		},
+		{
+			name:        "returns integration urls",
+			in:          ServiceTitan{Environment: "integration"},
+			wantAuthURL: "https://auth-integration.servicetitan.io",
+			wantAPIURL:  "https://api-integration.servicetitan.io",
+		},
+		{
+			name:        "returns sandbox urls",
+			in:          ServiceTitan{Environment: "sandbox"},
+			wantAuthURL: "http://localhost:8081",
+			wantAPIURL:  "http://localhost:8081",
+		},
+		{
+			name:        "returns the overridden urls",
+			in:          ServiceTitan{Environment: "integration", AuthURL: "http://localhost:9000/", APIURL: "http://localhost:9001"},
+			wantAuthURL: "http://localhost:9000",
+			wantAPIURL:  "http://localhost:9001",
+		},
+	}
+
+	for _, spec := range specs {
+		t.Run(spec.name, func(t *testing.T) {
+			assert.Equal(t, spec.in.BaseAuthURL(), spec.wantAuthURL)
+			assert.Equal(t, spec.in.BaseAPIURL(), spec.wantAPIURL)
+		})
+	}
+}
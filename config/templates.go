@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// applyTemplates merges each entry with the template it extends and then
+// the defaults, fields set in the entry win over the template and defaults
+func (c *Config) applyTemplates() Errors {
+	var errs Errors
+
+	var defaults Entry
+	if c.Defaults != nil {
+		var err error
+		if defaults, err = c.resolveExtends(*c.Defaults, nil); err != nil {
+			errs = append(errs, Error{scope: "defaults", messages: []string{err.Error()}})
+		}
+	}
+
+	for idx, entry := range c.Entries {
+		resolved, err := c.resolveExtends(entry, nil)
+		if err != nil {
+			errs = append(errs, Error{scope: entry.scope(idx), messages: []string{err.Error()}})
+			continue
+		}
+
+		c.Entries[idx] = mergeEntry(resolved, defaults)
+	}
+
+	return errs
+}
+
+// resolveExtends merges the entry with the chain of templates it extends
+func (c *Config) resolveExtends(entry Entry, chain []string) (Entry, error) {
+	if entry.Extends == "" {
+		return entry, nil
+	}
+
+	name := entry.Extends
+	for _, prev := range chain {
+		if prev == name {
+			return entry, fmt.Errorf("template %q extends itself through %s", name, strings.Join(append(chain, name), " -> "))
+		}
+	}
+
+	tpl, ok := c.Templates[name]
+	if !ok {
+		return entry, fmt.Errorf("extends unknown template %q", name)
+	}
+
+	base, err := c.resolveExtends(tpl, append(chain, name))
+	if err != nil {
+		return entry, err
+	}
+
+	merged := mergeEntry(entry, base)
+	merged.Extends = ""
+
+	return merged, nil
+}
+
+// mergeEntry returns the entry with every field that isn't set taken from the base
+func mergeEntry(entry, base Entry) Entry {
+	// Only one of connection or connections can be set, so an
+	// entry setting either doesn't inherit the other from the base
+	if entry.Connection != "" || len(entry.Connections) > 0 {
+		base.Connection, base.Connections = "", nil
+	}
+
+	// Likewise only one of report, export or file can be set
+	switch {
+	case entry.IsExport():
+		base.Report, base.File = Report{}, nil
+	case entry.IsFile():
+		base.Report, base.Export = Report{}, nil
+	case entry.Report.ID != "" || entry.Report.CategoryID != "":
+		base.Export, base.File = nil, nil
+	}
+
+	mergeValue(reflect.ValueOf(&entry).Elem(), reflect.ValueOf(base))
+	return entry
+}
+
+// mergeValue sets the zero fields of dst from base, lists of named
+// items such as parameters are merged by name with dst taking priority
+func mergeValue(dst, base reflect.Value) {
+	switch dst.Kind() {
+	case reflect.Struct:
+		for idx := 0; idx < dst.NumField(); idx++ {
+			if dst.Type().Field(idx).PkgPath != "" {
+				continue
+			}
+
+			mergeValue(dst.Field(idx), base.Field(idx))
+		}
+	case reflect.Slice:
+		if dst.Len() == 0 {
+			dst.Set(copyValue(base))
+			return
+		}
+
+		if isNamedStruct(dst.Type().Elem()) {
+			dst.Set(mergeNamed(dst, base))
+		}
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(copyValue(base))
+		}
+	default:
+		if dst.IsZero() {
+			dst.Set(base)
+		}
+	}
+}
+
+// copyValue returns a copy of the slice or pointer which doesn't share the base
+// backing array or struct, so entries changing their copy don't change others
+func copyValue(base reflect.Value) reflect.Value {
+	switch {
+	case base.Kind() == reflect.Slice && !base.IsNil():
+		items := reflect.MakeSlice(base.Type(), base.Len(), base.Len())
+		for idx := 0; idx < base.Len(); idx++ {
+			mergeValue(items.Index(idx), base.Index(idx))
+		}
+
+		return items
+	case base.Kind() == reflect.Ptr && !base.IsNil():
+		item := reflect.New(base.Type().Elem())
+		mergeValue(item.Elem(), base.Elem())
+		return item
+	}
+
+	return base
+}
+
+func isNamedStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	field, ok := t.FieldByName("Name")
+	return ok && field.Type.Kind() == reflect.String
+}
+
+// mergeNamed returns the base items followed by the dst items, where dst
+// has an item with the same name as the base it replaces the base item
+func mergeNamed(dst, base reflect.Value) reflect.Value {
+	merged := reflect.MakeSlice(dst.Type(), 0, dst.Len()+base.Len())
+	used := map[string]bool{}
+
+	for idx := 0; idx < base.Len(); idx++ {
+		item := reflect.New(base.Type().Elem()).Elem()
+		mergeValue(item, base.Index(idx))
+		name := item.FieldByName("Name").String()
+
+		for j := 0; j < dst.Len(); j++ {
+			if dst.Index(j).FieldByName("Name").String() == name {
+				item = dst.Index(j)
+				used[name] = true
+				break
+			}
+		}
+
+		merged = reflect.Append(merged, item)
+	}
+
+	for idx := 0; idx < dst.Len(); idx++ {
+		if item := dst.Index(idx); !used[item.FieldByName("Name").String()] {
+			merged = reflect.Append(merged, item)
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const templatesConfig = `
+servicetitan:
+  app_id: app
+  tenant_id: ten
+  client_id: id
+  client_secret: st-secret-123
+geckoboard:
+  api_key: api123
+defaults:
+  dataset:
+    type: append
+    required_fields: [Name]
+  report:
+    parameters:
+      - {name: From, value: NOW-7}
+      - {name: To, value: NOW}
+templates:
+  sales:
+    report:
+      category_id: sales
+      parameters:
+        - {name: From, value: CURRENT_MONTH_DAY1}
+    dataset:
+      field_overrides:
+        - {name: Revenue, type: Number}
+  sales-percent:
+    extends: sales
+    dataset:
+      field_overrides:
+        - {name: Margin, type: Percentage}
+`
+
+func TestLoadFile_Templates(t *testing.T) {
+	t.Run("merges the template and defaults into each entry", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": templatesConfig + `
+entries:
+  - extends: sales-percent
+    report:
+      id: "1"
+    dataset:
+      type: replace
+      field_overrides:
+        - {name: Revenue, type: Percentage}
+  - report: {id: "2", category_id: other}
+`,
+		})
+
+		cfg, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.NilError(t, err)
+		assert.NilError(t, cfg.Validate())
+
+		first := cfg.Entries[0]
+		assert.Equal(t, first.Extends, "")
+		assert.DeepEqual(t, first.Report, Report{
+			ID:         "1",
+			CategoryID: "sales",
+			Parameters: []Parameter{
+				{Name: "From", Value: "CURRENT_MONTH_DAY1"},
+				{Name: "To", Value: "NOW"},
+			},
+		})
+		assert.DeepEqual(t, first.Dataset, Dataset{
+			Type:           "replace",
+			RequiredFields: []string{"Name"},
+			FieldOverrides: []ReportField{
+				{Name: "Revenue", Type: "Percentage"},
+				{Name: "Margin", Type: "Percentage"},
+			},
+		})
+
+		second := cfg.Entries[1]
+		assert.DeepEqual(t, second.Report.Parameters, []Parameter{
+			{Name: "From", Value: "NOW-7"},
+			{Name: "To", Value: "NOW"},
+		})
+		assert.Equal(t, second.Dataset.Type, "append")
+		assert.Equal(t, len(second.Dataset.FieldOverrides), 0)
+	})
+
+	t.Run("doesn't inherit connection when the entry sets connections", func(t *testing.T) {
+		c := &Config{
+			Defaults: &Entry{Connection: "franchise-a"},
+			Entries: Entries{
+				{Connections: []string{"franchise-a", "franchise-b"}},
+				{},
+			},
+		}
+
+		assert.Equal(t, len(c.applyTemplates()), 0)
+		assert.Equal(t, c.Entries[0].Connection, "")
+		assert.Equal(t, c.Entries[1].Connection, "franchise-a")
+	})
+
+	t.Run("doesn't inherit the report when the entry sets an export or file", func(t *testing.T) {
+		c := &Config{
+			Defaults: &Entry{Report: Report{CategoryID: "sales", Parameters: []Parameter{{Name: "From", Value: "NOW"}}}},
+			Templates: map[string]Entry{
+				"jobs": {Export: &Export{Resource: "jpm/jobs"}},
+			},
+			Entries: Entries{
+				{Export: &Export{Resource: "crm/customers"}},
+				{Extends: "jobs", Report: Report{ID: "1"}},
+				{Extends: "jobs", File: &File{Path: "targets.csv"}},
+			},
+		}
+
+		assert.Equal(t, len(c.applyTemplates()), 0)
+		assert.DeepEqual(t, c.Entries[0].Report, Report{})
+		assert.Assert(t, c.Entries[1].Export == nil)
+		assert.Equal(t, c.Entries[1].Report.CategoryID, "sales")
+		assert.Assert(t, c.Entries[2].Export == nil)
+		assert.DeepEqual(t, c.Entries[2].Report, Report{})
+	})
+
+	t.Run("doesn't share lists or exports between entries", func(t *testing.T) {
+		c := &Config{
+			Defaults: &Entry{
+				Dataset: Dataset{RequiredFields: []string{"Name"}},
+				Export:  &Export{Resource: "crm/customers"},
+			},
+			Entries: Entries{{}, {}},
+		}
+
+		assert.Equal(t, len(c.applyTemplates()), 0)
+
+		c.Entries[0].Dataset.RequiredFields[0] = "ID"
+		c.Entries[0].Export.Resource = "jpm/jobs"
+
+		assert.DeepEqual(t, c.Entries[1].Dataset.RequiredFields, []string{"Name"})
+		assert.Equal(t, c.Entries[1].Export.Resource, "crm/customers")
+		assert.DeepEqual(t, c.Defaults.Dataset.RequiredFields, []string{"Name"})
+	})
+
+	t.Run("returns errors for unknown templates and cycles", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": templatesConfig + `
+  loop-a:
+    extends: loop-b
+  loop-b:
+    extends: loop-a
+entries:
+  - extends: missing
+    report: {id: "1", category_id: cat-1}
+  - extends: loop-a
+    report: {id: "2", category_id: cat-2}
+`,
+		})
+
+		cfg, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.NilError(t, err)
+
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, "Config section \"entries[1]\" errors:\n - extends unknown template \"missing\"")
+		assert.ErrorContains(t, err, "Config section \"entries[2]\" errors:\n - template \"loop-a\" extends itself through loop-a -> loop-b -> loop-a")
+	})
+
+	t.Run("returns error when a template is defined in more than one file", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yml": templatesConfig + "include: [team.yml]\nentries:\n  - report: {id: \"1\", category_id: cat-1}\n",
+			"team.yml":   "templates:\n  sales:\n    report: {category_id: other}\n",
+		})
+
+		_, err := LoadFile(filepath.Join(dir, "config.yml"))
+		assert.Error(t, err, filepath.Join(dir, "team.yml")+": template \"sales\" is already defined in the main config")
+	})
+}
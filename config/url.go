@@ -0,0 +1,44 @@
+package config
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// validateURL returns a message when the value is set
+// but isn't an absolute http or https url
+func validateURL(key, value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return []string{fmt.Sprintf("%s %q is not a valid http or https url", key, value)}
+	}
+
+	return nil
+}
+
+// LoadCACertPool returns the system cert pool with the
+// PEM encoded certificates from the file added to it
+func LoadCACertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("contains no valid PEM encoded certificates")
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// stringVisitor returns the replacement for a string value at the path,
+// secret is true when the value is within a field tagged secret:"true"
+type stringVisitor func(value, path string, secret bool) string
+
+// walkStrings calls the visitor for every exported string value reachable
+// from v, the path is built from the yaml keys to identify the value
+func walkStrings(v reflect.Value, path string, secret bool, visit stringVisitor) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			walkStrings(v.Elem(), path, secret, visit)
+		}
+	case reflect.Struct:
+		t := v.Type()
+
+		for idx := 0; idx < t.NumField(); idx++ {
+			field := t.Field(idx)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			key := yamlKey(field)
+			if key == "-" {
+				continue
+			}
+
+			isSecret := secret || field.Tag.Get("secret") == "true"
+			walkStrings(v.Field(idx), joinPath(path, key), isSecret, visit)
+		}
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < v.Len(); idx++ {
+			walkStrings(v.Index(idx), fmt.Sprintf("%s[%d]", path, idx+1), secret, visit)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+
+		for iter.Next() {
+			// Map values aren't addressable so replace a copy of the value
+			val := reflect.New(iter.Value().Type()).Elem()
+			val.Set(iter.Value())
+
+			walkStrings(val, joinPath(path, fmt.Sprint(iter.Key().Interface())), secret, visit)
+			v.SetMapIndex(iter.Key(), val)
+		}
+	case reflect.Interface:
+		if v.IsNil() || !v.CanSet() {
+			return
+		}
+
+		val := reflect.New(v.Elem().Type()).Elem()
+		val.Set(v.Elem())
+
+		walkStrings(val, path, secret, visit)
+		v.Set(val)
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(visit(v.String(), path, secret))
+		}
+	}
+}
+
+func yamlKey(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if key == "" {
+		return strings.ToLower(field.Name)
+	}
+
+	return key
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
@@ -1,12 +1,14 @@
 package dataset
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"servicetitan-to-dataset/config"
 	"servicetitan-to-dataset/servicetitan"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jnormington/geckoboard"
 )
@@ -16,18 +18,40 @@ var (
 	datasetNameRegexp = regexp.MustCompile(`[^0-9a-z._\- ]+`)
 )
 
-const maxFieldValueByteLength = 256
+const (
+	maxFieldValueByteLength = 256
+
+	tenantFieldKey   = "tenant"
+	tenantFieldLabel = "Tenant"
+)
 
 type BuilderConfig struct {
 	Report           *servicetitan.Report
 	Data             *servicetitan.ReportData
 	DatasetOverrides config.Dataset
+	// Tenant is the servicetitan connection name which prefixes
+	// the dataset name to keep the same report unique per tenant
+	Tenant string
+	// Location is the config time location datetimes are
+	// pushed in, the local time zone is used when nil
+	Location *time.Location
+}
+
+// TenantReport is the report and data fetched from a single tenant
+// which is merged with other tenants into a roll-up dataset
+type TenantReport struct {
+	Tenant string
+	Report *servicetitan.Report
+	Data   *servicetitan.ReportData
 }
 
 type DatasetBuilder struct {
 	report           *servicetitan.Report
 	data             *servicetitan.ReportData
 	datasetOverrides config.Dataset
+	tenant           string
+	rollUp           []TenantReport
+	location         *time.Location
 }
 
 func NewDatasetBuilder(conf BuilderConfig) *DatasetBuilder {
@@ -35,7 +59,45 @@ func NewDatasetBuilder(conf BuilderConfig) *DatasetBuilder {
 		report:           conf.Report,
 		data:             conf.Data,
 		datasetOverrides: conf.DatasetOverrides,
+		tenant:           conf.Tenant,
+		location:         location(conf.Location),
+	}
+}
+
+// NewRollUpDatasetBuilder returns a builder which merges the same report
+// from multiple tenants into one dataset with an additional tenant field.
+// It returns an error when a tenant report fields differ from the first tenant
+func NewRollUpDatasetBuilder(overrides config.Dataset, loc *time.Location, reports []TenantReport) (*DatasetBuilder, error) {
+	if len(reports) == 0 {
+		return nil, errors.New("at least one tenant report is required")
+	}
+
+	first := reports[0]
+	for _, tr := range reports[1:] {
+		if err := compareFields(first.Report.Fields, tr.Report.Fields); err != nil {
+			return nil, fmt.Errorf("tenant %q report fields are incompatible with tenant %q: %w", tr.Tenant, first.Tenant, err)
+		}
+
+		if err := compareFields(first.Data.Fields, tr.Data.Fields); err != nil {
+			return nil, fmt.Errorf("tenant %q report data fields are incompatible with tenant %q: %w", tr.Tenant, first.Tenant, err)
+		}
+	}
+
+	return &DatasetBuilder{
+		report:           first.Report,
+		data:             first.Data,
+		datasetOverrides: overrides,
+		rollUp:           reports,
+		location:         location(loc),
+	}, nil
+}
+
+func location(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.Local
 	}
+
+	return loc
 }
 
 func (d *DatasetBuilder) BuildSchema() *geckoboard.Dataset {
@@ -56,6 +118,16 @@ func (d *DatasetBuilder) BuildSchema() *geckoboard.Dataset {
 		}
 	}
 
+	// The tenant is always required as the same row
+	// can exist in more than one tenant report
+	if len(d.rollUp) > 0 {
+		fields[tenantFieldKey] = geckoboard.Field{
+			Type: geckoboard.StringType,
+			Name: tenantFieldLabel,
+		}
+		uniqueFields = append(uniqueFields, tenantFieldKey)
+	}
+
 	return &geckoboard.Dataset{
 		Name:     d.datasetName(),
 		Fields:   fields,
@@ -63,36 +135,30 @@ func (d *DatasetBuilder) BuildSchema() *geckoboard.Dataset {
 	}
 }
 
+// BuildData returns the dataset rows of the report data, a value which can't
+// be converted is left out like BuildRow and a row it's required for is skipped
 func (d *DatasetBuilder) BuildData() geckoboard.Data {
+	if len(d.rollUp) == 0 {
+		return d.buildRows(d.data, "")
+	}
+
 	data := geckoboard.Data{}
+	for _, tr := range d.rollUp {
+		data = append(data, d.buildRows(tr.Data, tr.Tenant)...)
+	}
+
+	return data
+}
 
-	for _, r := range d.data.Data {
-		gr := geckoboard.DataRow{}
+func (d *DatasetBuilder) buildRows(reportData *servicetitan.ReportData, tenant string) geckoboard.Data {
+	data := geckoboard.Data{}
 
+	for _, r := range reportData.Data {
 		switch row := r.(type) {
 		case []interface{}:
-			for idx, val := range row {
-				field := d.data.Fields[idx]
-				name := d.safeDataFieldName(field)
-
-				switch nval := val.(type) {
-				case string:
-					if len(nval) > maxFieldValueByteLength {
-						nval = nval[:maxFieldValueByteLength]
-					}
-					gr[name] = nval
-				case bool:
-					gr[name] = strings.ToUpper(strconv.FormatBool(nval))
-				case int, float64:
-					if field.Type == "String" {
-						gr[name] = fmt.Sprintf("%v", nval)
-					} else {
-						gr[name] = nval
-					}
-				}
+			if gr, _ := d.BuildRow(reportData.Fields, row, tenant); gr != nil {
+				data = append(data, gr)
 			}
-
-			data = append(data, gr)
 		default:
 			panic("unexpected data row")
 		}
@@ -101,6 +167,83 @@ func (d *DatasetBuilder) BuildData() geckoboard.Data {
 	return data
 }
 
+// BuildRow returns the dataset row of the report row values, each value is
+// for the field at the same index. The tenant is only added to roll-up rows,
+// which lets rows be built one at a time as they're read from a source.
+// Date and datetime values are normalised, a value which isn't a date is left
+// out of the row and returned as a ValueError. The row is nil when the value
+// of a required field is invalid, as geckoboard would reject the whole push
+func (d *DatasetBuilder) BuildRow(fields []servicetitan.ReportField, row []interface{}, tenant string) (geckoboard.DataRow, []*ValueError) {
+	gr := geckoboard.DataRow{}
+
+	if tenant != "" && len(d.rollUp) > 0 {
+		gr[tenantFieldKey] = tenant
+	}
+
+	var (
+		errs []*ValueError
+		skip bool
+	)
+
+	for idx, val := range row {
+		field := fields[idx]
+		name := d.safeDataFieldName(field)
+		fieldType := d.fieldType(field)
+
+		if val != nil && (fieldType == "Date" || fieldType == "Datetime") {
+			date, err := d.buildDate(field, fieldType, val)
+			if err != nil {
+				errs = append(errs, err)
+				skip = skip || !d.isOptionalField(field)
+				continue
+			}
+
+			gr[name] = date
+			continue
+		}
+
+		switch nval := val.(type) {
+		case string:
+			if len(nval) > maxFieldValueByteLength {
+				nval = nval[:maxFieldValueByteLength]
+			}
+			gr[name] = nval
+		case bool:
+			gr[name] = strings.ToUpper(strconv.FormatBool(nval))
+		case int, float64:
+			if field.Type == "String" {
+				gr[name] = fmt.Sprintf("%v", nval)
+			} else {
+				gr[name] = nval
+			}
+		}
+	}
+
+	if skip {
+		return nil, errs
+	}
+
+	return gr, errs
+}
+
+// buildDate returns the value as a geckoboard date or datetime
+func (d *DatasetBuilder) buildDate(field servicetitan.ReportField, fieldType string, val interface{}) (string, *ValueError) {
+	text, ok := val.(string)
+
+	var date string
+	if ok && fieldType == "Date" {
+		date, ok = normaliseDate(text, d.location)
+	} else if ok {
+		date, ok = normaliseDatetime(text, d.location)
+	}
+
+	if !ok {
+		return "", &ValueError{Field: field.Name, Value: val, Type: fieldType}
+	}
+
+	return date, nil
+}
+
 func (d *DatasetBuilder) safeDataFieldName(field servicetitan.ReportField) string {
 	key := fieldIDRegexp.ReplaceAllString(strings.ToLower(field.Name), "")
 	return strings.ReplaceAll(key, " ", "_")
@@ -109,8 +252,11 @@ func (d *DatasetBuilder) safeDataFieldName(field servicetitan.ReportField) strin
 func (d *DatasetBuilder) datasetName() string {
 	name := d.report.Name
 
-	if d.datasetOverrides.Name != "" {
+	switch {
+	case d.datasetOverrides.Name != "":
 		name = d.datasetOverrides.Name
+	case d.tenant != "":
+		name = d.tenant + " " + name
 	}
 
 	nn := datasetNameRegexp.ReplaceAllLiteralString(strings.ToLower(name), "")
@@ -128,15 +274,7 @@ func (d *DatasetBuilder) isOptionalField(field servicetitan.ReportField) bool {
 }
 
 func (d *DatasetBuilder) datasetFieldType(field servicetitan.ReportField) geckoboard.FieldType {
-	var fieldType = field.Type
-
-	// If a field override exists use that field type
-	// to return the specific dataset field type
-	if ovf := d.fieldOverride(field); ovf != nil {
-		fieldType = ovf.Type
-	}
-
-	switch fieldType {
+	switch d.fieldType(field) {
 	case "String":
 		return geckoboard.StringType
 	case "Number":
@@ -159,6 +297,16 @@ func (d *DatasetBuilder) datasetFieldType(field servicetitan.ReportField) geckob
 	return "unknown"
 }
 
+// fieldType returns the type of the field, or the type of its override
+// which returns the specific dataset field type
+func (d *DatasetBuilder) fieldType(field servicetitan.ReportField) string {
+	if ovf := d.fieldOverride(field); ovf != nil {
+		return ovf.Type
+	}
+
+	return field.Type
+}
+
 func (d *DatasetBuilder) fieldOverride(field servicetitan.ReportField) *config.ReportField {
 	for _, f := range d.datasetOverrides.FieldOverrides {
 		if f.Name == field.Name {
@@ -168,3 +316,34 @@ func (d *DatasetBuilder) fieldOverride(field servicetitan.ReportField) *config.R
 
 	return nil
 }
+
+// compareFields checks both lists of fields have the same names and
+// types, the order doesn't matter as rows are mapped by their own fields
+func compareFields(want, got []servicetitan.ReportField) error {
+	wantTypes := map[string]string{}
+	for _, f := range want {
+		wantTypes[f.Name] = f.Type
+	}
+
+	gotTypes := map[string]string{}
+	for _, f := range got {
+		gotTypes[f.Name] = f.Type
+
+		wantType, ok := wantTypes[f.Name]
+		if !ok {
+			return fmt.Errorf("unexpected field %q", f.Name)
+		}
+
+		if wantType != f.Type {
+			return fmt.Errorf("field %q has type %q but expected %q", f.Name, f.Type, wantType)
+		}
+	}
+
+	for _, f := range want {
+		if _, ok := gotTypes[f.Name]; !ok {
+			return fmt.Errorf("missing field %q", f.Name)
+		}
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"servicetitan-to-dataset/servicetitan"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jnormington/geckoboard"
 	"gotest.tools/v3/assert"
@@ -62,6 +63,25 @@ func TestDatasetBuilder_BuildSchema(t *testing.T) {
 		assert.Equal(t, got.Name, "report_a")
 	})
 
+	t.Run("prefixes the dataset name with the tenant", func(t *testing.T) {
+		conf := buildConfig()
+		conf.Tenant = "Franchise A"
+
+		builder := NewDatasetBuilder(conf)
+		got := builder.BuildSchema()
+		assert.Equal(t, got.Name, "franchise_a_report_a")
+	})
+
+	t.Run("uses the overridden dataset name without the tenant", func(t *testing.T) {
+		conf := buildConfig()
+		conf.Tenant = "franchise-a"
+		conf.DatasetOverrides.Name = "dataset-2"
+
+		builder := NewDatasetBuilder(conf)
+		got := builder.BuildSchema()
+		assert.Equal(t, got.Name, "dataset-2")
+	})
+
 	t.Run("returns valid dataset name from the overridden value", func(t *testing.T) {
 		specs := []struct {
 			in  string
@@ -105,7 +125,7 @@ func TestDatasetBuilder_BuildData(t *testing.T) {
 				"name":            "Jane Doe",
 				"number_of_jobs":  9,
 				"completion_rate": 0.24,
-				"created_on":      "2023-10-13T00:00:00-05:00Z",
+				"created_on":      "2023-10-13T00:00:00-05:00",
 				"tags":            strings.Repeat("b", 100),
 			},
 			map[string]interface{}{
@@ -115,10 +135,120 @@ func TestDatasetBuilder_BuildData(t *testing.T) {
 				"number_of_jobs":  15,
 				"completion_rate": 0.87,
 				"created_on":      "2023-10-13T00:00:00-05:00",
-				"tags":            strings.Repeat("界", 256),
+				"tags":            strings.Repeat("界", 300)[:256],
 			},
 		})
 	})
+
+	t.Run("normalises the date and datetime values", func(t *testing.T) {
+		specs := []struct {
+			in           string
+			wantDate     string
+			wantDatetime string
+		}{
+			{"2022-10-01", "2022-10-01", "2022-10-01T00:00:00-05:00"},
+			{"2022-10-01T00:00:00", "2022-10-01", "2022-10-01T00:00:00-05:00"},
+			{"2022-10-01T14:30:15.123", "2022-10-01", "2022-10-01T14:30:15-05:00"},
+			{"2022-10-01 14:30:15", "2022-10-01", "2022-10-01T14:30:15-05:00"},
+			{"2022-10-01T23:30:00Z", "2022-10-01", "2022-10-01T18:30:00-05:00"},
+			{"2022-10-01T02:00:00+02:00", "2022-10-01", "2022-09-30T19:00:00-05:00"},
+			{"10/01/2022", "2022-10-01", "2022-10-01T00:00:00-05:00"},
+		}
+
+		for _, tc := range specs {
+			t.Run(tc.in, func(t *testing.T) {
+				conf := buildConfig()
+				conf.Data.Data = []interface{}{
+					[]interface{}{"John Smith", 5, true, tc.in, 0.12, tc.in, "a"},
+				}
+
+				got := NewDatasetBuilder(conf).BuildData()
+				assert.Equal(t, got[0]["completed_on"], tc.wantDate)
+				assert.Equal(t, got[0]["created_on"], tc.wantDatetime)
+			})
+		}
+	})
+
+	t.Run("uses the overridden field type", func(t *testing.T) {
+		conf := buildConfig()
+		conf.DatasetOverrides.FieldOverrides = append(conf.DatasetOverrides.FieldOverrides, config.ReportField{Name: "Tags", Type: "Date"})
+		conf.Data.Data = []interface{}{
+			[]interface{}{"John Smith", 5, true, "2021-10-13", 0.12, "2023-10-13T00:00:00", "2022-06-01T00:00:00"},
+		}
+
+		got := NewDatasetBuilder(conf).BuildData()
+		assert.Equal(t, got[0]["tags"], "2022-06-01")
+	})
+
+	t.Run("skips a nil date", func(t *testing.T) {
+		conf := buildConfig()
+		conf.Data.Data = []interface{}{
+			[]interface{}{"John Smith", 5, true, nil, 0.12, nil, "a"},
+		}
+
+		got := NewDatasetBuilder(conf).BuildData()
+		assert.DeepEqual(t, got[0], geckoboard.DataRow{
+			"active":          "TRUE",
+			"name":            "John Smith",
+			"number_of_jobs":  5,
+			"completion_rate": 0.12,
+			"tags":            "a",
+		})
+	})
+
+	t.Run("leaves out an invalid date and skips the row when the field is required", func(t *testing.T) {
+		conf := buildConfig()
+		conf.DatasetOverrides.RequiredFields = []string{"Name", "Created on"}
+		conf.Data.Data = []interface{}{
+			[]interface{}{"John Smith", 5, true, "13th October", 0.12, "2023-10-13T00:00:00", "a"},
+			[]interface{}{"Jane Doe", 9, true, "2021-10-13", 0.24, "2023-10-13T00:00:00-05:00Z", "b"},
+		}
+
+		got := NewDatasetBuilder(conf).BuildData()
+		assert.Equal(t, len(got), 1)
+		assert.Equal(t, got[0]["name"], "John Smith")
+		assert.Assert(t, got[0]["completed_on"] == nil)
+	})
+}
+
+func TestDatasetBuilder_BuildRow(t *testing.T) {
+	conf := buildConfig()
+	fields := conf.Data.Fields
+
+	t.Run("returns every invalid date and the row without them", func(t *testing.T) {
+		row := []interface{}{"John Smith", 5, true, 20211013, 0.12, "2023-10-13T00:00:00-05:00Z", "a"}
+
+		got, errs := NewDatasetBuilder(conf).BuildRow(fields, row, "")
+		assert.DeepEqual(t, got, geckoboard.DataRow{
+			"active":          "TRUE",
+			"name":            "John Smith",
+			"number_of_jobs":  5,
+			"completion_rate": 0.12,
+			"tags":            "a",
+		})
+
+		assert.Equal(t, len(errs), 2)
+		assert.Error(t, errs[0], `field "Completed on" value "20211013" is not a valid date`)
+		assert.Error(t, errs[1], `field "Created on" value "2023-10-13T00:00:00-05:00Z" is not a valid datetime`)
+		assert.Equal(t, errs[1].Field, "Created on")
+	})
+
+	t.Run("returns no row when the invalid date is a required field", func(t *testing.T) {
+		conf := buildConfig()
+		conf.DatasetOverrides.RequiredFields = []string{"Name", "Completed on"}
+		row := []interface{}{"John Smith", 5, true, "13th October", 0.12, "2023-10-13T00:00:00", "a"}
+
+		got, errs := NewDatasetBuilder(conf).BuildRow(fields, row, "")
+		assert.Assert(t, got == nil)
+		assert.Error(t, errs[0], `field "Completed on" value "13th October" is not a valid date`)
+	})
+
+	t.Run("returns no errors for a valid row", func(t *testing.T) {
+		row := []interface{}{"John Smith", 5, true, "2021-10-13", 0.12, "2023-10-13T00:00:00", "a"}
+
+		_, errs := NewDatasetBuilder(conf).BuildRow(fields, row, "")
+		assert.Equal(t, len(errs), 0)
+	})
 }
 
 func buildConfig() BuilderConfig {
@@ -148,7 +278,7 @@ func buildConfig() BuilderConfig {
 		Data: &servicetitan.ReportData{
 			Data: []interface{}{
 				[]interface{}{"John Smith", 5, true, "2021-10-13", 0.12, "2023-10-13T00:00:00-05:00", strings.Repeat("a", 300)},
-				[]interface{}{"Jane Doe", 9, true, "2021-10-13", 0.24, "2023-10-13T00:00:00-05:00Z", strings.Repeat("b", 100)},
+				[]interface{}{"Jane Doe", 9, true, "2021-10-13", 0.24, "2023-10-13T00:00:00", strings.Repeat("b", 100)},
 				[]interface{}{"Hilary", 15, false, "2021-10-13", 0.87, "2023-10-13T00:00:00-05:00", strings.Repeat("界", 300)},
 			},
 			Fields: []servicetitan.ReportField{
@@ -177,5 +307,105 @@ func buildConfig() BuilderConfig {
 				},
 			},
 		},
+		Location: time.FixedZone("CDT", -5*60*60),
+	}
+}
+
+func TestNewRollUpDatasetBuilder(t *testing.T) {
+	t.Run("returns error when there are no tenant reports", func(t *testing.T) {
+		_, err := NewRollUpDatasetBuilder(config.Dataset{}, nil, nil)
+		assert.Error(t, err, "at least one tenant report is required")
+	})
+
+	t.Run("returns error naming the tenant with a missing field", func(t *testing.T) {
+		reports := buildTenantReports()
+		reports[1].Report.Fields = reports[1].Report.Fields[:1]
+
+		_, err := NewRollUpDatasetBuilder(config.Dataset{}, nil, reports)
+		assert.Error(t, err, `tenant "franchise-b" report fields are incompatible with tenant "franchise-a": missing field "Jobs"`)
+	})
+
+	t.Run("returns error naming the tenant with a different field type", func(t *testing.T) {
+		reports := buildTenantReports()
+		reports[1].Data.Fields = []servicetitan.ReportField{
+			{Name: "Name", Label: "Name", Type: "String"},
+			{Name: "Jobs", Label: "Jobs", Type: "String"},
+		}
+
+		_, err := NewRollUpDatasetBuilder(config.Dataset{}, nil, reports)
+		assert.Error(t, err, `tenant "franchise-b" report data fields are incompatible with tenant "franchise-a": field "Jobs" has type "String" but expected "Number"`)
+	})
+
+	t.Run("returns error naming the tenant with an unexpected field", func(t *testing.T) {
+		reports := buildTenantReports()
+		reports[1].Report.Fields = append(reports[1].Report.Fields, servicetitan.ReportField{Name: "Extra", Type: "String"})
+
+		_, err := NewRollUpDatasetBuilder(config.Dataset{}, nil, reports)
+		assert.Error(t, err, `tenant "franchise-b" report fields are incompatible with tenant "franchise-a": unexpected field "Extra"`)
+	})
+
+	t.Run("builds the schema with the tenant field", func(t *testing.T) {
+		builder, err := NewRollUpDatasetBuilder(config.Dataset{RequiredFields: []string{"Name"}}, nil, buildTenantReports())
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, builder.BuildSchema(), &geckoboard.Dataset{
+			Name: "jobs_report",
+			Fields: map[string]geckoboard.Field{
+				"name":   {Type: "string", Name: "Name"},
+				"jobs":   {Type: "number", Name: "Jobs", Optional: true},
+				"tenant": {Type: "string", Name: "Tenant"},
+			},
+			UniqueBy: []string{"name", "tenant"},
+		})
+	})
+
+	t.Run("builds the data from every tenant", func(t *testing.T) {
+		builder, err := NewRollUpDatasetBuilder(config.Dataset{RequiredFields: []string{"Name"}}, nil, buildTenantReports())
+		assert.NilError(t, err)
+
+		got := builder.BuildData()
+
+		assert.DeepEqual(t, got, geckoboard.Data{
+			{"name": "John Smith", "jobs": 5, "tenant": "franchise-a"},
+			{"name": "Jane Doe", "jobs": 9, "tenant": "franchise-b"},
+			{"name": "John Smith", "jobs": 2, "tenant": "franchise-b"},
+		})
+	})
+}
+
+func buildTenantReports() []TenantReport {
+	fields := func() []servicetitan.ReportField {
+		return []servicetitan.ReportField{
+			{Name: "Name", Label: "Name", Type: "String"},
+			{Name: "Jobs", Label: "Jobs", Type: "Number"},
+		}
+	}
+
+	return []TenantReport{
+		{
+			Tenant: "franchise-a",
+			Report: &servicetitan.Report{ID: 1, Name: "Jobs report", Fields: fields()},
+			Data: &servicetitan.ReportData{
+				Fields: fields(),
+				Data: []interface{}{
+					[]interface{}{"John Smith", 5},
+				},
+			},
+		},
+		{
+			Tenant: "franchise-b",
+			Report: &servicetitan.Report{ID: 1, Name: "Jobs report", Fields: fields()},
+			Data: &servicetitan.ReportData{
+				// Column order can differ between tenants
+				Fields: []servicetitan.ReportField{
+					{Name: "Jobs", Label: "Jobs", Type: "Number"},
+					{Name: "Name", Label: "Name", Type: "String"},
+				},
+				Data: []interface{}{
+					[]interface{}{9, "Jane Doe"},
+					[]interface{}{2, "John Smith"},
+				},
+			},
+		},
 	}
 }
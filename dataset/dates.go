@@ -0,0 +1,71 @@
+package dataset
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	geckoboardDateFormat     = "2006-01-02"
+	geckoboardDatetimeFormat = time.RFC3339
+)
+
+// dateLayouts are the formats ServiceTitan and local files send dates and
+// datetimes in, the layouts without a zone are in the time location
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"01/02/2006 15:04:05",
+	"01/02/2006",
+}
+
+// ValueError is a row value which couldn't be converted to its field type
+type ValueError struct {
+	Field string
+	Value interface{}
+	Type  string
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("field %q value %q is not a valid %s", e.Field, fmt.Sprint(e.Value), strings.ToLower(e.Type))
+}
+
+// normaliseDate returns the date as YYYY-MM-DD, the date is kept as
+// written rather than moved into the time location so a date with a
+// zone such as midnight in the tenant time zone stays on the same day
+func normaliseDate(value string, loc *time.Location) (string, bool) {
+	t, ok := parseDate(value, loc)
+	if !ok {
+		return "", false
+	}
+
+	return t.Format(geckoboardDateFormat), true
+}
+
+// normaliseDatetime returns the datetime as ISO 8601 in the time location,
+// a datetime without a zone is treated as already in the time location
+func normaliseDatetime(value string, loc *time.Location) (string, bool) {
+	t, ok := parseDate(value, loc)
+	if !ok {
+		return "", false
+	}
+
+	return t.In(loc).Format(geckoboardDatetimeFormat), true
+}
+
+func parseDate(value string, loc *time.Location) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,280 @@
+package httplog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"servicetitan-to-dataset/redact"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBodySize is how much of each body is logged when not set
+const DefaultMaxBodySize = 4096
+
+// redactedHeaders have their values replaced in the logs
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"St-App-Key":          true,
+	"X-Vault-Token":       true,
+}
+
+// redactedBodyFields are the form and json fields whose values are replaced,
+// they are also redacted by name as an encoded value won't match the secret
+var redactedBodyFields = []*regexp.Regexp{
+	regexp.MustCompile(`((?:^|&)(?:client_id|client_secret)=)[^&]*`),
+	regexp.MustCompile(`("(?:access_token|client_secret)"\s*:\s*")[^"]*`),
+}
+
+type Options struct {
+	// Logger defaults to the standard logger
+	Logger *log.Logger
+	// MaxBodySize is the number of bytes of each body that is logged,
+	// the rest is truncated. Zero uses DefaultMaxBodySize and a
+	// negative size doesn't log the bodies
+	MaxBodySize int
+}
+
+// Transport is a http.RoundTripper which logs every request and
+// response with the secrets redacted, for debugging the APIs
+type Transport struct {
+	base    http.RoundTripper
+	logger  *log.Logger
+	maxBody int
+}
+
+// NewTransport returns a transport which logs the requests sent by base,
+// the http.DefaultTransport is used when base is nil
+func NewTransport(base http.RoundTripper, opts Options) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = DefaultMaxBodySize
+	}
+
+	return &Transport{base: base, logger: opts.Logger, maxBody: opts.MaxBodySize}
+}
+
+var (
+	mu      sync.RWMutex
+	enabled *Options
+)
+
+// Enable logs the requests of the transports passed to Wrap, which are only
+// the ServiceTitan and Geckoboard clients. The http.DefaultTransport isn't
+// changed so other requests, such as resolving Vault secrets, aren't logged
+func Enable(opts Options) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if enabled != nil {
+		return
+	}
+
+	enabled = &opts
+}
+
+// Disable stops logging the requests of transports wrapped from now on,
+// transports which are already wrapped keep logging
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	enabled = nil
+}
+
+// Wrap returns a transport logging the requests of rt when logging
+// is enabled, otherwise rt is returned as is
+func Wrap(rt http.RoundTripper) http.RoundTripper {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if enabled == nil {
+		return rt
+	}
+
+	return NewTransport(rt, *enabled)
+}
+
+// Enabled returns whether logging is enabled
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return enabled != nil
+}
+
+// Logf logs a call of a client whose transport can't be wrapped, such as
+// the Geckoboard client, when logging is enabled. The body, such as the
+// data pushed, is logged like a request body
+func Logf(body []byte, format string, args ...interface{}) {
+	mu.RLock()
+	opts := enabled
+	mu.RUnlock()
+
+	if opts == nil {
+		return
+	}
+
+	t := NewTransport(nil, *opts)
+	msg := fmt.Sprintf(format, args...) + "\n"
+	if t.maxBody >= 0 {
+		msg += t.formatBody(body, int64(len(body)))
+	}
+
+	t.log("%s", msg)
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := t.requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.log("--> %s %s\n%s%s", req.Method, req.URL, formatHeaders(req.Header), reqBody)
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	took := time.Since(start).Round(time.Millisecond)
+
+	if err != nil {
+		t.log("<-- %s %s failed after %s: %v", req.Method, req.URL, took, err)
+		return nil, err
+	}
+
+	respBody, err := t.responseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	t.log("<-- %s %s %s (%s)\n%s%s", resp.Status, req.Method, req.URL, took, formatHeaders(resp.Header), respBody)
+	return resp, nil
+}
+
+func (t *Transport) log(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	t.logger.Print(redact.String("DBG: " + strings.TrimRight(msg, "\n")))
+}
+
+// requestBody returns the body to log without consuming the request body
+func (t *Transport) requestBody(req *http.Request) (string, error) {
+	if t.maxBody < 0 || req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+
+		defer body.Close()
+
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return "", err
+		}
+
+		return t.formatBody(b, int64(len(b))), nil
+	}
+
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return t.formatBody(b, int64(len(b))), nil
+}
+
+// responseBody reads ahead the part of the body which is logged, the rest
+// isn't buffered so a large response such as streamed report data is still
+// read as it arrives. The response body is replaced so the caller reads all of it
+func (t *Transport) responseBody(resp *http.Response) (string, error) {
+	if t.maxBody < 0 || resp.Body == nil || resp.Body == http.NoBody {
+		return "", nil
+	}
+
+	// One more byte than is logged tells whether the body is truncated
+	b, err := io.ReadAll(io.LimitReader(resp.Body, int64(t.maxBody)+1))
+	if err != nil {
+		resp.Body.Close()
+		return "", err
+	}
+
+	resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(b), resp.Body), Closer: resp.Body}
+
+	size := resp.ContentLength
+	if size < 0 && len(b) <= t.maxBody {
+		size = int64(len(b))
+	}
+
+	return t.formatBody(b, size), nil
+}
+
+// formatBody returns the body to log, size is the whole body size which
+// is negative when unknown, only the start of a large body is logged
+func (t *Transport) formatBody(b []byte, size int64) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	body := string(b)
+	if len(body) > t.maxBody {
+		body = body[:t.maxBody]
+	}
+
+	for _, re := range redactedBodyFields {
+		body = re.ReplaceAllString(body, "${1}"+redact.Placeholder)
+	}
+
+	switch {
+	case size < 0:
+		return fmt.Sprintf("%s... (truncated)\n", body)
+	case size > int64(t.maxBody):
+		return fmt.Sprintf("%s... (truncated %d of %d bytes)\n", body, size-int64(t.maxBody), size)
+	}
+
+	return body + "\n"
+}
+
+// readCloser reads the logged start of a body followed by the rest
+// of it, closing the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func formatHeaders(h http.Header) string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		value := strings.Join(h[k], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			value = redact.Placeholder
+		}
+
+		fmt.Fprintf(&sb, "%s: %s\n", k, value)
+	}
+
+	return sb.String()
+}
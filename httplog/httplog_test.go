@@ -0,0 +1,196 @@
+package httplog
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTransport_RoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/connect/token" {
+			io.WriteString(w, `{"access_token":"tok_1234","expires_in":900}`)
+			return
+		}
+
+		w.Write(b)
+	}))
+	defer server.Close()
+
+	buildClient := func(maxBody int) (*http.Client, *bytes.Buffer) {
+		buf := &bytes.Buffer{}
+		logger := log.New(buf, "", 0)
+
+		return &http.Client{Transport: NewTransport(nil, Options{Logger: logger, MaxBodySize: maxBody})}, buf
+	}
+
+	t.Run("logs the request and response", func(t *testing.T) {
+		client, buf := buildClient(0)
+
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/data?page=1", strings.NewReader(`{"parameters":[]}`))
+		assert.NilError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		assert.NilError(t, err)
+
+		b, err := io.ReadAll(resp.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"parameters":[]}`)
+
+		out := buf.String()
+		assert.Assert(t, strings.Contains(out, "DBG: --> POST "+server.URL+"/data?page=1\nContent-Type: application/json\n{\"parameters\":[]}\n"), out)
+		assert.Assert(t, strings.Contains(out, "DBG: <-- 200 OK POST "+server.URL+"/data?page=1 ("), out)
+		assert.Assert(t, strings.Contains(out, "Content-Type: application/json\n{\"parameters\":[]}\n"), out)
+	})
+
+	t.Run("redacts the auth headers and secrets in the bodies", func(t *testing.T) {
+		client, buf := buildClient(0)
+
+		form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"cid_123"}, "client_secret": {"s3cr3t+/="}}
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/connect/token", strings.NewReader(form.Encode()))
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer tok_5678")
+		req.Header.Set("ST-App-Key", "app_123")
+		req.Header.Set("X-Vault-Token", "hvs.vault_123")
+		req.SetBasicAuth("gb_api_key", "")
+
+		resp, err := client.Do(req)
+		assert.NilError(t, err)
+
+		b, err := io.ReadAll(resp.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"access_token":"tok_1234","expires_in":900}`)
+
+		out := buf.String()
+		for _, secret := range []string{"tok_5678", "app_123", "Z2JfYXBpX2tleTo", "hvs.vault_123", "cid_123", "s3cr3t", "tok_1234"} {
+			assert.Assert(t, !strings.Contains(out, secret), "%s is in the log:\n%s", secret, out)
+		}
+
+		assert.Assert(t, strings.Contains(out, "Authorization: [REDACTED]\n"), out)
+		assert.Assert(t, strings.Contains(out, "St-App-Key: [REDACTED]\n"), out)
+		assert.Assert(t, strings.Contains(out, "X-Vault-Token: [REDACTED]\n"), out)
+		assert.Assert(t, strings.Contains(out, "client_id=[REDACTED]&client_secret=[REDACTED]&grant_type=client_credentials"), out)
+		assert.Assert(t, strings.Contains(out, `{"access_token":"[REDACTED]","expires_in":900}`), out)
+	})
+
+	t.Run("truncates large bodies", func(t *testing.T) {
+		client, buf := buildClient(10)
+
+		resp, err := client.Post(server.URL, "text/plain", strings.NewReader(strings.Repeat("a", 25)))
+		assert.NilError(t, err)
+
+		b, err := io.ReadAll(resp.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, len(b), 25)
+
+		assert.Assert(t, strings.Contains(buf.String(), "aaaaaaaaaa... (truncated 15 of 25 bytes)\n"), buf.String())
+	})
+
+	t.Run("only reads ahead the logged part of a response", func(t *testing.T) {
+		streamed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Flushing before the end sends the body without a content length
+			io.WriteString(w, strings.Repeat("a", 20))
+			w.(http.Flusher).Flush()
+			io.WriteString(w, strings.Repeat("b", 20))
+		}))
+		defer streamed.Close()
+
+		client, buf := buildClient(10)
+
+		resp, err := client.Get(streamed.URL)
+		assert.NilError(t, err)
+		assert.Equal(t, resp.ContentLength, int64(-1))
+
+		b, err := io.ReadAll(resp.Body)
+		assert.NilError(t, err)
+		assert.NilError(t, resp.Body.Close())
+		assert.Equal(t, string(b), strings.Repeat("a", 20)+strings.Repeat("b", 20))
+
+		assert.Assert(t, strings.Contains(buf.String(), "aaaaaaaaaa... (truncated)\n"), buf.String())
+	})
+
+	t.Run("doesn't log the bodies when the size is negative", func(t *testing.T) {
+		client, buf := buildClient(-1)
+
+		_, err := client.Post(server.URL, "text/plain", strings.NewReader("body"))
+		assert.NilError(t, err)
+
+		assert.Assert(t, !strings.Contains(buf.String(), "body"), buf.String())
+	})
+
+	t.Run("logs the error when the request fails", func(t *testing.T) {
+		client, buf := buildClient(0)
+
+		_, err := client.Get("http://127.0.0.1:1/fail")
+		assert.Assert(t, err != nil)
+
+		assert.Assert(t, strings.Contains(buf.String(), "DBG: <-- GET http://127.0.0.1:1/fail failed after"), buf.String())
+	})
+}
+
+func TestWrap(t *testing.T) {
+	base := &http.Transport{}
+
+	t.Run("returns the transport when not enabled", func(t *testing.T) {
+		assert.Equal(t, Wrap(base), http.RoundTripper(base))
+	})
+
+	t.Run("returns a logging transport when enabled", func(t *testing.T) {
+		defaultTransport := http.DefaultTransport
+		t.Cleanup(func() {
+			http.DefaultTransport = defaultTransport
+			Disable()
+		})
+
+		Enable(Options{MaxBodySize: 10})
+
+		got, ok := Wrap(base).(*Transport)
+		assert.Assert(t, ok)
+		assert.Equal(t, got.base, http.RoundTripper(base))
+		assert.Equal(t, got.maxBody, 10)
+
+		// Only the wrapped transports log, not every request
+		assert.Equal(t, http.DefaultTransport, defaultTransport)
+	})
+}
+
+func TestLogf(t *testing.T) {
+	t.Run("doesn't log when not enabled", func(t *testing.T) {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+		Logf([]byte("{}"), "--> geckoboard %s", "FindOrCreate")
+		assert.Equal(t, buf.String(), "")
+	})
+
+	t.Run("logs the message and truncated body when enabled", func(t *testing.T) {
+		var buf bytes.Buffer
+		Enable(Options{Logger: log.New(&buf, "", 0), MaxBodySize: 4})
+		t.Cleanup(Disable)
+
+		Logf([]byte(`{"data":[]}`), "--> geckoboard %s", "AppendData")
+		assert.Equal(t, buf.String(), "DBG: --> geckoboard AppendData\n{\"da... (truncated 7 of 11 bytes)\n")
+	})
+
+	t.Run("doesn't log the body with a negative body size", func(t *testing.T) {
+		var buf bytes.Buffer
+		Enable(Options{Logger: log.New(&buf, "", 0), MaxBodySize: -1})
+		t.Cleanup(Disable)
+
+		Logf([]byte(`{"data":[]}`), "--> geckoboard %s", "AppendData")
+		assert.Equal(t, buf.String(), "DBG: --> geckoboard AppendData\n")
+	})
+}
@@ -0,0 +1,229 @@
+package keyword
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DateFormat = "2006-01-02"
+
+var (
+	nowSubRegexp          = regexp.MustCompile(`^NOW([-+])(\d+)d?$`)
+	currentMonthDayRegexp = regexp.MustCompile(`CURRENT_MONTH_DAY1`)
+)
+
+type TimeWrapper interface {
+	Now() time.Time
+}
+
+type Time struct {
+	location *time.Location
+}
+
+// NewTime returns the current time in the location, a nil location uses local time
+func NewTime(location *time.Location) Time {
+	return Time{location: location}
+}
+
+func (nt Time) Now() time.Time {
+	if nt.location == nil {
+		return time.Now().Local()
+	}
+
+	return time.Now().UTC().In(nt.location)
+}
+
+// Replacer computes the value of a keyword such as NOW from the value
+type Replacer interface {
+	HasMatched() bool
+	ComputedValue() string
+	SetValue(string)
+}
+
+// Keyword is a keyword which can be used as a report parameter value
+type Keyword struct {
+	// Name identifies the keyword such as NOW
+	Name string
+	// Pattern is the regular expression of values the keyword matches
+	Pattern     string
+	Description string
+	// New returns a replacer for the keyword using the time wrapper for the current time
+	New func(TimeWrapper) Replacer
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = []Keyword{
+		{
+			Name:        "NOW",
+			Pattern:     `^(NOW|NOW[-+]\d+d?)$`,
+			Description: "NOW is today's date, NOW-n or NOW+n is n days before or after today, a d suffix such as NOW-7d is also allowed",
+			New:         func(tw TimeWrapper) Replacer { return &NowReplacer{timeWrapper: tw} },
+		},
+		{
+			Name:        "CURRENT_MONTH_DAY1",
+			Pattern:     currentMonthDayRegexp.String(),
+			Description: "CURRENT_MONTH_DAY1 is the first day of the current month",
+			New:         func(tw TimeWrapper) Replacer { return &CurrentMonthDayReplacer{timeWrapper: tw} },
+		},
+	}
+)
+
+// Register adds a keyword which is matched after the existing keywords,
+// it panics when a keyword with the same name is already registered
+func Register(kw Keyword) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, existing := range registry {
+		if existing.Name == kw.Name {
+			panic(fmt.Sprintf("keyword %q is already registered", kw.Name))
+		}
+	}
+
+	registry = append(registry, kw)
+}
+
+// Registered returns every registered keyword in the order they are matched
+func Registered() []Keyword {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return append([]Keyword{}, registry...)
+}
+
+type NowReplacer struct {
+	value       string
+	timeWrapper TimeWrapper
+}
+
+type CurrentMonthDayReplacer struct {
+	value       string
+	timeWrapper TimeWrapper
+}
+
+// Handler replaces values using the user defined variables, the date
+// functions and every registered keyword, in that order
+type Handler struct {
+	value     string
+	replacer  Replacer
+	replacers []Replacer
+	variables map[string]string
+}
+
+// NewHandler returns a handler for the registered keywords and the variables
+func NewHandler(timeWrapper TimeWrapper, variables map[string]string) *Handler {
+	h := &Handler{variables: variables}
+	h.replacers = []Replacer{
+		&functionReplacer{handler: h},
+		&variableReplacer{handler: h},
+	}
+
+	for _, kw := range Registered() {
+		h.replacers = append(h.replacers, kw.New(timeWrapper))
+	}
+
+	return h
+}
+
+func (n *NowReplacer) HasMatched() bool {
+	return n.value == "NOW" || nowSubRegexp.MatchString(n.value)
+}
+
+func (n *NowReplacer) ComputedValue() string {
+	matches := nowSubRegexp.FindStringSubmatch(n.value)
+
+	if n.value == "NOW" {
+		return n.timeWrapper.Now().Format(DateFormat)
+	}
+
+	if len(matches) != 3 {
+		return n.value
+	}
+
+	parsedNum, _ := strconv.Atoi(matches[2])
+	days := time.Duration(parsedNum*24) * time.Hour
+
+	switch matches[1] {
+	case "-":
+		return n.timeWrapper.Now().Add(-days).Format(DateFormat)
+	default:
+		// Default to adding day
+		return n.timeWrapper.Now().Add(days).Format(DateFormat)
+	}
+}
+
+func (n *NowReplacer) SetValue(value string) {
+	n.value = value
+}
+
+func (c *CurrentMonthDayReplacer) HasMatched() bool {
+	return currentMonthDayRegexp.MatchString(c.value)
+}
+
+func (c *CurrentMonthDayReplacer) ComputedValue() string {
+	if !c.HasMatched() {
+		return c.value
+	}
+
+	// The time wrapper returns the time in the config time location
+	now := c.timeWrapper.Now()
+	pointOfTime := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	return pointOfTime.Format(DateFormat)
+}
+
+func (n *CurrentMonthDayReplacer) SetValue(value string) {
+	n.value = value
+}
+
+func (r *Handler) HasMatched() bool {
+	for _, kr := range r.replacers {
+		kr.SetValue(r.value)
+
+		if kr.HasMatched() {
+			r.replacer = kr
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Handler) ComputedValue() string {
+	return r.replacer.ComputedValue()
+}
+
+func (r *Handler) SetValue(value string) {
+	r.value = value
+}
+
+// IsVariable returns whether the value is the name of a variable
+func (r *Handler) IsVariable(value string) bool {
+	_, ok := r.variables[value]
+	return ok
+}
+
+// ValidateDateValue returns an error when the value of a Date parameter uses an
+// unknown function or variable, such as SEASON_STAR when only SEASON_START exists
+func (r *Handler) ValidateDateValue(value string) error {
+	return validateDateValue(value, r.variables)
+}
+
+// evaluate returns the computed value when it matches
+// a keyword, otherwise the value is returned as is
+func (r *Handler) evaluate(value string) string {
+	// Keep the current value as evaluate is called while computing a value
+	prevValue, prevReplacer := r.value, r.replacer
+	defer func() { r.value, r.replacer = prevValue, prevReplacer }()
+
+	r.SetValue(value)
+	if r.HasMatched() {
+		return r.ComputedValue()
+	}
+
+	return value
+}
@@ -1,20 +1,25 @@
-package processor
+package keyword
 
 import (
+	"regexp"
 	"testing"
 	"time"
 
 	"gotest.tools/v3/assert"
 )
 
-func TestNewKeywordHandler(t *testing.T) {
+func TestNewHandler(t *testing.T) {
 	timeLoc := &time.Location{}
-	got := NewKeywordHandler(timeLoc)
+	got := NewHandler(NewTime(timeLoc), nil)
 
-	assert.Equal(t, len(got.replacers), 2)
+	assert.Equal(t, len(got.replacers), 4)
 
 	for _, r := range got.replacers {
 		switch val := r.(type) {
+		case *functionReplacer:
+			assert.Equal(t, val.handler, got)
+		case *variableReplacer:
+			assert.Equal(t, val.handler, got)
 		case *NowReplacer:
 			tw := val.timeWrapper.(Time)
 			assert.Equal(t, tw.location, timeLoc)
@@ -27,6 +32,27 @@ func TestNewKeywordHandler(t *testing.T) {
 	}
 }
 
+func TestRegistered(t *testing.T) {
+	t.Run("returns patterns matching the keyword values", func(t *testing.T) {
+		kws := Registered()
+		assert.Equal(t, len(kws), 2)
+
+		for _, kw := range kws {
+			assert.Assert(t, kw.Description != "")
+		}
+
+		for _, value := range []string{"NOW", "NOW-7", "NOW+1", "NOW-7d"} {
+			assert.Assert(t, regexp.MustCompile(kws[0].Pattern).MatchString(value), value)
+		}
+
+		for _, value := range []string{"NOW-", "NOW-7x", "TONOW", "start_of_week(NOW-7)"} {
+			assert.Assert(t, !regexp.MustCompile(kws[0].Pattern).MatchString(value), value)
+		}
+
+		assert.Assert(t, regexp.MustCompile(kws[1].Pattern).MatchString("CURRENT_MONTH_DAY1"))
+	})
+}
+
 func TestTimeWrapper_Now(t *testing.T) {
 	t.Run("returns local time", func(t *testing.T) {
 		tm := Time{}
@@ -54,7 +80,7 @@ func TestNowReplacer_HasMatched(t *testing.T) {
 		})
 	}
 
-	badCases := []string{"now", "NOW+", "NOW-", "NOW20"}
+	badCases := []string{"now", "NOW+", "NOW-", "NOW20", "XNOW-7", "NOW-7abc"}
 	for _, in := range badCases {
 		t.Run("returns false when input is "+in, func(t *testing.T) {
 			nr := NowReplacer{value: in}
@@ -78,6 +104,13 @@ func TestNowReplacer_ComputedValue(t *testing.T) {
 		assert.Equal(t, nr.ComputedValue(), "NOW-")
 	})
 
+	t.Run("returns the original value when there is text around the keyword", func(t *testing.T) {
+		for _, value := range []string{"XNOW-7", "NOW-7abc"} {
+			nr := NowReplacer{value: value, timeWrapper: mockTimeWrapper{}}
+			assert.Equal(t, nr.ComputedValue(), value)
+		}
+	})
+
 	t.Run("returns the current date", func(t *testing.T) {
 		nr := NowReplacer{value: "NOW", timeWrapper: mockTimeWrapper{}}
 		assert.Equal(t, nr.ComputedValue(), "2005-02-04")
@@ -135,6 +168,14 @@ func TestCurrentMonthDayReplacer_ComputedValue(t *testing.T) {
 		nr := CurrentMonthDayReplacer{value: "CURRENT_MONTH_DAY1", timeWrapper: mockTimeWrapper{}}
 		assert.Equal(t, nr.ComputedValue(), "2005-02-01")
 	})
+
+	t.Run("returns 1st of the month in the time location", func(t *testing.T) {
+		loc := time.FixedZone("UTC+10", 10*60*60)
+		now := time.Date(2005, 3, 1, 2, 0, 0, 0, loc)
+
+		nr := CurrentMonthDayReplacer{value: "CURRENT_MONTH_DAY1", timeWrapper: mockTimeWrapper{now: now}}
+		assert.Equal(t, nr.ComputedValue(), "2005-03-01")
+	})
 }
 
 type mockTimeWrapper struct {
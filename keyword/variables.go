@@ -0,0 +1,255 @@
+package keyword
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	functionRegexp     = regexp.MustCompile(`^([a-z_][a-z0-9_]*)\((.*)\)$`)
+	variableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// variableLikeRegexp matches date values written like a variable name such
+	// as SEASON_START, which are reported unless there's a variable or keyword
+	variableLikeRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)+$`)
+)
+
+// Function transforms a date such as start_of_week(NOW)
+type Function func(time.Time) time.Time
+
+var (
+	functionsMu sync.RWMutex
+	functions   = map[string]Function{
+		"start_of_week": func(t time.Time) time.Time {
+			// Weeks start on a Monday
+			return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		},
+		"start_of_month": func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		},
+		"start_of_year": func(t time.Time) time.Time {
+			return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		},
+	}
+)
+
+// RegisterFunction adds a date function which can be used in
+// parameter values and variables, such as name(NOW-7d)
+func RegisterFunction(name string, fn Function) {
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+
+	if _, ok := functions[name]; ok {
+		panic(fmt.Sprintf("function %q is already registered", name))
+	}
+
+	functions[name] = fn
+}
+
+// FunctionNames returns the names of the registered functions in name order
+func FunctionNames() []string {
+	functionsMu.RLock()
+	defer functionsMu.RUnlock()
+
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func lookupFunction(name string) (Function, bool) {
+	functionsMu.RLock()
+	defer functionsMu.RUnlock()
+
+	fn, ok := functions[name]
+	return fn, ok
+}
+
+// functionReplacer computes a date function with the argument
+// evaluated as a keyword, variable or a YYYY-MM-DD date
+type functionReplacer struct {
+	value   string
+	handler *Handler
+}
+
+func (f *functionReplacer) HasMatched() bool {
+	matches := functionRegexp.FindStringSubmatch(f.value)
+	if matches == nil {
+		return false
+	}
+
+	_, ok := lookupFunction(matches[1])
+	return ok
+}
+
+func (f *functionReplacer) ComputedValue() string {
+	value := f.value
+
+	matches := functionRegexp.FindStringSubmatch(value)
+	if matches == nil {
+		return value
+	}
+
+	fn, ok := lookupFunction(matches[1])
+	if !ok {
+		return value
+	}
+
+	date, err := time.Parse(DateFormat, f.handler.evaluate(strings.TrimSpace(matches[2])))
+	if err != nil {
+		return value
+	}
+
+	return fn(date).Format(DateFormat)
+}
+
+func (f *functionReplacer) SetValue(value string) {
+	f.value = value
+}
+
+// variableReplacer replaces the name of a variable with its value,
+// which is itself evaluated so it can use keywords and functions
+type variableReplacer struct {
+	value   string
+	handler *Handler
+}
+
+func (v *variableReplacer) HasMatched() bool {
+	return v.handler.IsVariable(v.value)
+}
+
+func (v *variableReplacer) ComputedValue() string {
+	value, ok := v.handler.variables[v.value]
+	if !ok {
+		return v.value
+	}
+
+	return v.handler.evaluate(value)
+}
+
+func (v *variableReplacer) SetValue(value string) {
+	v.value = value
+}
+
+// ValidateVariables returns an error for each variable with an invalid name,
+// that refers to itself or uses an unknown function, variable or keyword
+func ValidateVariables(variables map[string]string) []string {
+	var msgs []string
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !variableNameRegexp.MatchString(name) {
+			msgs = append(msgs, fmt.Sprintf("variable %q must only contain letters, numbers and underscores", name))
+			continue
+		}
+
+		if matchesKeyword(name) {
+			msgs = append(msgs, fmt.Sprintf("variable %q has the same name as a keyword", name))
+			continue
+		}
+
+		if err := validateExpression(variables[name], variables, []string{name}, false); err != nil {
+			msgs = append(msgs, fmt.Sprintf("variable %q %v", name, err))
+		}
+	}
+
+	return msgs
+}
+
+// ValidateValue returns an error when the value uses an unknown
+// function or the function argument isn't a known variable or keyword
+func ValidateValue(value string, variables map[string]string) error {
+	return validateExpression(value, variables, nil, false)
+}
+
+// validateDateValue returns an error when the value of a Date parameter, or
+// the variable it refers to, is written like a variable name but isn't one.
+// Other parameters can have values such as JOB_COMPLETED so aren't checked
+func validateDateValue(value string, variables map[string]string) error {
+	if err := ValidateValue(value, variables); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for !seen[value] {
+		next, ok := variables[value]
+		if !ok {
+			break
+		}
+
+		seen[value] = true
+		value = next
+	}
+
+	if variableLikeRegexp.MatchString(value) && !matchesKeyword(value) {
+		return fmt.Errorf("uses unknown variable %q", value)
+	}
+
+	return nil
+}
+
+// validateExpression checks the expression refers to known names, chain is
+// the variables being resolved to catch variables which refer to themselves.
+// A function argument must resolve to a date, otherwise any value is allowed
+func validateExpression(expr string, variables map[string]string, chain []string, isArg bool) error {
+	if matches := functionRegexp.FindStringSubmatch(expr); matches != nil {
+		arg := strings.TrimSpace(matches[2])
+		if _, ok := lookupFunction(matches[1]); ok {
+			return validateExpression(arg, variables, chain, true)
+		}
+
+		// An unknown function is a plain value such as foo(bar), unless
+		// the argument is a date name so it must be a misspelt function
+		if _, ok := variables[arg]; ok || matchesKeyword(arg) {
+			return fmt.Errorf("uses unknown function %q, valid functions are %q", matches[1], FunctionNames())
+		}
+
+		return nil
+	}
+
+	if value, ok := variables[expr]; ok {
+		for _, prev := range chain {
+			if prev == expr {
+				return fmt.Errorf("refers to itself through %s", strings.Join(append(chain, expr), " -> "))
+			}
+		}
+
+		return validateExpression(value, variables, append(chain, expr), isArg)
+	}
+
+	if !isArg || matchesKeyword(expr) {
+		return nil
+	}
+
+	if _, err := time.Parse(DateFormat, expr); err != nil {
+		return fmt.Errorf("uses unknown name %q, which must be a variable, keyword or a YYYY-MM-DD date", expr)
+	}
+
+	return nil
+}
+
+// matchesKeyword returns whether any registered keyword matches the value
+func matchesKeyword(value string) bool {
+	for _, kw := range Registered() {
+		r := kw.New(NewTime(nil))
+		r.SetValue(value)
+
+		if r.HasMatched() {
+			return true
+		}
+	}
+
+	return false
+}
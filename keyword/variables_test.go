@@ -0,0 +1,174 @@
+package keyword
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestHandler_Variables(t *testing.T) {
+	// A Wednesday
+	tw := mockTimeWrapper{now: time.Date(2026, 3, 18, 9, 0, 0, 0, time.UTC)}
+
+	h := NewHandler(tw, map[string]string{
+		"SEASON_START":    "2026-03-01",
+		"LAST_WEEK_START": "start_of_week(NOW-7d)",
+		"SEASON_WEEK":     "start_of_week(SEASON_START)",
+		"REGION":          "North",
+	})
+
+	specs := []struct {
+		in   string
+		want string
+	}{
+		{"SEASON_START", "2026-03-01"},
+		{"LAST_WEEK_START", "2026-03-09"},
+		{"SEASON_WEEK", "2026-02-23"},
+		{"REGION", "North"},
+		{"start_of_month(NOW)", "2026-03-01"},
+		{"start_of_year(SEASON_START)", "2026-01-01"},
+		{"start_of_week(2026-03-16)", "2026-03-16"},
+		{"NOW-1d", "2026-03-17"},
+	}
+
+	for _, spec := range specs {
+		t.Run("returns computed value for "+spec.in, func(t *testing.T) {
+			h.SetValue(spec.in)
+			assert.Assert(t, h.HasMatched())
+			assert.Equal(t, h.ComputedValue(), spec.want)
+		})
+	}
+
+	t.Run("doesn't match unknown values", func(t *testing.T) {
+		for _, in := range []string{"OTHER", "unknown_fn(NOW)", ""} {
+			h.SetValue(in)
+			assert.Assert(t, !h.HasMatched(), in)
+		}
+	})
+
+	t.Run("returns whether the value is a variable", func(t *testing.T) {
+		assert.Assert(t, h.IsVariable("REGION"))
+		assert.Assert(t, !h.IsVariable("NOW"))
+	})
+}
+
+func TestRegister(t *testing.T) {
+	defer func(prev []Keyword) { registry = prev }(Registered())
+
+	Register(Keyword{
+		Name:    "TOMORROW",
+		Pattern: "^TOMORROW$",
+		New:     func(tw TimeWrapper) Replacer { return &NowReplacer{timeWrapper: tw} },
+	})
+
+	t.Run("adds the keyword to new handlers", func(t *testing.T) {
+		h := NewHandler(mockTimeWrapper{}, nil)
+		assert.Equal(t, len(h.replacers), 5)
+	})
+
+	t.Run("panics when the keyword is already registered", func(t *testing.T) {
+		defer func() {
+			assert.Equal(t, recover(), `keyword "NOW" is already registered`)
+		}()
+
+		Register(Keyword{Name: "NOW"})
+	})
+}
+
+func TestRegisterFunction(t *testing.T) {
+	defer func() {
+		functionsMu.Lock()
+		delete(functions, "next_day")
+		functionsMu.Unlock()
+	}()
+
+	RegisterFunction("next_day", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) })
+
+	h := NewHandler(mockTimeWrapper{}, nil)
+	h.SetValue("next_day(NOW)")
+	assert.Assert(t, h.HasMatched())
+	assert.Equal(t, h.ComputedValue(), "2005-02-05")
+	assert.DeepEqual(t, FunctionNames(), []string{"next_day", "start_of_month", "start_of_week", "start_of_year"})
+}
+
+func TestValidateVariables(t *testing.T) {
+	t.Run("returns no errors for valid variables", func(t *testing.T) {
+		msgs := ValidateVariables(map[string]string{
+			"SEASON_START":    "2026-03-01",
+			"LAST_WEEK_START": "start_of_week(NOW-7d)",
+			"SEASON_WEEK":     "start_of_week(SEASON_START)",
+			"REGION":          "North",
+		})
+
+		assert.Equal(t, len(msgs), 0)
+	})
+
+	t.Run("returns errors for cycles and unknown names", func(t *testing.T) {
+		msgs := ValidateVariables(map[string]string{
+			"A":        "B",
+			"B":        "start_of_week(A)",
+			"BAD FN":   "NOW",
+			"NOW":      "2026-01-01",
+			"UNKNOWN":  "start_of_week(SEASON)",
+			"WRONG_FN": "start_of_wek(NOW)",
+		})
+
+		assert.DeepEqual(t, msgs, []string{
+			`variable "A" refers to itself through A -> B -> A`,
+			`variable "B" refers to itself through B -> A -> B`,
+			`variable "BAD FN" must only contain letters, numbers and underscores`,
+			`variable "NOW" has the same name as a keyword`,
+			`variable "UNKNOWN" uses unknown name "SEASON", which must be a variable, keyword or a YYYY-MM-DD date`,
+			`variable "WRONG_FN" uses unknown function "start_of_wek", valid functions are ["start_of_month" "start_of_week" "start_of_year"]`,
+		})
+	})
+}
+
+func TestValidateValue(t *testing.T) {
+	vars := map[string]string{"SEASON_START": "2026-03-01"}
+
+	t.Run("allows plain values, keywords and known names", func(t *testing.T) {
+		for _, in := range []string{"Install", "NOW-1", "CURRENT_MONTH_DAY1", "SEASON_START", "start_of_week(SEASON_START)", "foo(bar)", "REGION"} {
+			assert.NilError(t, ValidateValue(in, vars), in)
+		}
+	})
+
+	t.Run("returns error for unknown names within functions", func(t *testing.T) {
+		assert.Error(t, ValidateValue("start_of_month(SEASON)", vars), `uses unknown name "SEASON", which must be a variable, keyword or a YYYY-MM-DD date`)
+	})
+
+	t.Run("allows upper case values which aren't variables", func(t *testing.T) {
+		assert.NilError(t, ValidateValue("JOB_COMPLETED", vars))
+	})
+
+	t.Run("returns error for an unknown function of a date name", func(t *testing.T) {
+		assert.Error(t, ValidateValue("start_of_wek(SEASON_START)", vars), `uses unknown function "start_of_wek", valid functions are ["start_of_month" "start_of_week" "start_of_year"]`)
+	})
+}
+
+func TestValidateDateValue(t *testing.T) {
+	vars := map[string]string{
+		"SEASON_START": "2026-03-01",
+		"SEASON":       "SEASON_START",
+		"TYPO":         "SEASON_STAR",
+	}
+
+	t.Run("allows keywords, dates and known variables", func(t *testing.T) {
+		for _, in := range []string{"NOW-7", "CURRENT_MONTH_DAY1", "2026-01-01", "SEASON_START", "SEASON", "start_of_week(SEASON)"} {
+			assert.NilError(t, validateDateValue(in, vars), in)
+		}
+	})
+
+	t.Run("returns error for an unknown variable", func(t *testing.T) {
+		assert.Error(t, validateDateValue("SEASON_STAR", vars), `uses unknown variable "SEASON_STAR"`)
+	})
+
+	t.Run("returns error for a variable of an unknown variable", func(t *testing.T) {
+		assert.Error(t, validateDateValue("TYPO", vars), `uses unknown variable "SEASON_STAR"`)
+	})
+
+	t.Run("returns error for an unknown name within a function", func(t *testing.T) {
+		assert.Error(t, validateDateValue("start_of_month(SEASON_STAR)", vars), `uses unknown name "SEASON_STAR", which must be a variable, keyword or a YYYY-MM-DD date`)
+	})
+}
@@ -2,133 +2,502 @@ package processor
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"servicetitan-to-dataset/config"
 	"servicetitan-to-dataset/dataset"
+	"servicetitan-to-dataset/httplog"
+	"servicetitan-to-dataset/keyword"
 	"servicetitan-to-dataset/servicetitan"
+	"servicetitan-to-dataset/source"
+	"servicetitan-to-dataset/tracing"
 	"strings"
 	"time"
 
 	"github.com/jnormington/geckoboard"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("processor")
+
+// pushBatchSize is the most rows geckoboard accepts in a single request
+const pushBatchSize = 500
+
 type ReportProcessor struct {
 	maxDatasetRecords int
 	config            *config.Config
 	timeNow           func() time.Time
 
-	serviceTitanClient *servicetitan.Client
-	geckoboardClient   *geckoboard.Client
-	keywordReplacer    KeywordReplacer
+	tenants          []tenantClient
+	geckoboardClient *geckoboard.Client
+	keywordReplacer  *keyword.Handler
+	// exportState defaults to the files in the config state_dir
+	exportState source.ExportState
 }
 
-func New(cfg *config.Config) ReportProcessor {
-	c, _ := servicetitan.New(cfg.ServiceTitan)
-	gb := geckoboard.New("https://api.geckoboard.com", cfg.Geckoboard.APIKey)
+type tenantClient struct {
+	connection config.ServiceTitan
+	client     *servicetitan.Client
+}
 
-	return ReportProcessor{
-		maxDatasetRecords:  5000,
-		config:             cfg,
-		serviceTitanClient: c,
-		geckoboardClient:   gb,
-		keywordReplacer:    NewKeywordHandler(cfg.TimeLoc()),
+// New returns a processor which fetches reports from the servicetitan
+// tenant of each connection and pushes to the geckoboard destination,
+// multiple connections are used by roll-up entries
+func New(cfg *config.Config, dest config.Geckoboard, conns ...config.ServiceTitan) (ReportProcessor, error) {
+	return NewWithClients(cfg, dest, servicetitan.NewPool(nil), conns...)
+}
+
+// NewWithClients returns a processor like New which uses the clients
+// from the pool, so the session of each tenant is shared between entries
+func NewWithClients(cfg *config.Config, dest config.Geckoboard, clients *servicetitan.Pool, conns ...config.ServiceTitan) (ReportProcessor, error) {
+	gb := newGeckoboardClient(dest)
+
+	tenants := []tenantClient{}
+	for _, conn := range conns {
+		c, err := clients.Client(conn)
+		if err != nil {
+			return ReportProcessor{}, fmt.Errorf("servicetitan connection %q: %w", conn.Label(), err)
+		}
+
+		tenants = append(tenants, tenantClient{connection: conn, client: c})
 	}
+
+	return ReportProcessor{
+		maxDatasetRecords: 5000,
+		config:            cfg,
+		tenants:           tenants,
+		geckoboardClient:  gb,
+		keywordReplacer:   keyword.NewHandler(keyword.NewTime(cfg.TimeLoc()), cfg.Variables),
+	}, nil
 }
 
-func (r ReportProcessor) Process(ctx context.Context, entry config.Entry) error {
-	report, err := r.serviceTitanClient.ReportService.GetReport(ctx, entry.Report.CategoryID, entry.Report.ID)
+func (r ReportProcessor) Process(ctx context.Context, entry config.Entry) (err error) {
+	tenants := []string{}
+	for _, tenant := range r.tenants {
+		tenants = append(tenants, tenant.connection.Label())
+	}
+
+	ctx, span := tracer.Start(ctx, "processor.Process", trace.WithAttributes(
+		attribute.String("entry.category_id", entry.Report.CategoryID),
+		attribute.String("entry.report_id", entry.Report.ID),
+		attribute.String("entry.dataset_type", entry.Dataset.Type),
+		attribute.StringSlice("entry.tenants", tenants),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	switch {
+	case entry.IsExport():
+		span.SetAttributes(attribute.String("entry.export", entry.Export.Resource))
+	case entry.IsFile():
+		span.SetAttributes(attribute.String("entry.file", entry.File.Path))
+	}
+
+	sources, err := r.entrySources(entry)
 	if err != nil {
 		return err
 	}
 
-	data, err := r.fetchReportData(ctx, report, entry)
+	reports := []dataset.TenantReport{}
+	tables := []*source.Table{}
+
+	for _, src := range sources {
+		table, err := src.source.Open(ctx)
+		if err != nil {
+			return r.sourceError(entry, src, err)
+		}
+
+		report, data := table.Report()
+		reports = append(reports, dataset.TenantReport{
+			Tenant: src.tenant,
+			Report: report,
+			Data:   data,
+		})
+		tables = append(tables, table)
+	}
+
+	schema, builder, err := r.build(ctx, entry, reports)
 	if err != nil {
 		return err
 	}
 
-	builder := dataset.NewDatasetBuilder(dataset.BuilderConfig{
-		Report:           report,
-		Data:             data,
-		DatasetOverrides: entry.Dataset,
+	span.SetAttributes(attribute.String("geckoboard.dataset", schema.Name))
+
+	if err := r.findOrCreate(ctx, schema); err != nil {
+		return err
+	}
+
+	rows, err := r.pushRows(ctx, entry, schema, func(fn func(geckoboard.DataRow) error) error {
+		for idx, src := range sources {
+			fields := tables[idx].Fields
+			num := 0
+			full := false
+
+			err := src.source.Rows(ctx, func(row []interface{}) error {
+				num++
+
+				gr, valueErrs := builder.BuildRow(fields, row, src.tenant)
+				logValueErrors(tenantLabel(tenants, src.tenant), num, valueErrs, gr == nil)
+
+				if gr == nil {
+					return nil
+				}
+
+				err := fn(gr)
+				full = errors.Is(err, source.ErrStop)
+				return err
+			})
+			if err != nil {
+				return r.sourceError(entry, src, err)
+			}
+
+			// A replace dataset has all the rows it can take, so the
+			// rate limited report data of the other tenants isn't fetched
+			if full {
+				return nil
+			}
+		}
+
+		return nil
 	})
 
-	schema := builder.BuildSchema()
-	if err := r.geckoboardClient.DatasetService.FindOrCreate(ctx, schema); err != nil {
+	span.SetAttributes(attribute.Int("dataset.rows", rows))
+
+	if err != nil {
 		return err
 	}
 
-	if strings.ToLower(entry.Dataset.Type) == "append" {
-		return r.geckoboardClient.DatasetService.AppendData(ctx, schema, builder.BuildData())
+	// Sources are only committed once the rows are pushed,
+	// so rows which failed to push are fetched again
+	for _, src := range sources {
+		if c, ok := src.source.(source.Committer); ok {
+			if err := c.Commit(); err != nil {
+				return fmt.Errorf("unable to save the source state: %w", err)
+			}
+		}
 	}
 
-	return r.geckoboardClient.DatasetService.ReplaceData(ctx, schema, builder.BuildData())
+	return nil
 }
 
-func (r *ReportProcessor) fetchReportData(ctx context.Context, report *servicetitan.Report, entry config.Entry) (*servicetitan.ReportData, error) {
-	reportParams, err := r.buildReportParameters(report, entry)
-	if err != nil {
-		return nil, err
+// logValueErrors logs each value of the row which couldn't be converted,
+// the row is still pushed without the value unless it was skipped
+func logValueErrors(tenant string, num int, errs []*dataset.ValueError, skipped bool) {
+	for _, err := range errs {
+		if skipped {
+			log.Printf("ERR: [%s] Row %d %v, skipping the row as the field is required", tenant, num, err)
+		} else {
+			log.Printf("ERR: [%s] Row %d %v, pushing the row without the value", tenant, num, err)
+		}
+	}
+}
+
+// tenantLabel returns the tenant of a roll-up source, or the tenants of the entry
+func tenantLabel(tenants []string, tenant string) string {
+	if tenant != "" {
+		return tenant
 	}
 
-	reportOpts := servicetitan.ReportDataRequest{
-		CategoryID: entry.Report.CategoryID,
-		ReportID:   entry.Report.ID,
-		Parameters: reportParams,
+	return strings.Join(tenants, ",")
+}
+
+// sourceError adds the tenant to the error of a roll-up source
+func (r ReportProcessor) sourceError(entry config.Entry, src tenantSource, err error) error {
+	if entry.IsRollUp() {
+		return fmt.Errorf("tenant %q: %w", src.tenant, err)
 	}
 
-	reportData := &servicetitan.ReportData{}
+	return err
+}
 
-	// We can only fetch a single page - with a rate limit of 1 per 5 minutes
-	pagination := &servicetitan.PaginationOptions{Page: 1, PageSize: 5000}
-	resp, err := r.serviceTitanClient.ReportService.GetReportData(ctx, reportOpts, pagination)
+// build returns the dataset schema and the builder of the rows from the reports
+func (r ReportProcessor) build(ctx context.Context, entry config.Entry, reports []dataset.TenantReport) (_ *geckoboard.Dataset, _ *dataset.DatasetBuilder, err error) {
+	_, span := tracer.Start(ctx, "dataset.Build")
+	defer func() { tracing.End(span, err) }()
+
+	builder, err := r.buildDataset(entry, reports)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	reportData.Data = append(reportData.Data, resp.Data...)
-	if reportData.Fields == nil {
-		reportData.Fields = resp.Fields
-	}
+	schema := builder.BuildSchema()
+	span.SetAttributes(attribute.Int("dataset.fields", len(schema.Fields)))
+
+	return schema, builder, nil
+}
+
+func (r ReportProcessor) findOrCreate(ctx context.Context, schema *geckoboard.Dataset) (err error) {
+	ctx, span := tracer.Start(ctx, "geckoboard.FindOrCreate", trace.WithAttributes(
+		attribute.String("geckoboard.dataset", schema.Name),
+	))
+	defer func() { endGeckoboardSpan(span, err) }()
 
-	return reportData, nil
+	return r.geckoboardClient.DatasetService.FindOrCreate(ctx, schema)
 }
 
-// Build the parameters from the config to servicetitan compatible parameters.
-// This also supports special NOW and NOW-n keywords for date fields - which if
-// the fields are of type Date will be replaced with the current time and current time -n
-func (r *ReportProcessor) buildReportParameters(report *servicetitan.Report, ent config.Entry) ([]servicetitan.DataRequestParamters, error) {
-	params := []servicetitan.DataRequestParamters{}
+// pushRows pushes the rows as they're read so only a few batches of rows are held
+// in memory. Append datasets are pushed a batch at a time and replace datasets
+// keep the first batch, as geckoboard only replaces with up to a batch of rows.
+// The rows read are returned, which for a replace dataset is at most a batch
+func (r ReportProcessor) pushRows(ctx context.Context, entry config.Entry, schema *geckoboard.Dataset, read func(func(geckoboard.DataRow) error) error) (int, error) {
+	isAppend := strings.ToLower(entry.Dataset.Type) == "append"
+	batch := make(geckoboard.Data, 0, pushBatchSize)
+	rows := 0
 
-	for _, p := range ent.Report.Parameters {
-		param := r.lookupParameter(report, p.Name)
+	pusher := startBatchPusher(ctx, func(ctx context.Context, data geckoboard.Data) error {
+		return r.push(ctx, entry, schema, data)
+	})
 
-		if param == nil {
-			return nil, fmt.Errorf("invalid param %q for report %v", p.Name, report.ID)
+	err := read(func(row geckoboard.DataRow) error {
+		batch = append(batch, row)
+		rows++
+
+		if len(batch) < pushBatchSize {
+			return nil
 		}
 
-		value := p.Value
+		if !isAppend {
+			return source.ErrStop
+		}
+
+		if err := pusher.Push(batch); err != nil {
+			return err
+		}
+
+		batch = make(geckoboard.Data, 0, pushBatchSize)
+		return nil
+	})
+
+	// Replace always pushes so a report without any rows
+	// empties the dataset, but appending nothing is skipped
+	if err == nil && (!isAppend || len(batch) > 0) {
+		err = pusher.Push(batch)
+	}
+
+	// The push error is returned rather than the error stopping the read
+	if pushErr := pusher.Wait(); pushErr != nil && (err == nil || errors.Is(err, errPushFailed)) {
+		err = pushErr
+	}
+
+	return rows, err
+}
+
+// pushQueueBatches is how many batches can wait to be pushed, which is more
+// than a page of report data so reading a report isn't held up by geckoboard
+const pushQueueBatches = 20
+
+// errPushFailed stops reading the rows once a batch failed to push
+var errPushFailed = errors.New("unable to push a batch of rows")
+
+// batchPusher pushes the batches on another goroutine. The rows are read from
+// the report data response as they arrive, and waiting on geckoboard while
+// reading would count towards the timeout of the servicetitan request
+type batchPusher struct {
+	batches chan geckoboard.Data
+	failed  chan struct{}
+	done    chan struct{}
+	err     error
+}
+
+func startBatchPusher(ctx context.Context, push func(context.Context, geckoboard.Data) error) *batchPusher {
+	p := &batchPusher{
+		batches: make(chan geckoboard.Data, pushQueueBatches),
+		failed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
 
-		if param.DataType == "Date" {
-			val, _ := value.(string)
-			r.keywordReplacer.SetValue(val)
+	go func() {
+		defer close(p.done)
+
+		// The batches after a failed push are dropped
+		for data := range p.batches {
+			if p.err != nil {
+				continue
+			}
 
-			if r.keywordReplacer.HasMatched() {
-				value = r.keywordReplacer.ComputedValue()
+			if p.err = push(ctx, data); p.err != nil {
+				close(p.failed)
 			}
 		}
+	}()
 
-		params = append(params, servicetitan.DataRequestParamters{Name: p.Name, Value: value})
+	return p
+}
+
+// Push queues the batch to be pushed, which isn't changed afterwards.
+// It returns errPushFailed once a batch has failed to push
+func (p *batchPusher) Push(data geckoboard.Data) error {
+	select {
+	case <-p.failed:
+		return errPushFailed
+	default:
 	}
 
-	return params, nil
+	select {
+	case p.batches <- data:
+		return nil
+	case <-p.failed:
+		return errPushFailed
+	}
 }
 
-func (r *ReportProcessor) lookupParameter(report *servicetitan.Report, key string) *servicetitan.ReportParameter {
-	for _, p := range report.Parameters {
-		if p.Name == key {
-			return &p
-		}
+// Wait returns once the queued batches are pushed with the error of the failed push
+func (p *batchPusher) Wait() error {
+	close(p.batches)
+	<-p.done
+
+	return p.err
+}
+
+func (r ReportProcessor) push(ctx context.Context, entry config.Entry, schema *geckoboard.Dataset, data geckoboard.Data) (err error) {
+	name := "geckoboard.ReplaceData"
+	if strings.ToLower(entry.Dataset.Type) == "append" {
+		name = "geckoboard.AppendData"
+	}
+
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("geckoboard.dataset", schema.Name),
+		attribute.Int("geckoboard.records", len(data)),
+	))
+	defer func() { endGeckoboardSpan(span, err) }()
+
+	if strings.ToLower(entry.Dataset.Type) == "append" {
+		return r.geckoboardClient.DatasetService.AppendData(ctx, schema, data)
+	}
+
+	return r.geckoboardClient.DatasetService.ReplaceData(ctx, schema, data)
+}
+
+// newGeckoboardClient returns the client of the destination, which logs its calls
+// when --debug-http is enabled. The geckoboard client doesn't allow setting its
+// http transport, so the dataset calls are logged rather than the requests
+func newGeckoboardClient(dest config.Geckoboard) *geckoboard.Client {
+	gb := geckoboard.New(dest.BaseURL(), dest.APIKey)
+
+	if httplog.Enabled() {
+		gb.DatasetService = loggingDatasetService{DatasetService: gb.DatasetService}
+	}
+
+	return gb
+}
+
+// loggingDatasetService logs each geckoboard dataset call with the dataset or data sent
+type loggingDatasetService struct {
+	geckoboard.DatasetService
+}
+
+func (l loggingDatasetService) FindOrCreate(ctx context.Context, dataset *geckoboard.Dataset) error {
+	return logGeckoboardCall("FindOrCreate", dataset, dataset, func() error {
+		return l.DatasetService.FindOrCreate(ctx, dataset)
+	})
+}
+
+func (l loggingDatasetService) AppendData(ctx context.Context, dataset *geckoboard.Dataset, data geckoboard.Data) error {
+	return logGeckoboardCall("AppendData", dataset, geckoboard.DataPayload{Data: data}, func() error {
+		return l.DatasetService.AppendData(ctx, dataset, data)
+	})
+}
+
+func (l loggingDatasetService) ReplaceData(ctx context.Context, dataset *geckoboard.Dataset, data geckoboard.Data) error {
+	return logGeckoboardCall("ReplaceData", dataset, geckoboard.DataPayload{Data: data}, func() error {
+		return l.DatasetService.ReplaceData(ctx, dataset, data)
+	})
+}
+
+func logGeckoboardCall(method string, dataset *geckoboard.Dataset, body interface{}, call func() error) error {
+	b, _ := json.Marshal(body)
+	httplog.Logf(b, "--> geckoboard %s %s", method, dataset.Name)
+
+	start := time.Now()
+	err := call()
+	took := time.Since(start).Round(time.Millisecond)
+
+	if err != nil {
+		httplog.Logf(nil, "<-- geckoboard %s %s failed after %s: %v", method, dataset.Name, took, err)
+		return err
 	}
 
+	httplog.Logf(nil, "<-- geckoboard %s %s (%s)", method, dataset.Name, took)
 	return nil
 }
+
+// endGeckoboardSpan adds the status code of a geckoboard error to the span
+func endGeckoboardSpan(span trace.Span, err error) {
+	var gbErr *geckoboard.Error
+	if errors.As(err, &gbErr) {
+		span.SetAttributes(attribute.Int("http.status_code", gbErr.StatusCode))
+	}
+
+	tracing.End(span, err)
+}
+
+// tenantSource is the source of an entry for one tenant
+type tenantSource struct {
+	tenant string
+	source source.Source
+}
+
+// entrySources returns the source of the entry for each tenant, a file
+// isn't fetched from a tenant so it has a single source
+func (r ReportProcessor) entrySources(entry config.Entry) ([]tenantSource, error) {
+	if entry.IsFile() {
+		return []tenantSource{{source: source.NewFile(*entry.File)}}, nil
+	}
+
+	var state source.ExportState
+	if entry.IsExport() {
+		var err error
+		if state, err = r.loadExportState(); err != nil {
+			return nil, err
+		}
+	}
+
+	sources := []tenantSource{}
+	for _, tenant := range r.tenants {
+		var src source.Source = source.NewReport(tenant.client.ReportService, entry.Report, r.keywordReplacer)
+
+		if entry.IsExport() {
+			key := exportStateKey(tenant.connection, entry)
+			src = source.NewExport(tenant.client.ExportService, entry.Export, state, key, r.maxDatasetRecords)
+		}
+
+		sources = append(sources, tenantSource{tenant: tenant.connection.Name, source: src})
+	}
+
+	return sources, nil
+}
+
+// loadExportState returns the state of the export feeds
+func (r ReportProcessor) loadExportState() (source.ExportState, error) {
+	if r.exportState != nil {
+		return r.exportState, nil
+	}
+
+	return source.NewFileExportState(r.config.ExportStateDir())
+}
+
+// exportStateKey identifies the continuation token of the tenant export, the
+// same export pushed to different datasets keeps a token for each dataset
+func exportStateKey(conn config.ServiceTitan, entry config.Entry) string {
+	return conn.Label() + "|" + entry.DatasetKey()
+}
+
+func (r ReportProcessor) buildDataset(entry config.Entry, reports []dataset.TenantReport) (*dataset.DatasetBuilder, error) {
+	if entry.IsRollUp() {
+		return dataset.NewRollUpDatasetBuilder(entry.Dataset, r.config.TimeLoc(), reports)
+	}
+
+	if len(reports) != 1 {
+		return nil, fmt.Errorf("expected a single tenant report but got %d", len(reports))
+	}
+
+	return dataset.NewDatasetBuilder(dataset.BuilderConfig{
+		Report:           reports[0].Report,
+		Data:             reports[0].Data,
+		DatasetOverrides: entry.Dataset,
+		Tenant:           reports[0].Tenant,
+		Location:         r.config.TimeLoc(),
+	}), nil
+}
@@ -1,14 +1,30 @@
 package processor
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
 	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/httplog"
+	"servicetitan-to-dataset/keyword"
 	"servicetitan-to-dataset/servicetitan"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/jnormington/geckoboard"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	"gotest.tools/v3/assert"
 )
 
@@ -18,14 +34,104 @@ func TestNewProcessor(t *testing.T) {
 		Geckoboard:   config.Geckoboard{},
 	}
 
-	out := New(cfg)
+	out, err := New(cfg, cfg.Geckoboard, cfg.ServiceTitan)
+	assert.NilError(t, err)
 
 	assert.Equal(t, out.maxDatasetRecords, 5000)
 	assert.Equal(t, out.config, cfg)
-	assert.Assert(t, out.serviceTitanClient != nil)
+	assert.Equal(t, len(out.tenants), 1)
+	assert.Assert(t, out.tenants[0].client != nil)
 	assert.Assert(t, out.geckoboardClient != nil)
 }
 
+func TestNewWithClients(t *testing.T) {
+	cfg := &config.Config{}
+	clients := servicetitan.NewPool(nil)
+
+	a, err := NewWithClients(cfg, cfg.Geckoboard, clients, config.ServiceTitan{Name: "franchise-a"})
+	assert.NilError(t, err)
+	b, err := NewWithClients(cfg, cfg.Geckoboard, clients, config.ServiceTitan{Name: "franchise-a"}, config.ServiceTitan{Name: "franchise-b"})
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(b.tenants), 2)
+	assert.Assert(t, a.tenants[0].client == b.tenants[0].client)
+	assert.Assert(t, b.tenants[0].client != b.tenants[1].client)
+}
+
+func TestNewGeckoboardClient(t *testing.T) {
+	t.Run("doesn't log the calls without --debug-http", func(t *testing.T) {
+		gb := newGeckoboardClient(config.Geckoboard{APIKey: "gb_123"})
+
+		_, ok := gb.DatasetService.(loggingDatasetService)
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("logs the calls with --debug-http", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		}))
+		defer server.Close()
+
+		var logs strings.Builder
+		httplog.Enable(httplog.Options{Logger: log.New(&logs, "", 0)})
+		t.Cleanup(httplog.Disable)
+
+		gb := newGeckoboardClient(config.Geckoboard{APIKey: "gb_123", URL: server.URL})
+		dataset := &geckoboard.Dataset{Name: "jobs"}
+
+		err := gb.DatasetService.AppendData(context.Background(), dataset, geckoboard.Data{{"name": "Sam"}})
+		assert.NilError(t, err)
+
+		assert.Assert(t, strings.Contains(logs.String(), "DBG: --> geckoboard AppendData jobs\n{\"data\":[{\"name\":\"Sam\"}]}"), logs.String())
+		assert.Assert(t, strings.Contains(logs.String(), "DBG: <-- geckoboard AppendData jobs ("), logs.String())
+	})
+}
+
+func TestProcessor_ProcessTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+
+	proc, _, _ := buildProcessorWithMocks()
+
+	err := proc.Process(context.Background(), config.Entry{
+		Report:  config.Report{ID: "1234", CategoryID: "category-abc"},
+		Dataset: config.Dataset{Name: "jobs", Type: "append"},
+	})
+	assert.NilError(t, err)
+
+	spans := recorder.Ended()
+	names := []string{}
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+
+	assert.DeepEqual(t, names, []string{"dataset.Build", "geckoboard.FindOrCreate", "geckoboard.AppendData", "processor.Process"})
+
+	root := spans[3]
+	for _, span := range spans[:3] {
+		assert.Equal(t, span.Parent().SpanID(), root.SpanContext().SpanID())
+	}
+
+	assert.DeepEqual(t, spanAttributes(spans[0]), map[attribute.Key]string{
+		"dataset.fields": "4",
+	})
+	assert.DeepEqual(t, spanAttributes(spans[2]), map[attribute.Key]string{
+		"geckoboard.dataset": "jobs",
+		"geckoboard.records": "3",
+	})
+	assert.Equal(t, spanAttributes(root)["dataset.rows"], "3")
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+	attrs := map[attribute.Key]string{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+
+	return attrs
+}
+
 func TestProcessor_Processor(t *testing.T) {
 	t.Run("queries the correct report and report data", func(t *testing.T) {
 		var (
@@ -290,6 +396,83 @@ func TestProcessor_Processor(t *testing.T) {
 			assert.NilError(t, err)
 			assert.Assert(t, calledReportData)
 		})
+
+		t.Run("replaces variables with their computed values", func(t *testing.T) {
+			var calledReportData bool
+			proc, rs, _ := buildProcessorWithMocks()
+
+			proc.keywordReplacer = keyword.NewHandler(mockTimeWrapper{now: time.Date(2022, 6, 7, 8, 11, 0, 0, time.UTC)}, map[string]string{
+				"LAST_WEEK_START": "start_of_week(NOW-7d)",
+				"TECHNICIAN":      "Hilary",
+			})
+
+			rs.getReportDataFn = func(got servicetitan.ReportDataRequest, gotPagination *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+				assert.DeepEqual(t, got.Parameters, []servicetitan.DataRequestParamters{
+					{Name: "From", Value: "2022-05-30"},
+					{Name: "To", Value: "2022-06-07"},
+					{Name: "Username", Value: "Hilary"},
+				})
+				calledReportData = true
+
+				return &servicetitan.ReportData{
+					Data:   []interface{}{},
+					Fields: []servicetitan.ReportField{},
+				}, nil
+			}
+
+			err := proc.Process(context.Background(), config.Entry{
+				Report: config.Report{
+					ID:         "1234",
+					CategoryID: "category-abc",
+					Parameters: []config.Parameter{
+						{Name: "From", Value: "LAST_WEEK_START"},
+						{Name: "To", Value: "NOW"},
+						{Name: "Username", Value: "TECHNICIAN"},
+					},
+				},
+			})
+
+			assert.NilError(t, err)
+			assert.Assert(t, calledReportData)
+		})
+	})
+
+	t.Run("returns error for an unknown variable as a date parameter", func(t *testing.T) {
+		proc, rs, _ := buildProcessorWithMocks()
+
+		rs.getReportDataFn = func(servicetitan.ReportDataRequest, *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+			return nil, errors.New("not expected to be called")
+		}
+
+		err := proc.Process(context.Background(), config.Entry{
+			Report: config.Report{
+				ID:         "1234",
+				CategoryID: "category-abc",
+				Parameters: []config.Parameter{
+					{Name: "From", Value: "SEASON_STAR"},
+					{Name: "Username", Value: "JOB_COMPLETED"},
+				},
+			},
+		})
+		assert.Error(t, err, `param "From" for report 2222222 uses unknown variable "SEASON_STAR"`)
+	})
+
+	t.Run("sends upper case values of other parameters as is", func(t *testing.T) {
+		proc, rs, _ := buildProcessorWithMocks()
+
+		rs.getReportDataFn = func(got servicetitan.ReportDataRequest, _ *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+			assert.DeepEqual(t, got.Parameters, []servicetitan.DataRequestParamters{{Name: "Username", Value: "JOB_COMPLETED"}})
+			return &servicetitan.ReportData{}, nil
+		}
+
+		err := proc.Process(context.Background(), config.Entry{
+			Report: config.Report{
+				ID:         "1234",
+				CategoryID: "category-abc",
+				Parameters: []config.Parameter{{Name: "Username", Value: "JOB_COMPLETED"}},
+			},
+		})
+		assert.NilError(t, err)
 	})
 
 	t.Run("returns error when report fetch fails", func(t *testing.T) {
@@ -349,6 +532,79 @@ func TestProcessor_Processor(t *testing.T) {
 		assert.ErrorContains(t, err, "append data error")
 	})
 
+	t.Run("logs the row of an invalid date and pushes the row without it", func(t *testing.T) {
+		proc, rs, ds := buildProcessorWithMocks()
+
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+
+		var pushed geckoboard.Data
+		ds.replaceDataFn = func(_ *geckoboard.Dataset, data geckoboard.Data) error {
+			pushed = data
+			return nil
+		}
+
+		rs.getReportDataFn = func(servicetitan.ReportDataRequest, *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+			return &servicetitan.ReportData{
+				Data: []interface{}{
+					[]interface{}{"John Smith", 5, true, "2021-10-13"},
+					[]interface{}{"Jane Doe", 9, true, "13th October"},
+				},
+				Fields: []servicetitan.ReportField{
+					{Name: "Name", Label: "Name", Type: "String"},
+					{Name: "Number of jobs", Label: "Completed Jobs", Type: "Number"},
+					{Name: "Active", Label: "Active", Type: "Boolean"},
+					{Name: "Completed on", Label: "Completed date", Type: "Date"},
+				},
+			}, nil
+		}
+
+		err := proc.Process(context.Background(), config.Entry{})
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(pushed), 2)
+		assert.Assert(t, pushed[1]["completed_on"] == nil)
+		assert.Equal(t, pushed[1]["name"], "Jane Doe")
+		assert.Assert(t, strings.Contains(logs.String(), `Row 2 field "Completed on" value "13th October" is not a valid date, pushing the row without the value`), logs.String())
+	})
+
+	t.Run("logs and skips a row with an invalid required date", func(t *testing.T) {
+		proc, rs, ds := buildProcessorWithMocks()
+
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+
+		var pushed geckoboard.Data
+		ds.replaceDataFn = func(_ *geckoboard.Dataset, data geckoboard.Data) error {
+			pushed = data
+			return nil
+		}
+
+		rs.getReportDataFn = func(servicetitan.ReportDataRequest, *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+			return &servicetitan.ReportData{
+				Data: []interface{}{
+					[]interface{}{"John Smith", "2021-10-13"},
+					[]interface{}{"Jane Doe", "13th October"},
+				},
+				Fields: []servicetitan.ReportField{
+					{Name: "Name", Label: "Name", Type: "String"},
+					{Name: "Completed on", Label: "Completed date", Type: "Date"},
+				},
+			}, nil
+		}
+
+		err := proc.Process(context.Background(), config.Entry{
+			Dataset: config.Dataset{RequiredFields: []string{"Name", "Completed on"}},
+		})
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(pushed), 1)
+		assert.Equal(t, pushed[0]["name"], "John Smith")
+		assert.Assert(t, strings.Contains(logs.String(), `Row 2 field "Completed on" value "13th October" is not a valid date, skipping the row as the field is required`), logs.String())
+	})
+
 	t.Run("returns error when invalid param in config", func(t *testing.T) {
 		proc, _, _ := buildProcessorWithMocks()
 
@@ -367,32 +623,430 @@ func TestProcessor_Processor(t *testing.T) {
 	})
 }
 
+func TestProcessor_ProcessRollUp(t *testing.T) {
+	buildRollUpProcessor := func() (ReportProcessor, *mockReportService, *mockReportService, *mockDatasetService) {
+		proc, rsA, ds := buildProcessorWithMocks()
+		rsB := &mockReportService{}
+
+		clientB, err := servicetitan.New(config.ServiceTitan{Name: "franchise-b"})
+		assert.NilError(t, err)
+		clientB.ReportService = rsB
+
+		proc.tenants[0].connection.Name = "franchise-a"
+		proc.tenants = append(proc.tenants, tenantClient{
+			connection: config.ServiceTitan{Name: "franchise-b"},
+			client:     clientB,
+		})
+
+		return proc, rsA, rsB, ds
+	}
+
+	entry := config.Entry{
+		Connections: []string{"franchise-a", "franchise-b"},
+		Dataset: config.Dataset{
+			RequiredFields: []string{"Name"},
+		},
+	}
+
+	t.Run("pushes the data from every tenant into one dataset", func(t *testing.T) {
+		proc, _, rsB, ds := buildRollUpProcessor()
+
+		rsB.getReportDataFn = func(servicetitan.ReportDataRequest, *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+			return &servicetitan.ReportData{
+				Data: []interface{}{
+					[]interface{}{"Bob", 1, false, "2021-10-14"},
+				},
+				Fields: []servicetitan.ReportField{
+					{Name: "Name", Label: "Name", Type: "String"},
+					{Name: "Number of jobs", Label: "Completed Jobs", Type: "Number"},
+					{Name: "Active", Label: "Active", Type: "Boolean"},
+					{Name: "Completed on", Label: "Completed date", Type: "Date"},
+				},
+			}, nil
+		}
+
+		var calledReplaceData bool
+
+		ds.findOrCreateFn = func(got *geckoboard.Dataset) error {
+			assert.Equal(t, got.Name, "report_a")
+			assert.DeepEqual(t, got.UniqueBy, []string{"name", "tenant"})
+			return nil
+		}
+
+		ds.replaceDataFn = func(_ *geckoboard.Dataset, got geckoboard.Data) error {
+			assert.Equal(t, len(got), 4)
+			assert.Equal(t, got[0]["tenant"], "franchise-a")
+			assert.Equal(t, got[3]["tenant"], "franchise-b")
+			assert.Equal(t, got[3]["name"], "Bob")
+
+			calledReplaceData = true
+			return nil
+		}
+
+		assert.NilError(t, proc.Process(context.Background(), entry))
+		assert.Assert(t, calledReplaceData)
+	})
+
+	t.Run("doesn't fetch the other tenants once the replace dataset is full", func(t *testing.T) {
+		proc, rsA, rsB, ds := buildRollUpProcessor()
+
+		rsA.getReportDataFn = func(servicetitan.ReportDataRequest, *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+			data := &servicetitan.ReportData{Fields: []servicetitan.ReportField{{Name: "Name", Label: "Name", Type: "String"}}}
+			for i := 0; i < 600; i++ {
+				data.Data = append(data.Data, []interface{}{fmt.Sprintf("Technician %d", i+1)})
+			}
+
+			return data, nil
+		}
+
+		rsB.getReportDataFn = func(servicetitan.ReportDataRequest, *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+			return nil, errors.New("not expected to be called")
+		}
+
+		var pushed geckoboard.Data
+		ds.replaceDataFn = func(_ *geckoboard.Dataset, got geckoboard.Data) error {
+			pushed = got
+			return nil
+		}
+
+		assert.NilError(t, proc.Process(context.Background(), entry))
+		assert.Equal(t, len(pushed), 500)
+		assert.Equal(t, pushed[499]["tenant"], "franchise-a")
+	})
+
+	t.Run("returns error with the tenant when a report fetch fails", func(t *testing.T) {
+		proc, _, rsB, _ := buildRollUpProcessor()
+
+		rsB.getReportFn = func(string, string) (*servicetitan.Report, error) {
+			return nil, errors.New("report fetch failed")
+		}
+
+		err := proc.Process(context.Background(), entry)
+		assert.Error(t, err, `tenant "franchise-b": report fetch failed`)
+	})
+
+	t.Run("returns error when tenant fields are incompatible", func(t *testing.T) {
+		proc, _, rsB, _ := buildRollUpProcessor()
+
+		rsB.getReportFn = func(string, string) (*servicetitan.Report, error) {
+			return &servicetitan.Report{
+				Name:   "Report A",
+				Fields: []servicetitan.ReportField{{Name: "Name", Label: "Name", Type: "String"}},
+			}, nil
+		}
+
+		err := proc.Process(context.Background(), entry)
+		assert.ErrorContains(t, err, `tenant "franchise-b" report fields are incompatible with tenant "franchise-a"`)
+	})
+}
+
+func TestProcessor_ProcessExport(t *testing.T) {
+	entry := config.Entry{
+		Export: &config.Export{
+			Resource: "jpm/jobs",
+			Fields: []config.SourceField{
+				{Name: "id", Type: "Number"},
+				{Name: "jobStatus", Label: "Status", Type: "String"},
+				{Name: "location.name", Label: "Location", Type: "String"},
+			},
+		},
+		Dataset: config.Dataset{Type: "append", RequiredFields: []string{"id"}},
+	}
+
+	buildExportProcessor := func() (ReportProcessor, *mockExportService, *mockDatasetService, *memoryExportState) {
+		proc, _, ds := buildProcessorWithMocks()
+		es := &mockExportService{}
+		state := &memoryExportState{tokens: map[string]string{}}
+
+		proc.tenants[0].client.ExportService = es
+		proc.exportState = state
+
+		return proc, es, ds, state
+	}
+
+	t.Run("pushes every page of records and saves the token", func(t *testing.T) {
+		proc, es, ds, state := buildExportProcessor()
+		state.tokens["|"+entry.DatasetKey()] = "token-1"
+
+		pages := map[string]*servicetitan.ExportData{
+			"token-1": {
+				Data:         []map[string]interface{}{{"id": float64(1), "jobStatus": "Completed", "location": map[string]interface{}{"name": "Head office"}}},
+				HasMore:      true,
+				ContinueFrom: "token-2",
+			},
+			"token-2": {
+				Data:         []map[string]interface{}{{"id": float64(2), "jobStatus": "Scheduled"}},
+				ContinueFrom: "token-3",
+			},
+		}
+
+		es.getExportFn = func(got servicetitan.ExportRequest) (*servicetitan.ExportData, error) {
+			assert.Equal(t, got.Resource, "jpm/jobs")
+			return pages[got.From], nil
+		}
+
+		ds.findOrCreateFn = func(got *geckoboard.Dataset) error {
+			assert.Equal(t, got.Name, "jobs")
+			assert.DeepEqual(t, got.Fields["locationname"], geckoboard.Field{Type: geckoboard.StringType, Name: "Location", Optional: true})
+			assert.DeepEqual(t, got.UniqueBy, []string{"id"})
+			return nil
+		}
+
+		var pushed geckoboard.Data
+		ds.appendDataFn = func(_ *geckoboard.Dataset, got geckoboard.Data) error {
+			pushed = got
+			return nil
+		}
+
+		assert.NilError(t, proc.Process(context.Background(), entry))
+		assert.DeepEqual(t, pushed, geckoboard.Data{
+			{"id": float64(1), "jobstatus": "Completed", "locationname": "Head office"},
+			{"id": float64(2), "jobstatus": "Scheduled"},
+		})
+		assert.Equal(t, state.tokens["|"+entry.DatasetKey()], "token-3")
+	})
+
+	t.Run("doesn't push without changed records", func(t *testing.T) {
+		proc, es, ds, state := buildExportProcessor()
+
+		es.getExportFn = func(got servicetitan.ExportRequest) (*servicetitan.ExportData, error) {
+			assert.Equal(t, got.From, "")
+			return &servicetitan.ExportData{ContinueFrom: "token-1"}, nil
+		}
+
+		ds.appendDataFn = func(*geckoboard.Dataset, geckoboard.Data) error {
+			t.Fatal("not expected to be called")
+			return nil
+		}
+
+		assert.NilError(t, proc.Process(context.Background(), entry))
+		assert.Equal(t, state.tokens["|"+entry.DatasetKey()], "token-1")
+	})
+
+	t.Run("stops at the max dataset records", func(t *testing.T) {
+		proc, es, _, state := buildExportProcessor()
+		proc.maxDatasetRecords = 1
+
+		es.getExportFn = func(got servicetitan.ExportRequest) (*servicetitan.ExportData, error) {
+			assert.Equal(t, got.From, "")
+			return &servicetitan.ExportData{
+				Data:         []map[string]interface{}{{"id": float64(1)}},
+				HasMore:      true,
+				ContinueFrom: "token-1",
+			}, nil
+		}
+
+		assert.NilError(t, proc.Process(context.Background(), entry))
+		assert.Equal(t, state.tokens["|"+entry.DatasetKey()], "token-1")
+	})
+
+	t.Run("doesn't save the token when the push fails", func(t *testing.T) {
+		proc, es, ds, state := buildExportProcessor()
+
+		es.getExportFn = func(servicetitan.ExportRequest) (*servicetitan.ExportData, error) {
+			return &servicetitan.ExportData{Data: []map[string]interface{}{{"id": float64(1)}}, ContinueFrom: "token-1"}, nil
+		}
+
+		ds.appendDataFn = func(*geckoboard.Dataset, geckoboard.Data) error {
+			return errors.New("append data error")
+		}
+
+		assert.ErrorContains(t, proc.Process(context.Background(), entry), "append data error")
+		assert.Equal(t, len(state.tokens), 0)
+	})
+
+	t.Run("returns error when the export fetch fails", func(t *testing.T) {
+		proc, es, _, _ := buildExportProcessor()
+
+		es.getExportFn = func(servicetitan.ExportRequest) (*servicetitan.ExportData, error) {
+			return nil, errors.New("export fetch failed")
+		}
+
+		assert.Error(t, proc.Process(context.Background(), entry), "export fetch failed")
+	})
+}
+
+func TestProcessor_ProcessFile(t *testing.T) {
+	t.Run("pushes the file rows to the dataset", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "targets.csv")
+		assert.NilError(t, os.WriteFile(path, []byte("Month,Target\n2022-06-01,1500\n2022-07-01,2000\n"), 0o600))
+
+		proc, _, ds := buildProcessorWithMocks()
+
+		ds.findOrCreateFn = func(got *geckoboard.Dataset) error {
+			assert.Equal(t, got.Name, "targets")
+			assert.DeepEqual(t, got.Fields["month"], geckoboard.Field{Type: geckoboard.DateType, Name: "Month"})
+			return nil
+		}
+
+		var pushed geckoboard.Data
+		ds.replaceDataFn = func(_ *geckoboard.Dataset, got geckoboard.Data) error {
+			pushed = got
+			return nil
+		}
+
+		err := proc.Process(context.Background(), config.Entry{
+			File: &config.File{Path: path, Fields: []config.SourceField{
+				{Name: "Month", Type: "Date"},
+				{Name: "Target", Type: "Number"},
+			}},
+			Dataset: config.Dataset{RequiredFields: []string{"Month"}},
+		})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, pushed, geckoboard.Data{
+			{"month": "2022-06-01", "target": float64(1500)},
+			{"month": "2022-07-01", "target": float64(2000)},
+		})
+	})
+}
+
+func TestProcessor_ProcessBatches(t *testing.T) {
+	reportRows := func(count int) func(servicetitan.ReportDataRequest, *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+		return func(servicetitan.ReportDataRequest, *servicetitan.PaginationOptions) (*servicetitan.ReportData, error) {
+			data := &servicetitan.ReportData{Fields: []servicetitan.ReportField{
+				{Name: "Name", Label: "Name", Type: "String"},
+				{Name: "Number of jobs", Label: "Completed Jobs", Type: "Number"},
+				{Name: "Active", Label: "Active", Type: "Boolean"},
+				{Name: "Completed on", Label: "Completed date", Type: "Date"},
+			}}
+
+			for i := 0; i < count; i++ {
+				data.Data = append(data.Data, []interface{}{fmt.Sprintf("Technician %d", i+1), i, true, "2021-10-13"})
+			}
+
+			return data, nil
+		}
+	}
+
+	t.Run("appends the rows in batches", func(t *testing.T) {
+		proc, rs, ds := buildProcessorWithMocks()
+		rs.getReportDataFn = reportRows(1200)
+
+		batches := []int{}
+		ds.appendDataFn = func(_ *geckoboard.Dataset, got geckoboard.Data) error {
+			batches = append(batches, len(got))
+			return nil
+		}
+
+		err := proc.Process(context.Background(), config.Entry{Dataset: config.Dataset{Type: "append"}})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, batches, []int{500, 500, 200})
+	})
+
+	t.Run("doesn't append without any rows", func(t *testing.T) {
+		proc, rs, ds := buildProcessorWithMocks()
+		rs.getReportDataFn = reportRows(0)
+
+		ds.appendDataFn = func(*geckoboard.Dataset, geckoboard.Data) error {
+			return errors.New("not expected to be called")
+		}
+
+		err := proc.Process(context.Background(), config.Entry{Dataset: config.Dataset{Type: "append"}})
+		assert.NilError(t, err)
+	})
+
+	t.Run("replaces with the first batch of rows", func(t *testing.T) {
+		proc, rs, ds := buildProcessorWithMocks()
+		rs.getReportDataFn = reportRows(1200)
+
+		calls := 0
+		var pushed geckoboard.Data
+		ds.replaceDataFn = func(_ *geckoboard.Dataset, got geckoboard.Data) error {
+			calls++
+			pushed = got
+			return nil
+		}
+
+		err := proc.Process(context.Background(), config.Entry{})
+		assert.NilError(t, err)
+		assert.Equal(t, calls, 1)
+		assert.Equal(t, len(pushed), 500)
+		assert.Equal(t, pushed[499]["name"], "Technician 500")
+	})
+
+	t.Run("reads the rows while the batches are appended", func(t *testing.T) {
+		proc, _, ds := buildProcessorWithMocks()
+		readDone := make(chan struct{})
+
+		batches := []int{}
+		ds.appendDataFn = func(_ *geckoboard.Dataset, got geckoboard.Data) error {
+			// A push waiting on the rows to be read would never finish if reading waited on it
+			select {
+			case <-readDone:
+			case <-time.After(5 * time.Second):
+				return errors.New("the rows weren't read while pushing")
+			}
+
+			batches = append(batches, len(got))
+			return nil
+		}
+
+		entry := config.Entry{Dataset: config.Dataset{Type: "append"}}
+		rows, err := proc.pushRows(context.Background(), entry, &geckoboard.Dataset{Name: "jobs"}, func(fn func(geckoboard.DataRow) error) error {
+			defer close(readDone)
+
+			for i := 0; i < 1200; i++ {
+				if err := fn(geckoboard.DataRow{"name": fmt.Sprintf("Technician %d", i+1)}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, rows, 1200)
+		assert.DeepEqual(t, batches, []int{500, 500, 200})
+	})
+
+	t.Run("stops reading once a batch fails to append", func(t *testing.T) {
+		proc, _, ds := buildProcessorWithMocks()
+
+		ds.appendDataFn = func(*geckoboard.Dataset, geckoboard.Data) error {
+			return errors.New("append data error")
+		}
+
+		entry := config.Entry{Dataset: config.Dataset{Type: "append"}}
+		_, err := proc.pushRows(context.Background(), entry, &geckoboard.Dataset{Name: "jobs"}, func(fn func(geckoboard.DataRow) error) error {
+			for {
+				if err := fn(geckoboard.DataRow{"name": "Technician"}); err != nil {
+					return err
+				}
+			}
+		})
+		assert.Error(t, err, "append data error")
+	})
+
+	t.Run("replaces with no rows to empty the dataset", func(t *testing.T) {
+		proc, rs, ds := buildProcessorWithMocks()
+		rs.getReportDataFn = reportRows(0)
+
+		calls := 0
+		ds.replaceDataFn = func(_ *geckoboard.Dataset, got geckoboard.Data) error {
+			calls++
+			assert.Equal(t, len(got), 0)
+			return nil
+		}
+
+		err := proc.Process(context.Background(), config.Entry{})
+		assert.NilError(t, err)
+		assert.Equal(t, calls, 1)
+	})
+}
+
 func buildProcessorWithMocks() (ReportProcessor, *mockReportService, *mockDatasetService) {
 	reportSrv := &mockReportService{}
 	datasetSrv := &mockDatasetService{}
 
-	proc := New(&config.Config{
+	proc, _ := New(&config.Config{
 		ServiceTitan: config.ServiceTitan{},
 		Geckoboard:   config.Geckoboard{},
-	})
+	}, config.Geckoboard{}, config.ServiceTitan{})
 
-	proc.serviceTitanClient.ReportService = reportSrv
+	proc.tenants[0].client.ReportService = reportSrv
 	proc.geckoboardClient.DatasetService = datasetSrv
 
-	kh := proc.keywordReplacer.(*KeywordHandler)
 	now := time.Date(2022, 6, 7, 8, 11, 0, 0, time.UTC)
-
-	for idx := range kh.replacers {
-		r := kh.replacers[idx]
-
-		switch val := r.(type) {
-		case *NowReplacer:
-			val.timeWrapper = mockTimeWrapper{now: now}
-		case *CurrentMonthDayReplacer:
-			val.timeWrapper = mockTimeWrapper{now: now}
-		}
-
-	}
+	proc.keywordReplacer = keyword.NewHandler(mockTimeWrapper{now: now}, nil)
 
 	return proc, reportSrv, datasetSrv
 }
@@ -488,3 +1142,106 @@ func (r *mockReportService) GetReportData(_ context.Context, rdr servicetitan.Re
 
 	return r.getReportDataFn(rdr, po)
 }
+
+func (r *mockReportService) StreamReportData(ctx context.Context, rdr servicetitan.ReportDataRequest, po *servicetitan.PaginationOptions, fn servicetitan.RowFunc) (*servicetitan.ReportData, error) {
+	data, err := r.GetReportData(ctx, rdr, po)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range data.Data {
+		if err := fn(data.Fields, row.([]interface{})); errors.Is(err, servicetitan.ErrStopRows) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	data.Data = nil
+	return data, nil
+}
+
+type mockExportService struct {
+	getExportFn func(servicetitan.ExportRequest) (*servicetitan.ExportData, error)
+}
+
+func (e *mockExportService) GetExport(_ context.Context, req servicetitan.ExportRequest) (*servicetitan.ExportData, error) {
+	return e.getExportFn(req)
+}
+
+type memoryExportState struct {
+	tokens map[string]string
+}
+
+func (m *memoryExportState) Load(key string) (string, error) {
+	return m.tokens[key], nil
+}
+
+func (m *memoryExportState) Save(key, token string) error {
+	m.tokens[key] = token
+	return nil
+}
+
+type mockTimeWrapper struct {
+	now time.Time
+}
+
+func (m mockTimeWrapper) Now() time.Time {
+	return m.now
+}
+
+// BenchmarkProcessor_ProcessFile pushes a csv file of each size, the peak-heap metric is
+// the most memory in use while pushing which stays flat as the rows are streamed in batches
+func BenchmarkProcessor_ProcessFile(b *testing.B) {
+	for _, rows := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "jobs.csv")
+
+			content := &strings.Builder{}
+			content.WriteString("Name,Jobs,Completed on\n")
+			for i := 0; i < rows; i++ {
+				fmt.Fprintf(content, "Technician %d,%d,2021-10-13\n", i, i)
+			}
+
+			if err := os.WriteFile(path, []byte(content.String()), 0o600); err != nil {
+				b.Fatal(err)
+			}
+			content = nil
+
+			proc, _, ds := buildProcessorWithMocks()
+			entry := config.Entry{
+				File: &config.File{Path: path, Fields: []config.SourceField{
+					{Name: "Name", Type: "String"},
+					{Name: "Jobs", Type: "Number"},
+					{Name: "Completed on", Type: "Date"},
+				}},
+				Dataset: config.Dataset{Type: "append"},
+			}
+
+			b.ReportAllocs()
+			runtime.GC()
+
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			base, peak := stats.HeapAlloc, uint64(0)
+
+			ds.appendDataFn = func(*geckoboard.Dataset, geckoboard.Data) error {
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > base && stats.HeapAlloc-base > peak {
+					peak = stats.HeapAlloc - base
+				}
+
+				return nil
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := proc.Process(context.Background(), entry); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(peak), "peak-heap-bytes")
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package redact
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	Placeholder = "[REDACTED]"
+
+	// Very short values would redact unrelated parts of the output
+	minSecretLength = 4
+)
+
+var global = &Redactor{}
+
+// Redactor replaces known secret values in strings
+type Redactor struct {
+	mu       sync.RWMutex
+	secrets  map[string]struct{}
+	replacer *strings.Replacer
+}
+
+// Add registers the secret to be redacted
+func (r *Redactor) Add(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.secrets == nil {
+		r.secrets = map[string]struct{}{}
+	}
+
+	if _, ok := r.secrets[secret]; ok {
+		return
+	}
+
+	r.secrets[secret] = struct{}{}
+
+	secrets := make([]string, 0, len(r.secrets))
+	for s := range r.secrets {
+		secrets = append(secrets, s)
+	}
+
+	// Longest first so a secret containing another is fully redacted
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+
+	pairs := []string{}
+	for _, s := range secrets {
+		pairs = append(pairs, s, Placeholder)
+	}
+
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// String returns the input with every registered secret redacted
+func (r *Redactor) String(in string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.replacer == nil {
+		return in
+	}
+
+	return r.replacer.Replace(in)
+}
+
+// Writer returns a writer which redacts the secrets before writing to w
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return writer{w: w, redactor: r}
+}
+
+type writer struct {
+	w        io.Writer
+	redactor *Redactor
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.String(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Add registers the secret to be redacted by the global redactor
+func Add(secret string) {
+	global.Add(secret)
+}
+
+// String redacts the secrets registered with the global redactor
+func String(in string) string {
+	return global.String(in)
+}
+
+// Writer returns a writer redacting the secrets registered with the global redactor
+func Writer(w io.Writer) io.Writer {
+	return global.Writer(w)
+}
@@ -0,0 +1,56 @@
+package redact
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRedactor_String(t *testing.T) {
+	t.Run("returns the input when there are no secrets", func(t *testing.T) {
+		r := &Redactor{}
+		assert.Equal(t, r.String("client_secret=abcd1234"), "client_secret=abcd1234")
+	})
+
+	t.Run("redacts every registered secret", func(t *testing.T) {
+		r := &Redactor{}
+		r.Add("abcd1234")
+		r.Add("tok_5678")
+		r.Add("abcd1234")
+
+		got := r.String("client_secret=abcd1234&token=tok_5678 again abcd1234")
+		assert.Equal(t, got, "client_secret=[REDACTED]&token=[REDACTED] again [REDACTED]")
+	})
+
+	t.Run("redacts the whole secret when it contains another secret", func(t *testing.T) {
+		r := &Redactor{}
+		r.Add("abcd")
+		r.Add("abcd1234")
+
+		assert.Equal(t, r.String("key=abcd1234"), "key=[REDACTED]")
+	})
+
+	t.Run("ignores very short secrets", func(t *testing.T) {
+		r := &Redactor{}
+		r.Add("")
+		r.Add("abc")
+
+		assert.Equal(t, r.String("abc"), "abc")
+	})
+}
+
+func TestRedactor_Writer(t *testing.T) {
+	t.Run("redacts the secrets before writing", func(t *testing.T) {
+		r := &Redactor{}
+		r.Add("apikey1234")
+
+		buf := &bytes.Buffer{}
+		n, err := io.WriteString(r.Writer(buf), "ERR: invalid api key apikey1234\n")
+		assert.NilError(t, err)
+
+		assert.Equal(t, n, 32)
+		assert.Equal(t, buf.String(), "ERR: invalid api key [REDACTED]\n")
+	})
+}
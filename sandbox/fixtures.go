@@ -0,0 +1,133 @@
+package sandbox
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+//go:embed fixtures.yml
+var defaultFixtures []byte
+
+// Fixtures are the reports the sandbox serves, along with
+// the rate limit and errors it responds with
+type Fixtures struct {
+	// ClientID and ClientSecret are the only credentials
+	// accepted when set, otherwise any are accepted
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// AppID is the only ST-App-Key accepted when set, otherwise any is accepted
+	AppID string `yaml:"app_id"`
+	// TenantIDs are the only tenants served when set, otherwise any tenant is
+	TenantIDs []string `yaml:"tenant_ids"`
+	// TokenExpiresIn is the seconds each token is valid for, defaults to 900
+	TokenExpiresIn int `yaml:"token_expires_in"`
+	// GeckoboardAPIKeys are the only api keys accepted when set
+	GeckoboardAPIKeys []string   `yaml:"geckoboard_api_keys"`
+	Categories        []Category `yaml:"categories"`
+	RateLimit         RateLimit  `yaml:"rate_limit"`
+	Faults            []Fault    `yaml:"faults"`
+}
+
+type Category struct {
+	ID      string   `yaml:"id"`
+	Name    string   `yaml:"name"`
+	Reports []Report `yaml:"reports"`
+}
+
+type Report struct {
+	ID         int         `yaml:"id"`
+	Name       string      `yaml:"name"`
+	Fields     []Field     `yaml:"fields"`
+	Parameters []Parameter `yaml:"parameters"`
+	// Rows are the report data with a value for each field in order
+	Rows [][]interface{} `yaml:"rows"`
+}
+
+type Field struct {
+	Name  string `yaml:"name"`
+	Label string `yaml:"label"`
+	Type  string `yaml:"type"`
+}
+
+type Parameter struct {
+	Name     string `yaml:"name"`
+	Label    string `yaml:"label"`
+	Type     string `yaml:"type"`
+	Required bool   `yaml:"required"`
+	Array    bool   `yaml:"array"`
+}
+
+// RateLimit limits the report data requests of each tenant
+// like ServiceTitan does, the limit is off when requests is 0
+type RateLimit struct {
+	Requests int           `yaml:"requests"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Fault responds to the requests matching the method and path with the status
+type Fault struct {
+	// Method matches any method when empty
+	Method string `yaml:"method"`
+	// Path is a path.Match pattern of the request path such as
+	// /reporting/v2/tenant/*/report-category/*/reports/*/data
+	Path   string `yaml:"path"`
+	Status int    `yaml:"status"`
+	// Body defaults to an error message in the format of the API
+	Body string `yaml:"body"`
+	// Count is how many of the matching requests fail,
+	// every matching request fails when it is 0
+	Count int `yaml:"count"`
+}
+
+// LoadFixtures reads the fixtures from the file, the
+// built in fixtures are returned when the path is empty
+func LoadFixtures(path string) (Fixtures, error) {
+	b := defaultFixtures
+
+	if path != "" {
+		var err error
+		if b, err = os.ReadFile(path); err != nil {
+			return Fixtures{}, err
+		}
+	}
+
+	fixtures := Fixtures{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&fixtures); err != nil {
+		return Fixtures{}, fmt.Errorf("reading fixtures %s failed: %w", path, err)
+	}
+
+	return fixtures, fixtures.validate()
+}
+
+func (f Fixtures) validate() error {
+	for _, c := range f.Categories {
+		for _, r := range c.Reports {
+			for idx, row := range r.Rows {
+				if len(row) != len(r.Fields) {
+					return fmt.Errorf("report %d row %d has %d values but there are %d fields", r.ID, idx+1, len(row), len(r.Fields))
+				}
+			}
+		}
+	}
+
+	for _, fault := range f.Faults {
+		if fault.Path == "" || fault.Status == 0 {
+			return fmt.Errorf("fault for %q requires a path and status", fault.Path)
+		}
+	}
+
+	if f.RateLimit.Requests > 0 && f.RateLimit.Interval <= 0 {
+		return fmt.Errorf("rate_limit requires an interval such as 5m")
+	}
+
+	return nil
+}
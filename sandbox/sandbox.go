@@ -0,0 +1,694 @@
+package sandbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"path"
+	"servicetitan-to-dataset/servicetitan"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jnormington/geckoboard"
+)
+
+const (
+	// ServiceTitanAddr and GeckoboardAddr are where the sandbox listens by default,
+	// the sandbox servicetitan environment in the config uses ServiceTitanAddr
+	ServiceTitanAddr = "localhost:8081"
+	GeckoboardAddr   = "localhost:8082"
+
+	defaultTokenExpiresIn = 900
+	reportingPrefix       = "/reporting/v2/tenant/"
+)
+
+// Sandbox emulates the ServiceTitan and Geckoboard APIs from the fixtures,
+// the data pushed to the Geckoboard datasets is kept in memory
+type Sandbox struct {
+	fixtures Fixtures
+	record   io.Writer
+
+	mu            sync.Mutex
+	tokens        map[string]time.Time
+	tokenRequests int
+	reportData    map[string][]time.Time
+	dataRequests  []DataRequest
+	faultMatches  []int
+	failures      []failure
+	datasets      map[string]*Dataset
+	pushes        []Push
+}
+
+// DataRequest is a report data request received by the sandbox
+type DataRequest struct {
+	Request    servicetitan.ReportDataRequest
+	Pagination servicetitan.PaginationOptions
+}
+
+type failure struct {
+	status     int
+	retryAfter time.Duration
+}
+
+// Dataset is a Geckoboard dataset created in the sandbox
+type Dataset struct {
+	Schema geckoboard.Dataset `json:"schema"`
+	Data   geckoboard.Data    `json:"data"`
+}
+
+// Push is a request which created a dataset or sent data to it
+type Push struct {
+	Time    time.Time       `json:"time"`
+	Dataset string          `json:"dataset"`
+	Action  string          `json:"action"`
+	Data    geckoboard.Data `json:"data,omitempty"`
+}
+
+// New returns a sandbox serving the fixtures, every push is written
+// to record as a line of json when it isn't nil
+func New(fixtures Fixtures, record io.Writer) *Sandbox {
+	if fixtures.TokenExpiresIn == 0 {
+		fixtures.TokenExpiresIn = defaultTokenExpiresIn
+	}
+
+	return &Sandbox{
+		fixtures:     fixtures,
+		record:       record,
+		tokens:       map[string]time.Time{},
+		reportData:   map[string][]time.Time{},
+		faultMatches: make([]int, len(fixtures.Faults)),
+		datasets:     map[string]*Dataset{},
+	}
+}
+
+// Update changes the fixtures of the running sandbox, such as adding a report
+func (s *Sandbox) Update(fn func(*Fixtures)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.fixtures)
+
+	if s.fixtures.TokenExpiresIn == 0 {
+		s.fixtures.TokenExpiresIn = defaultTokenExpiresIn
+	}
+
+	for len(s.faultMatches) < len(s.fixtures.Faults) {
+		s.faultMatches = append(s.faultMatches, 0)
+	}
+}
+
+// FailNext responds to the next n reporting requests with the status, and
+// the Retry-After header when retryAfter is set. Unlike the fixture faults
+// they're only responded with once the token has been accepted
+func (s *Sandbox) FailNext(n, status int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{status: status, retryAfter: retryAfter})
+	}
+}
+
+// RevokeTokens rejects every token issued so far, as if ServiceTitan revoked
+// them, so the next request is responded to with 401 Unauthorized
+func (s *Sandbox) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = map[string]time.Time{}
+}
+
+// TokenRequests returns how many tokens have been issued
+func (s *Sandbox) TokenRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tokenRequests
+}
+
+// DataRequests returns the report data requests received in order
+func (s *Sandbox) DataRequests() []DataRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]DataRequest{}, s.dataRequests...)
+}
+
+// Pushes returns every push in the order they were received
+func (s *Sandbox) Pushes() []Push {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Push{}, s.pushes...)
+}
+
+// Datasets returns the datasets keyed by their id
+func (s *Sandbox) Datasets() map[string]Dataset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := map[string]Dataset{}
+	for id, ds := range s.datasets {
+		out[id] = Dataset{Schema: ds.Schema, Data: append(geckoboard.Data{}, ds.Data...)}
+	}
+
+	return out
+}
+
+// ServiceTitan returns the handler for the ServiceTitan auth and reporting
+// endpoints, the auth_url and api_url of the config are both its url
+func (s *Sandbox) ServiceTitan() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.injectFault(w, r, writeServiceTitanError) {
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/connect/token" && r.Method == http.MethodPost:
+			s.token(w, r)
+		case strings.HasPrefix(r.URL.Path, reportingPrefix):
+			if !s.authorized(r) {
+				writeServiceTitanError(w, http.StatusUnauthorized, "The access token is missing, invalid or has expired")
+				return
+			}
+
+			if s.fail(w) {
+				return
+			}
+
+			s.reporting(w, r)
+		default:
+			writeServiceTitanError(w, http.StatusNotFound, "Not found")
+		}
+	})
+}
+
+func (s *Sandbox) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	s.mu.Lock()
+	clientID, clientSecret, expiresIn := s.fixtures.ClientID, s.fixtures.ClientSecret, s.fixtures.TokenExpiresIn
+	s.mu.Unlock()
+
+	id, secret := r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	if id == "" || secret == "" || (clientID != "" && (id != clientID || secret != clientSecret)) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		writeServiceTitanError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	token := "sandbox-" + hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.tokens[token] = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	s.tokenRequests++
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"expires_in":   expiresIn,
+		"token_type":   "Bearer",
+	})
+}
+
+func (s *Sandbox) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	appKey := r.Header.Get("ST-App-Key")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if appKey == "" || (s.fixtures.AppID != "" && appKey != s.fixtures.AppID) {
+		return false
+	}
+
+	expiresAt, ok := s.tokens[token]
+	return ok && time.Now().Before(expiresAt)
+}
+
+// fail responds with the next queued failure when there is one
+func (s *Sandbox) fail(w http.ResponseWriter) bool {
+	s.mu.Lock()
+	if len(s.failures) == 0 {
+		s.mu.Unlock()
+		return false
+	}
+
+	f := s.failures[0]
+	s.failures = s.failures[1:]
+	s.mu.Unlock()
+
+	if f.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(f.retryAfter.Seconds()))))
+	}
+
+	writeServiceTitanError(w, f.status, http.StatusText(f.status))
+	return true
+}
+
+// reporting serves the paths after /reporting/v2/tenant/ which are
+// {tenant}/report-categories, {tenant}/report-category/{category}/reports
+// and then /{report} for the report and /{report}/data for its data.
+// The lock is held while responding as the fixtures can be updated
+func (s *Sandbox) reporting(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, reportingPrefix), "/")
+	tenant := parts[0]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.fixtures.TenantIDs) > 0 && !contains(s.fixtures.TenantIDs, tenant) {
+		writeServiceTitanError(w, http.StatusNotFound, fmt.Sprintf("Tenant %q not found", tenant))
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "report-categories" && r.Method == http.MethodGet:
+		s.categories(w, r)
+	case len(parts) >= 4 && parts[1] == "report-category" && parts[3] == "reports":
+		category, ok := s.category(parts[2])
+		if !ok {
+			writeServiceTitanError(w, http.StatusNotFound, fmt.Sprintf("Report category %q not found", parts[2]))
+			return
+		}
+
+		if len(parts) == 4 {
+			if r.Method != http.MethodGet {
+				w.Header().Set("Allow", http.MethodGet)
+				writeServiceTitanError(w, http.StatusMethodNotAllowed, "Method not allowed")
+				return
+			}
+
+			s.reports(w, r, category)
+			return
+		}
+
+		report, ok := s.report(category, parts[4])
+		if !ok {
+			writeServiceTitanError(w, http.StatusNotFound, fmt.Sprintf("Report %q not found", parts[4]))
+			return
+		}
+
+		switch {
+		case len(parts) == 5 && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, reportMetadata(report))
+		case len(parts) == 6 && parts[5] == "data" && r.Method == http.MethodPost:
+			s.data(w, r, tenant, category, report)
+		default:
+			writeServiceTitanError(w, http.StatusNotFound, "Not found")
+		}
+	default:
+		writeServiceTitanError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (s *Sandbox) categories(w http.ResponseWriter, r *http.Request) {
+	items := []servicetitan.Category{}
+	for _, c := range s.fixtures.Categories {
+		items = append(items, servicetitan.Category{ID: c.ID, Name: c.Name})
+	}
+
+	items, p := paginate(r, items)
+	writeJSON(w, http.StatusOK, servicetitan.CategoryList{Items: items, HasMore: p.hasMore, Page: p.page, PageSize: p.pageSize, Total: p.total})
+}
+
+func (s *Sandbox) reports(w http.ResponseWriter, r *http.Request, category Category) {
+	items := []servicetitan.Report{}
+	for _, report := range category.Reports {
+		items = append(items, servicetitan.Report{ID: report.ID, Name: report.Name})
+	}
+
+	items, p := paginate(r, items)
+	writeJSON(w, http.StatusOK, servicetitan.ReportList{Items: items, HasMore: p.hasMore, Page: p.page, PageSize: p.pageSize, Total: p.total})
+}
+
+// data is called with the lock held
+func (s *Sandbox) data(w http.ResponseWriter, r *http.Request, tenant string, category Category, report Report) {
+	if retryAfter, ok := s.allowReportData(tenant); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		writeServiceTitanError(w, http.StatusTooManyRequests, "Too many requests, the report data rate limit has been reached")
+		return
+	}
+
+	req := servicetitan.ReportDataRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeServiceTitanError(w, http.StatusBadRequest, "The request body is invalid: "+err.Error())
+		return
+	}
+
+	rows := []interface{}{}
+	for _, row := range report.Rows {
+		rows = append(rows, row)
+	}
+
+	rows, p := paginate(r, rows)
+
+	req.CategoryID, req.ReportID = category.ID, strconv.Itoa(report.ID)
+	s.dataRequests = append(s.dataRequests, DataRequest{
+		Request:    req,
+		Pagination: servicetitan.PaginationOptions{Page: p.page, PageSize: p.pageSize},
+	})
+
+	if msg := validateParameters(report, req.Parameters); msg != "" {
+		writeServiceTitanError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, servicetitan.ReportData{
+		Fields:   reportMetadata(report).Fields,
+		Data:     rows,
+		HasMore:  p.hasMore,
+		Page:     p.page,
+		PageSize: p.pageSize,
+		Total:    p.total,
+	})
+}
+
+// allowReportData records the request when the tenant is within the rate limit,
+// otherwise it returns how long until the next request is allowed. It's
+// called with the lock held
+func (s *Sandbox) allowReportData(tenant string) (time.Duration, bool) {
+	limit := s.fixtures.RateLimit
+	if limit.Requests <= 0 {
+		return 0, true
+	}
+
+	now := time.Now()
+	recent := []time.Time{}
+	for _, t := range s.reportData[tenant] {
+		if now.Sub(t) < limit.Interval {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= limit.Requests {
+		s.reportData[tenant] = recent
+		return recent[0].Add(limit.Interval).Sub(now), false
+	}
+
+	s.reportData[tenant] = append(recent, now)
+	return 0, true
+}
+
+func validateParameters(report Report, params []servicetitan.DataRequestParamters) string {
+	given := map[string]bool{}
+	for _, p := range params {
+		given[p.Name] = true
+	}
+
+	known := map[string]bool{}
+	for _, p := range report.Parameters {
+		known[p.Name] = true
+
+		if p.Required && !given[p.Name] {
+			return fmt.Sprintf("The %s parameter is required", p.Name)
+		}
+	}
+
+	for _, p := range params {
+		if !known[p.Name] {
+			return fmt.Sprintf("The %s parameter is not a parameter of the report", p.Name)
+		}
+	}
+
+	return ""
+}
+
+func (s *Sandbox) category(id string) (Category, bool) {
+	for _, c := range s.fixtures.Categories {
+		if c.ID == id {
+			return c, true
+		}
+	}
+
+	return Category{}, false
+}
+
+func (s *Sandbox) report(category Category, id string) (Report, bool) {
+	for _, r := range category.Reports {
+		if strconv.Itoa(r.ID) == id {
+			return r, true
+		}
+	}
+
+	return Report{}, false
+}
+
+func reportMetadata(report Report) servicetitan.Report {
+	out := servicetitan.Report{ID: report.ID, Name: report.Name, Fields: []servicetitan.ReportField{}, Parameters: []servicetitan.ReportParameter{}}
+
+	for _, f := range report.Fields {
+		out.Fields = append(out.Fields, servicetitan.ReportField{Name: f.Name, Label: f.Label, Type: f.Type})
+	}
+
+	for _, p := range report.Parameters {
+		out.Parameters = append(out.Parameters, servicetitan.ReportParameter{
+			Name:       p.Name,
+			Label:      p.Label,
+			DataType:   p.Type,
+			IsArray:    p.Array,
+			IsRequired: p.Required,
+		})
+	}
+
+	return out
+}
+
+type page struct {
+	page, pageSize, total int
+	hasMore               bool
+}
+
+// paginate returns the items of the page and page size in the query,
+// which default to the first page of 50 items like ServiceTitan
+func paginate[T any](r *http.Request, items []T) ([]T, page) {
+	p := page{page: 1, pageSize: 50, total: len(items)}
+
+	if n, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && n > 0 {
+		p.page = n
+	}
+
+	if n, err := strconv.Atoi(r.URL.Query().Get("pageSize")); err == nil && n > 0 {
+		p.pageSize = n
+	}
+
+	start := (p.page - 1) * p.pageSize
+	if start > len(items) {
+		start = len(items)
+	}
+
+	end := start + p.pageSize
+	if end > len(items) {
+		end = len(items)
+	}
+
+	p.hasMore = end < len(items)
+	return items[start:end], p
+}
+
+// Geckoboard returns the handler for the Geckoboard dataset endpoints,
+// the url of the geckoboard config is its url. The datasets can be
+// listed with GET /datasets which the Geckoboard API doesn't have
+func (s *Sandbox) Geckoboard() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.injectFault(w, r, writeGeckoboardError) {
+			return
+		}
+
+		apiKey, _, _ := r.BasicAuth()
+		if !s.validAPIKey(apiKey) {
+			writeGeckoboardError(w, http.StatusUnauthorized, "Your API key is invalid")
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		switch {
+		case r.URL.Path == "/" && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, struct{}{})
+		case r.URL.Path == "/datasets" && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, s.Datasets())
+		case len(parts) == 2 && parts[0] == "datasets" && r.Method == http.MethodPut:
+			s.findOrCreate(w, r, parts[1])
+		case len(parts) == 3 && parts[0] == "datasets" && parts[2] == "data" && r.Method == http.MethodPut:
+			s.pushData(w, r, parts[1], "replace")
+		case len(parts) == 3 && parts[0] == "datasets" && parts[2] == "data" && r.Method == http.MethodPost:
+			s.pushData(w, r, parts[1], "append")
+		default:
+			writeGeckoboardError(w, http.StatusNotFound, "Not found")
+		}
+	})
+}
+
+func (s *Sandbox) validAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.fixtures.GeckoboardAPIKeys) == 0 || contains(s.fixtures.GeckoboardAPIKeys, key)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Sandbox) findOrCreate(w http.ResponseWriter, r *http.Request, id string) {
+	schema := geckoboard.Dataset{}
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		writeGeckoboardError(w, http.StatusBadRequest, "The request body is invalid: "+err.Error())
+		return
+	}
+
+	schema.Name = id
+
+	s.mu.Lock()
+	existing, ok := s.datasets[id]
+	if ok && !sameFields(existing.Schema, schema) {
+		s.mu.Unlock()
+		writeGeckoboardError(w, http.StatusConflict, "Fields and their types cannot be changed for an existing dataset")
+		return
+	}
+
+	if !ok {
+		s.datasets[id] = &Dataset{Schema: schema, Data: geckoboard.Data{}}
+		s.recordPush(Push{Time: time.Now(), Dataset: id, Action: "create"})
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, schema)
+}
+
+func (s *Sandbox) pushData(w http.ResponseWriter, r *http.Request, id, action string) {
+	payload := geckoboard.DataPayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeGeckoboardError(w, http.StatusBadRequest, "The request body is invalid: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ds, ok := s.datasets[id]
+	if !ok {
+		writeGeckoboardError(w, http.StatusNotFound, fmt.Sprintf("Dataset %q not found", id))
+		return
+	}
+
+	if action == "replace" {
+		ds.Data = geckoboard.Data{}
+	}
+
+	ds.Data = append(ds.Data, payload.Data...)
+	s.recordPush(Push{Time: time.Now(), Dataset: id, Action: action, Data: payload.Data})
+
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// recordPush is called with the lock held
+func (s *Sandbox) recordPush(p Push) {
+	s.pushes = append(s.pushes, p)
+	log.Printf("INF: [sandbox] %s dataset %q with %d records", p.Action, p.Dataset, len(p.Data))
+
+	if s.record == nil {
+		return
+	}
+
+	b, err := json.Marshal(p)
+	if err == nil {
+		_, err = s.record.Write(append(b, '\n'))
+	}
+
+	if err != nil {
+		log.Printf("ERR: [sandbox] Unable to record the push %v", err)
+	}
+}
+
+func sameFields(a, b geckoboard.Dataset) bool {
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+
+	for key, f := range a.Fields {
+		if other, ok := b.Fields[key]; !ok || other.Type != f.Type {
+			return false
+		}
+	}
+
+	return true
+}
+
+// injectFault responds with the first fault matching the request
+func (s *Sandbox) injectFault(w http.ResponseWriter, r *http.Request, writeError func(http.ResponseWriter, int, string)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for idx, f := range s.fixtures.Faults {
+		if f.Method != "" && !strings.EqualFold(f.Method, r.Method) {
+			continue
+		}
+
+		if ok, _ := path.Match(f.Path, r.URL.Path); !ok {
+			continue
+		}
+
+		if f.Count > 0 && s.faultMatches[idx] >= f.Count {
+			continue
+		}
+
+		s.faultMatches[idx]++
+
+		if f.Body != "" {
+			w.WriteHeader(f.Status)
+			io.WriteString(w, f.Body)
+		} else {
+			writeError(w, f.Status, "Sandbox fault: "+http.StatusText(f.Status))
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func writeServiceTitanError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"title": msg, "status": status})
+}
+
+func writeGeckoboardError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"error": map[string]string{"message": msg}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,258 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/processor"
+	"servicetitan-to-dataset/servicetitan"
+	"testing"
+	"time"
+
+	"github.com/jnormington/geckoboard"
+	"gotest.tools/v3/assert"
+)
+
+func startSandbox(t *testing.T, fixtures Fixtures, record io.Writer) (*Sandbox, config.ServiceTitan, config.Geckoboard) {
+	t.Helper()
+
+	sb := New(fixtures, record)
+
+	st := httptest.NewServer(sb.ServiceTitan())
+	gb := httptest.NewServer(sb.Geckoboard())
+	t.Cleanup(st.Close)
+	t.Cleanup(gb.Close)
+
+	conn := config.ServiceTitan{
+		AppID:        "app",
+		TenantID:     "1234",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		AuthURL:      st.URL,
+		APIURL:       st.URL,
+	}
+
+	return sb, conn, config.Geckoboard{APIKey: "api-key", URL: gb.URL}
+}
+
+func loadDefaultFixtures(t *testing.T) Fixtures {
+	t.Helper()
+
+	fixtures, err := LoadFixtures("")
+	assert.NilError(t, err)
+
+	return fixtures
+}
+
+var technicianEntry = config.Entry{
+	Report: config.Report{
+		ID:         "1001",
+		CategoryID: "operations",
+		Parameters: []config.Parameter{
+			{Name: "From", Value: "2022-06-01"},
+			{Name: "To", Value: "2022-06-07"},
+		},
+	},
+	Dataset: config.Dataset{Name: "technicians", Type: "append", RequiredFields: []string{"Name"}},
+}
+
+func TestSandbox_Push(t *testing.T) {
+	t.Run("pushes the report data to the dataset", func(t *testing.T) {
+		record := &bytes.Buffer{}
+		sb, conn, dest := startSandbox(t, loadDefaultFixtures(t), record)
+
+		proc, err := processor.New(&config.Config{}, dest, conn)
+		assert.NilError(t, err)
+		assert.NilError(t, proc.Process(context.Background(), technicianEntry))
+		assert.NilError(t, proc.Process(context.Background(), technicianEntry))
+
+		pushes := sb.Pushes()
+		assert.Equal(t, len(pushes), 3)
+		assert.Equal(t, pushes[0].Action, "create")
+		assert.Equal(t, pushes[1].Action, "append")
+		assert.Equal(t, len(pushes[1].Data), 4)
+		assert.Equal(t, pushes[1].Data[0]["name"], "John Smith")
+
+		ds := sb.Datasets()["technicians"]
+		assert.Equal(t, len(ds.Data), 8)
+		assert.Equal(t, ds.Schema.Fields["completedjobs"].Type, geckoboard.FieldType("number"))
+
+		lines := bytes.Split(bytes.TrimSpace(record.Bytes()), []byte("\n"))
+		assert.Equal(t, len(lines), 3)
+
+		got := Push{}
+		assert.NilError(t, json.Unmarshal(lines[2], &got))
+		assert.Equal(t, got.Dataset, "technicians")
+		assert.Equal(t, len(got.Data), 4)
+	})
+
+	t.Run("replaces the dataset data", func(t *testing.T) {
+		sb, conn, dest := startSandbox(t, loadDefaultFixtures(t), nil)
+
+		entry := technicianEntry
+		entry.Dataset.Type = "replace"
+
+		proc, err := processor.New(&config.Config{}, dest, conn)
+		assert.NilError(t, err)
+		assert.NilError(t, proc.Process(context.Background(), entry))
+		assert.NilError(t, proc.Process(context.Background(), entry))
+
+		assert.Equal(t, len(sb.Datasets()["technicians"].Data), 4)
+	})
+
+	t.Run("returns error when a required parameter is missing", func(t *testing.T) {
+		_, conn, dest := startSandbox(t, loadDefaultFixtures(t), nil)
+
+		entry := technicianEntry
+		entry.Report.Parameters = entry.Report.Parameters[:1]
+
+		proc, err := processor.New(&config.Config{}, dest, conn)
+		assert.NilError(t, err)
+
+		err = proc.Process(context.Background(), entry)
+		assert.ErrorContains(t, err, "The To parameter is required")
+	})
+
+	t.Run("returns error when the geckoboard api key is rejected", func(t *testing.T) {
+		fixtures := loadDefaultFixtures(t)
+		fixtures.GeckoboardAPIKeys = []string{"other-key"}
+		_, conn, dest := startSandbox(t, fixtures, nil)
+
+		proc, err := processor.New(&config.Config{}, dest, conn)
+		assert.NilError(t, err)
+
+		err = proc.Process(context.Background(), technicianEntry)
+		assert.ErrorContains(t, err, "Your API key is invalid")
+	})
+}
+
+func TestSandbox_ServiceTitan(t *testing.T) {
+	t.Run("rate limits the report data requests of each tenant", func(t *testing.T) {
+		fixtures := loadDefaultFixtures(t)
+		fixtures.RateLimit = RateLimit{Requests: 1, Interval: time.Minute}
+		_, conn, _ := startSandbox(t, fixtures, nil)
+
+		req := servicetitan.ReportDataRequest{CategoryID: "marketing", ReportID: "2001", Parameters: []servicetitan.DataRequestParamters{
+			{Name: "DateType", Value: 1}, {Name: "From", Value: "2022-06-01"}, {Name: "To", Value: "2022-06-07"},
+		}}
+
+		client, err := servicetitan.New(conn)
+		assert.NilError(t, err)
+
+		data, err := client.ReportService.GetReportData(context.Background(), req, nil)
+		assert.NilError(t, err)
+		assert.Equal(t, len(data.Data), 3)
+
+		_, err = client.ReportService.GetReportData(context.Background(), req, nil)
+
+		var stErr *servicetitan.Error
+		assert.Assert(t, errors.As(err, &stErr))
+		assert.Equal(t, stErr.StatusCode, http.StatusTooManyRequests)
+
+		conn.TenantID = "5678"
+		other, err := servicetitan.New(conn)
+		assert.NilError(t, err)
+
+		_, err = other.ReportService.GetReportData(context.Background(), req, nil)
+		assert.NilError(t, err)
+	})
+
+	t.Run("returns method not allowed for a report list request which isn't a GET", func(t *testing.T) {
+		sb := New(loadDefaultFixtures(t), nil)
+
+		rec := httptest.NewRecorder()
+		sb.reporting(rec, httptest.NewRequest(http.MethodPost, reportingPrefix+"1234/report-category/marketing/reports", nil))
+
+		assert.Equal(t, rec.Code, http.StatusMethodNotAllowed)
+		assert.Equal(t, rec.Header().Get("Allow"), http.MethodGet)
+	})
+
+	t.Run("injects the faults", func(t *testing.T) {
+		fixtures := loadDefaultFixtures(t)
+		fixtures.Faults = []Fault{
+			{Path: "/reporting/v2/tenant/*/report-category/*/reports", Status: http.StatusInternalServerError, Count: 1},
+			{Method: "POST", Path: "/reporting/v2/tenant/*/report-category/*/reports/*/data", Status: http.StatusBadGateway, Body: "bad gateway"},
+		}
+		_, conn, _ := startSandbox(t, fixtures, nil)
+
+		client, err := servicetitan.New(conn)
+		assert.NilError(t, err)
+
+		_, err = client.ReportService.GetReports(context.Background(), servicetitan.Category{ID: "marketing"}, nil)
+		assert.ErrorContains(t, err, "Sandbox fault: Internal Server Error")
+
+		_, err = client.ReportService.GetReports(context.Background(), servicetitan.Category{ID: "marketing"}, nil)
+		assert.NilError(t, err)
+
+		_, err = client.ReportService.GetReportData(context.Background(), servicetitan.ReportDataRequest{CategoryID: "marketing", ReportID: "2001"}, nil)
+		assert.Error(t, err, `ServiceTitan error: bad gateway got response code 502 for request path "/reporting/v2/tenant/1234/report-category/marketing/reports/2001/data"`)
+	})
+}
+
+func TestLoadFixtures(t *testing.T) {
+	t.Run("loads the built in fixtures", func(t *testing.T) {
+		fixtures, err := LoadFixtures("")
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(fixtures.Categories), 2)
+		assert.Equal(t, fixtures.Categories[0].Reports[0].ID, 1001)
+	})
+
+	t.Run("loads the fixtures file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "fixtures.yml")
+		assert.NilError(t, os.WriteFile(path, []byte(`
+app_id: app
+tenant_ids: [1234]
+rate_limit:
+  requests: 2
+  interval: 5m
+faults:
+  - path: /connect/token
+    status: 500
+categories:
+  - id: cat
+    reports:
+      - id: 1
+        fields: [{name: Name, type: String}]
+        rows: [[a], [b]]
+`), 0o600))
+
+		fixtures, err := LoadFixtures(path)
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, fixtures.TenantIDs, []string{"1234"})
+		assert.DeepEqual(t, fixtures.RateLimit, RateLimit{Requests: 2, Interval: 5 * time.Minute})
+		assert.DeepEqual(t, fixtures.Faults, []Fault{{Path: "/connect/token", Status: 500}})
+		assert.Equal(t, len(fixtures.Categories[0].Reports[0].Rows), 2)
+	})
+
+	t.Run("returns error when a row doesn't match the fields", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "fixtures.yml")
+		assert.NilError(t, os.WriteFile(path, []byte(`
+categories:
+  - id: cat
+    reports:
+      - id: 1
+        fields: [{name: Name, type: String}]
+        rows: [[a, b]]
+`), 0o600))
+
+		_, err := LoadFixtures(path)
+		assert.Error(t, err, "report 1 row 1 has 2 values but there are 1 fields")
+	})
+
+	t.Run("returns error for unknown keys", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "fixtures.yml")
+		assert.NilError(t, os.WriteFile(path, []byte("categorys: []\n"), 0o600))
+
+		_, err := LoadFixtures(path)
+		assert.ErrorContains(t, err, "field categorys not found")
+	})
+}
@@ -1,42 +1,102 @@
 package servicetitan
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/httplog"
+	"servicetitan-to-dataset/tracing"
+	"strconv"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Client is safe for concurrent use, requests share the session
+// and only one request fetches a new token when it has expired
 type Client struct {
-	client  *http.Client
-	config  config.ServiceTitan
-	session *Session
+	client *http.Client
+	config config.ServiceTitan
+
+	// mu guards the session and the in flight token call
+	mu         sync.Mutex
+	session    *Session
+	tokenCall  *tokenCall
+	tokenCache TokenCache
 
 	AuthService   AuthService
 	ReportService ReportService
+	ExportService ExportService
 }
 
 func New(cfg config.ServiceTitan) (*Client, error) {
+	httpClient, err := buildHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	c := &Client{
-		client: &http.Client{Timeout: 30 * time.Second},
+		client: httpClient,
 		config: cfg,
 	}
 
 	c.AuthService = authService{
-		baseURL: "https://auth.servicetitan.io",
+		baseURL: cfg.BaseAuthURL(),
 		client:  c,
 	}
 	c.ReportService = reportService{
-		baseURL: fmt.Sprintf("https://api.servicetitan.io/reporting/v2/tenant/%s", cfg.TenantID),
+		baseURL: fmt.Sprintf("%s/reporting/v2/tenant/%s", cfg.BaseAPIURL(), cfg.TenantID),
 		client:  c,
 	}
+	c.ExportService = exportService{
+		baseURL:  cfg.BaseAPIURL(),
+		tenantID: cfg.TenantID,
+		client:   c,
+	}
 
 	return c, nil
 }
 
+// defaultTransport is cloned for each client so the proxy and
+// certificates of one connection don't change the other clients
+var defaultTransport = http.DefaultTransport.(*http.Transport)
+
+// buildHTTPClient returns a http client using the proxy and custom
+// certificate authority from the config when set, which logs its
+// requests when --debug-http is enabled
+func buildHTTPClient(cfg config.ServiceTitan) (*http.Client, error) {
+	transport := defaultTransport.Clone()
+
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url: %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if cfg.CACertFile != "" {
+		pool, err := config.LoadCACertPool(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca_cert_file %w", err)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Timeout: 30 * time.Second, Transport: httplog.Wrap(transport)}, nil
+}
+
 func (c *Client) buildURL(baseURL, path string, params url.Values) string {
 	return fmt.Sprintf("%s?%s", baseURL+path, params.Encode())
 }
@@ -59,27 +119,209 @@ func (c *Client) buildPOSTRequest(url string, body io.Reader) (*http.Request, er
 	return r, nil
 }
 
-func (c *Client) addAuthorization(r *http.Request) (err error) {
-	defer func() {
-		if err == nil {
-			r.Header.Add("Authorization", c.session.Token)
-		}
-	}()
+// tokenCall is a token request which other requests wait on
+type tokenCall struct {
+	done    chan struct{}
+	session *Session
+	err     error
+}
+
+// tokenFetchTimeout limits a token request, which isn't
+// cancelled by the request that started it
+const tokenFetchTimeout = 30 * time.Second
+
+// detachedContext keeps the values of its parent, such as the trace
+// span, but isn't cancelled or given a deadline by the parent
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// detach returns a context like context.WithoutCancel, which
+// isn't available in the Go version this module supports
+func detach(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+
+	return detachedContext{Context: ctx}
+}
+
+// authSession returns the current session, fetching a new token when
+// there isn't a session or it has expired. Concurrent callers wait on
+// the same token request rather than each fetching their own token
+func (c *Client) authSession(ctx context.Context) (*Session, error) {
+	c.mu.Lock()
 
 	if c.session != nil && !c.session.IsExpired() {
-		return nil
+		session := c.session
+		c.mu.Unlock()
+		return session, nil
 	}
 
-	c.session, err = c.AuthService.GetToken(r.Context(), c.config)
-	return err
+	call := c.tokenCall
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		c.tokenCall = call
+		c.mu.Unlock()
+
+		// The token is shared by every waiting request, so it's fetched
+		// without this request's cancellation which would fail them all
+		go c.fetchToken(detach(ctx), call)
+	} else {
+		c.mu.Unlock()
+	}
+
+	select {
+	case <-call.done:
+		return call.session, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) fetchToken(ctx context.Context, call *tokenCall) {
+	var err error
+
+	ctx, cancel := context.WithTimeout(ctx, tokenFetchTimeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "servicetitan.FetchToken", trace.WithAttributes(
+		attribute.String("servicetitan.tenant", c.config.Label()),
+	))
+
+	session := c.cachedSession()
+	span.SetAttributes(attribute.Bool("servicetitan.token_cached", session != nil))
+
+	if session == nil {
+		session, err = c.newSession(ctx)
+	}
+
+	tracing.End(span, err)
+
+	c.mu.Lock()
+	if err == nil {
+		c.session = session
+	}
+	c.tokenCall = nil
+	c.mu.Unlock()
+
+	call.session, call.err = session, err
+	close(call.done)
 }
 
 func (c *Client) doRequest(req *http.Request, resource interface{}) error {
-	if authstep, _ := req.Context().Value("authStep").(authStep); !authstep {
-		c.addAuthorization(req)
-		req.Header.Add("ST-App-Key", c.config.AppID)
+	if authstep, _ := req.Context().Value("authStep").(authStep); authstep {
+		return c.sendRequest(req, resource)
 	}
 
+	if err := makeBodyReplayable(req); err != nil {
+		return err
+	}
+
+	err := c.doAuthorizedRequest(req, resource)
+	if !isUnauthorized(err) {
+		return err
+	}
+
+	// The token can be revoked before it expires, so retry once with a new token
+	trace.SpanFromContext(req.Context()).AddEvent("token rejected, retrying with a new token")
+
+	retry, err := cloneRequest(req)
+	if err != nil {
+		return err
+	}
+
+	err = c.doAuthorizedRequest(retry, resource)
+	if isUnauthorized(err) {
+		return &AuthenticationError{
+			Err: fmt.Errorf("a new token was also rejected, please check the app id, client id and secret: %w", err),
+		}
+	}
+
+	return err
+}
+
+// doAuthorizedRequest sends the request with the session token, the
+// session is discarded when ServiceTitan responds the token is invalid
+func (c *Client) doAuthorizedRequest(req *http.Request, resource interface{}) error {
+	session, err := c.authSession(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", session.Token)
+	req.Header.Set("ST-App-Key", c.config.AppID)
+
+	err = c.sendRequest(req, resource)
+	if isUnauthorized(err) {
+		c.discardSession(session)
+	}
+
+	return err
+}
+
+// isAuthRejected reports whether ServiceTitan rejected the token request, such
+// as for an invalid client id or secret, rather than it failing to be sent
+func isAuthRejected(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500
+}
+
+// cachedSession returns the session from the token cache when it hasn't
+// expired, a cache which can't be read is treated as having no session
+func (c *Client) cachedSession() *Session {
+	if c.tokenCache == nil {
+		return nil
+	}
+
+	session, err := c.tokenCache.Load(c.config)
+	if err != nil || session == nil || session.IsExpired() {
+		return nil
+	}
+
+	return session
+}
+
+func (c *Client) newSession(ctx context.Context) (*Session, error) {
+	session, err := c.AuthService.GetToken(ctx, c.config)
+	if isAuthRejected(err) {
+		return nil, &AuthenticationError{Err: err}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.tokenCache != nil {
+		// The cache only saves the next run fetching a token,
+		// so failing to save it doesn't fail the request
+		_ = c.tokenCache.Save(c.config, session)
+	}
+
+	return session, nil
+}
+
+// discardSession removes the session and the cached token unless
+// another request has already replaced it with a new one
+func (c *Client) discardSession(session *Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session != session {
+		return
+	}
+
+	c.session = nil
+	if c.tokenCache != nil {
+		_ = c.tokenCache.Delete(c.config)
+	}
+}
+
+func (c *Client) sendRequest(req *http.Request, resource interface{}) error {
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return err
@@ -87,10 +329,16 @@ func (c *Client) doRequest(req *http.Request, resource interface{}) error {
 
 	defer resp.Body.Close()
 
+	trace.SpanFromContext(req.Context()).SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
 	if err := c.checkResponse(resp); err != nil {
 		return err
 	}
 
+	if dec, ok := resource.(responseDecoder); ok {
+		return dec.decode(resp.Body)
+	}
+
 	if resource != nil {
 		d := json.NewDecoder(resp.Body)
 		if err := d.Decode(&resource); err != nil {
@@ -101,6 +349,52 @@ func (c *Client) doRequest(req *http.Request, resource interface{}) error {
 	return nil
 }
 
+// responseDecoder is a resource which decodes the response body itself,
+// such as report data which is read a row at a time
+type responseDecoder interface {
+	decode(io.Reader) error
+}
+
+// makeBodyReplayable reads the request body into memory when
+// the request can't already return a new copy of the body
+func makeBodyReplayable(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody == nil {
+		return clone, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	clone.Body = body
+	return clone, nil
+}
+
+func isUnauthorized(err error) bool {
+	var stErr *Error
+	return errors.As(err, &stErr) && stErr.StatusCode == http.StatusUnauthorized
+}
+
 func (c *Client) checkResponse(resp *http.Response) error {
 	if resp.StatusCode == http.StatusOK {
 		return nil
@@ -111,9 +405,16 @@ func (c *Client) checkResponse(resp *http.Response) error {
 		return err
 	}
 
-	return &Error{
+	apiErr := &Error{
 		StatusCode:  resp.StatusCode,
 		RequestPath: resp.Request.URL.Path,
 		Message:     string(b),
 	}
+
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+		wait := time.Duration(secs) * time.Second
+		apiErr.RetryAfter = &wait
+	}
+
+	return apiErr
 }
@@ -2,14 +2,29 @@ package servicetitan
 
 import (
 	"context"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/httplog"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	"gotest.tools/v3/assert"
 )
 
@@ -33,6 +48,84 @@ func TestClient_New(t *testing.T) {
 	})
 }
 
+func TestClient_NewWithEnvironment(t *testing.T) {
+	t.Run("uses the integration environment urls", func(t *testing.T) {
+		c, err := New(config.ServiceTitan{TenantID: "tenant_123", Environment: "integration"})
+		assert.NilError(t, err)
+
+		assert.Equal(t, c.AuthService.(authService).baseURL, "https://auth-integration.servicetitan.io")
+		assert.Equal(t, c.ReportService.(reportService).baseURL, "https://api-integration.servicetitan.io/reporting/v2/tenant/tenant_123")
+	})
+
+	t.Run("uses the overridden urls", func(t *testing.T) {
+		c, err := New(config.ServiceTitan{
+			TenantID: "tenant_123",
+			AuthURL:  "http://localhost:9000",
+			APIURL:   "http://localhost:9001/",
+		})
+		assert.NilError(t, err)
+
+		assert.Equal(t, c.AuthService.(authService).baseURL, "http://localhost:9000")
+		assert.Equal(t, c.ReportService.(reportService).baseURL, "http://localhost:9001/reporting/v2/tenant/tenant_123")
+	})
+
+	t.Run("uses the proxy url", func(t *testing.T) {
+		c, err := New(config.ServiceTitan{ProxyURL: "http://proxy.internal:3128"})
+		assert.NilError(t, err)
+
+		req, _ := http.NewRequest(http.MethodGet, "https://api.servicetitan.io", nil)
+		got, err := c.client.Transport.(*http.Transport).Proxy(req)
+		assert.NilError(t, err)
+		assert.Equal(t, got.String(), "http://proxy.internal:3128")
+	})
+
+	t.Run("trusts the custom certificate authority", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "{}")
+		}))
+		defer server.Close()
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		assert.NilError(t, os.WriteFile(caFile, pemBytes, 0o600))
+
+		c, err := New(config.ServiceTitan{CACertFile: caFile})
+		assert.NilError(t, err)
+
+		resp, err := c.client.Get(server.URL)
+		assert.NilError(t, err)
+		resp.Body.Close()
+	})
+
+	t.Run("logs the requests when debug logging is enabled", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "{}")
+		}))
+		defer server.Close()
+
+		var logs strings.Builder
+		httplog.Enable(httplog.Options{Logger: log.New(&logs, "", 0)})
+		t.Cleanup(httplog.Disable)
+
+		c, err := New(config.ServiceTitan{TenantID: "tenant_123"})
+		assert.NilError(t, err)
+
+		resp, err := c.client.Get(server.URL + "/ping")
+		assert.NilError(t, err)
+		resp.Body.Close()
+
+		assert.Assert(t, strings.Contains(logs.String(), "--> GET "+server.URL+"/ping"), logs.String())
+	})
+
+	t.Run("returns error when the ca cert file is invalid", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		assert.NilError(t, os.WriteFile(caFile, []byte("not a cert"), 0o600))
+
+		_, err := New(config.ServiceTitan{CACertFile: caFile})
+		assert.Error(t, err, "ca_cert_file contains no valid PEM encoded certificates")
+	})
+}
+
 func TestClient_Authorization(t *testing.T) {
 	t.Run("creates new session on first request", func(t *testing.T) {
 		authCalls := 0
@@ -148,6 +241,332 @@ func TestClient_Authorization(t *testing.T) {
 		assert.Equal(t, authCalls, 2)
 		assert.Equal(t, reportCalls, 3)
 	})
+
+	t.Run("concurrent requests wait on one token request", func(t *testing.T) {
+		var authCalls, reportCalls int32
+
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&reportCalls, 1)
+			assert.Equal(t, r.Header.Get("Authorization"), "tok_1234")
+			io.WriteString(w, "{}")
+		})
+
+		c := &Client{client: http.DefaultClient}
+		c.AuthService = &mockAuthService{
+			getTokenFn: func() (*Session, error) {
+				atomic.AddInt32(&authCalls, 1)
+				time.Sleep(50 * time.Millisecond)
+
+				return &Session{
+					Token:     "tok_1234",
+					ExpiresAt: time.Now().UTC().Add(2 * time.Minute),
+				}, nil
+			},
+		}
+		c.ReportService = mockReportService{
+			client:  c,
+			baseURL: server.URL,
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := c.ReportService.GetCategories(nil, nil)
+				assert.Check(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, atomic.LoadInt32(&authCalls), int32(1))
+		assert.Equal(t, atomic.LoadInt32(&reportCalls), int32(10))
+	})
+
+	t.Run("returns authentication error when the token request fails", func(t *testing.T) {
+		reportCalls := 0
+
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			reportCalls++
+		})
+
+		c := &Client{client: http.DefaultClient}
+		c.AuthService = &mockAuthService{
+			getTokenFn: func() (*Session, error) {
+				return nil, &Error{
+					StatusCode:  http.StatusBadRequest,
+					RequestPath: "/connect/token",
+					Message:     `{"error":"invalid_client"}`,
+				}
+			},
+		}
+		c.ReportService = mockReportService{
+			client:  c,
+			baseURL: server.URL,
+		}
+
+		_, err := c.ReportService.GetCategories(nil, nil)
+		assert.ErrorIs(t, err, ErrAuthentication)
+		assert.Error(t, err, `ServiceTitan authentication failed: ServiceTitan error: {"error":"invalid_client"} got response code 400 for request path "/connect/token"`)
+
+		var stErr *Error
+		assert.Assert(t, errors.As(err, &stErr))
+		assert.Equal(t, stErr.StatusCode, http.StatusBadRequest)
+
+		assert.Equal(t, reportCalls, 0)
+		assert.Assert(t, c.session == nil)
+	})
+
+	t.Run("returns the error without authentication failed when the token request isn't sent", func(t *testing.T) {
+		c := &Client{client: http.DefaultClient}
+		c.AuthService = &mockAuthService{
+			getTokenFn: func() (*Session, error) {
+				return nil, context.DeadlineExceeded
+			},
+		}
+
+		_, err := c.authSession(context.Background())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Assert(t, !errors.Is(err, ErrAuthentication))
+	})
+
+	t.Run("waiting requests get the token when the request fetching it is cancelled", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		c := &Client{client: http.DefaultClient}
+		c.AuthService = authServiceFunc(func(ctx context.Context) (*Session, error) {
+			close(started)
+			<-release
+
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			return &Session{Token: "tok_1234", ExpiresAt: time.Now().UTC().Add(5 * time.Minute)}, nil
+		})
+
+		leaderCtx, cancel := context.WithCancel(context.Background())
+		leaderErr := make(chan error, 1)
+		go func() {
+			_, err := c.authSession(leaderCtx)
+			leaderErr <- err
+		}()
+
+		<-started
+		waiter := make(chan *Session, 1)
+		go func() {
+			session, err := c.authSession(context.Background())
+			assert.Check(t, err)
+			waiter <- session
+		}()
+
+		cancel()
+		assert.ErrorIs(t, <-leaderErr, context.Canceled)
+
+		close(release)
+		assert.Equal(t, (<-waiter).Token, "tok_1234")
+	})
+
+	t.Run("retries with a new token when the token is rejected", func(t *testing.T) {
+		bodies := []string{}
+
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			assert.NilError(t, err)
+			bodies = append(bodies, string(b))
+
+			if r.Header.Get("Authorization") == "tok_1231" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			assert.Equal(t, r.Header.Get("Authorization"), "tok_1232")
+			io.WriteString(w, `{"fields":[],"data":[]}`)
+		})
+
+		auth := &mockAuthService{}
+		c := &Client{client: http.DefaultClient, AuthService: auth}
+		srv := reportService{baseURL: server.URL, client: c}
+
+		_, err := srv.GetReportData(context.Background(), ReportDataRequest{CategoryID: "cat-1", ReportID: "rpt-1"}, nil)
+		assert.NilError(t, err)
+
+		assert.Equal(t, auth.calls, 2)
+		assert.Equal(t, len(bodies), 2)
+		assert.Equal(t, bodies[1], bodies[0])
+		assert.Equal(t, c.session.Token, "tok_1232")
+	})
+
+	t.Run("replays a body which can't be read again", func(t *testing.T) {
+		bodies := []string{}
+
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			assert.NilError(t, err)
+			bodies = append(bodies, string(b))
+
+			if r.Header.Get("Authorization") == "tok_1231" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		})
+
+		c := &Client{client: http.DefaultClient, AuthService: &mockAuthService{}}
+
+		req, err := http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader(`{"a":1}`)))
+		assert.NilError(t, err)
+
+		assert.NilError(t, c.doRequest(req, nil))
+		assert.DeepEqual(t, bodies, []string{`{"a":1}`, `{"a":1}`})
+	})
+
+	t.Run("returns authentication error when the new token is also rejected", func(t *testing.T) {
+		reportCalls := 0
+
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			reportCalls++
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, "invalid token")
+		})
+
+		auth := &mockAuthService{}
+		c := &Client{client: http.DefaultClient, AuthService: auth}
+		srv := reportService{baseURL: server.URL, client: c}
+
+		_, err := srv.GetCategories(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrAuthentication)
+		assert.Error(t, err, `ServiceTitan authentication failed: a new token was also rejected, please check the app id, client id and secret: ServiceTitan error: invalid token got response code 401 for request path "/report-categories"`)
+
+		assert.Equal(t, auth.calls, 2)
+		assert.Equal(t, reportCalls, 2)
+	})
+}
+
+func TestClient_TokenCache(t *testing.T) {
+	cfg := config.ServiceTitan{TenantID: "tenant_123", ClientID: "cid_123", ClientSecret: "secret_123"}
+
+	buildClient := func(t *testing.T, cache TokenCache, token string) (*Client, *mockAuthService) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, r.Header.Get("Authorization"), token)
+			io.WriteString(w, "{}")
+		})
+
+		auth := &mockAuthService{}
+		c := &Client{client: http.DefaultClient, config: cfg, tokenCache: cache, AuthService: auth}
+		c.ReportService = reportService{baseURL: server.URL, client: c}
+
+		return c, auth
+	}
+
+	t.Run("uses the cached token", func(t *testing.T) {
+		cache, err := NewFileTokenCache(t.TempDir())
+		assert.NilError(t, err)
+		assert.NilError(t, cache.Save(cfg, &Session{Token: "tok_cached", ExpiresAt: time.Now().UTC().Add(time.Hour)}))
+
+		c, auth := buildClient(t, cache, "tok_cached")
+
+		_, err = c.ReportService.GetCategories(context.Background(), nil)
+		assert.NilError(t, err)
+		assert.Equal(t, auth.calls, 0)
+	})
+
+	t.Run("fetches and caches a token when the cached token has expired", func(t *testing.T) {
+		cache, err := NewFileTokenCache(t.TempDir())
+		assert.NilError(t, err)
+		assert.NilError(t, cache.Save(cfg, &Session{Token: "tok_cached", ExpiresAt: time.Now().UTC()}))
+
+		c, auth := buildClient(t, cache, "tok_1231")
+
+		_, err = c.ReportService.GetCategories(context.Background(), nil)
+		assert.NilError(t, err)
+		assert.Equal(t, auth.calls, 1)
+
+		got, err := cache.Load(cfg)
+		assert.NilError(t, err)
+		assert.Equal(t, got.Token, "tok_1231")
+	})
+
+	t.Run("removes the cached token when it is rejected", func(t *testing.T) {
+		cache, err := NewFileTokenCache(t.TempDir())
+		assert.NilError(t, err)
+		assert.NilError(t, cache.Save(cfg, &Session{Token: "tok_revoked", ExpiresAt: time.Now().UTC().Add(time.Hour)}))
+
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "tok_revoked" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			io.WriteString(w, "{}")
+		})
+
+		auth := &mockAuthService{}
+		c := &Client{client: http.DefaultClient, config: cfg, tokenCache: cache, AuthService: auth}
+		c.ReportService = reportService{baseURL: server.URL, client: c}
+
+		_, err = c.ReportService.GetCategories(context.Background(), nil)
+		assert.NilError(t, err)
+		assert.Equal(t, auth.calls, 1)
+
+		got, err := cache.Load(cfg)
+		assert.NilError(t, err)
+		assert.Equal(t, got.Token, "tok_1231")
+	})
+}
+
+func TestClient_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+
+	server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"fields":[],"data":[["a"],["b"]],"hasMore":true,"totalCount":10}`)
+	})
+
+	c := &Client{client: http.DefaultClient, config: config.ServiceTitan{TenantID: "tenant_123"}, AuthService: &mockAuthService{}}
+	srv := reportService{baseURL: server.URL, client: c}
+
+	_, err := srv.GetReportData(context.Background(), ReportDataRequest{CategoryID: "cat-1", ReportID: "rpt-1"}, &PaginationOptions{Page: 2, PageSize: 2})
+	assert.NilError(t, err)
+
+	spans := recorder.Ended()
+	assert.Equal(t, len(spans), 2)
+
+	token, data := spans[0], spans[1]
+	assert.Equal(t, token.Name(), "servicetitan.FetchToken")
+	assert.Equal(t, token.Parent().SpanID(), data.SpanContext().SpanID())
+	assert.DeepEqual(t, spanAttributes(token), map[attribute.Key]string{
+		"servicetitan.tenant":       "tenant_123",
+		"servicetitan.token_cached": "false",
+	})
+
+	assert.Equal(t, data.Name(), "servicetitan.GetReportData")
+	assert.DeepEqual(t, spanAttributes(data), map[attribute.Key]string{
+		"servicetitan.category_id": "cat-1",
+		"servicetitan.report_id":   "rpt-1",
+		"servicetitan.page":        "2",
+		"servicetitan.page_size":   "2",
+		"http.status_code":         "200",
+		"servicetitan.rows":        "2",
+		"servicetitan.total_rows":  "10",
+		"servicetitan.has_more":    "true",
+	})
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+	attrs := map[attribute.Key]string{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+
+	return attrs
+}
+
+// authServiceFunc is an auth service which is given the context of the token request
+type authServiceFunc func(context.Context) (*Session, error)
+
+func (f authServiceFunc) GetToken(ctx context.Context, _ config.ServiceTitan) (*Session, error) {
+	return f(ctx)
 }
 
 type mockAuthService struct {
@@ -176,27 +595,29 @@ type mockReportService struct {
 func (r mockReportService) GetCategories(context.Context, *PaginationOptions) (*CategoryList, error) {
 	url, _ := url.Parse(r.baseURL)
 	req := &http.Request{URL: url, Header: http.Header{}}
-	r.client.doRequest(req, nil)
-	return nil, nil
+	return nil, r.client.doRequest(req, nil)
 }
 
 func (r mockReportService) GetReports(context.Context, Category, *PaginationOptions) (*ReportList, error) {
 	url, _ := url.Parse(r.baseURL)
 	req := &http.Request{URL: url, Header: http.Header{}}
-	r.client.doRequest(req, nil)
-	return nil, nil
+	return nil, r.client.doRequest(req, nil)
 }
 
 func (r mockReportService) GetReport(context.Context, string, string) (*Report, error) {
 	url, _ := url.Parse(r.baseURL)
 	req := &http.Request{URL: url, Header: http.Header{}}
-	r.client.doRequest(req, nil)
-	return nil, nil
+	return nil, r.client.doRequest(req, nil)
 }
 
 func (r mockReportService) GetReportData(context.Context, ReportDataRequest, *PaginationOptions) (*ReportData, error) {
 	url, _ := url.Parse(r.baseURL)
 	req := &http.Request{URL: url, Header: http.Header{}}
-	r.client.doRequest(req, nil)
-	return nil, nil
+	return nil, r.client.doRequest(req, nil)
+}
+
+func (r mockReportService) StreamReportData(context.Context, ReportDataRequest, *PaginationOptions, RowFunc) (*ReportData, error) {
+	url, _ := url.Parse(r.baseURL)
+	req := &http.Request{URL: url, Header: http.Header{}}
+	return nil, r.client.doRequest(req, nil)
 }
@@ -1,11 +1,21 @@
 package servicetitan
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrAuthentication is matched by errors.Is when a token couldn't be
+// fetched, such as when the client id or secret are rejected
+var ErrAuthentication = errors.New("ServiceTitan authentication failed")
 
 type Error struct {
 	StatusCode  int
 	RequestPath string
 	Message     string
+	// RetryAfter is from the Retry-After header of a rate limited response
+	RetryAfter *time.Duration
 }
 
 func (e *Error) Error() string {
@@ -14,3 +24,21 @@ func (e *Error) Error() string {
 
 	return msg + " " + extra
 }
+
+// AuthenticationError is returned when fetching a token fails, Err
+// is the *Error with the ServiceTitan response when there was one
+type AuthenticationError struct {
+	Err error
+}
+
+func (e *AuthenticationError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrAuthentication, e.Err)
+}
+
+func (e *AuthenticationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AuthenticationError) Is(target error) bool {
+	return target == ErrAuthentication
+}
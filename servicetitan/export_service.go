@@ -0,0 +1,59 @@
+package servicetitan
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"servicetitan-to-dataset/tracing"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ExportService interface {
+	GetExport(context.Context, ExportRequest) (*ExportData, error)
+}
+
+type exportService struct {
+	baseURL  string
+	tenantID string
+	client   *Client
+}
+
+func (e exportService) GetExport(ctx context.Context, opts ExportRequest) (_ *ExportData, err error) {
+	ctx, span := tracer.Start(ctx, "servicetitan.GetExport", trace.WithAttributes(
+		attribute.String("servicetitan.export", opts.Resource),
+		attribute.Bool("servicetitan.continued", opts.From != ""),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	module, name, ok := strings.Cut(opts.Resource, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid export resource %q", opts.Resource)
+	}
+
+	params := url.Values{"includeRecentChanges": {strconv.FormatBool(opts.IncludeRecentChanges)}}
+	if opts.From != "" {
+		params.Set("from", opts.From)
+	}
+
+	path := fmt.Sprintf("/%s/v2/tenant/%s/export/%s", module, e.tenantID, name)
+	req, err := e.client.buildGETRequest(e.client.buildURL(e.baseURL, path, params))
+	if err != nil {
+		return nil, err
+	}
+
+	data := &ExportData{}
+	if err := e.client.doRequest(req.WithContext(ctx), data); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.Int("servicetitan.rows", len(data.Data)),
+		attribute.Bool("servicetitan.has_more", data.HasMore),
+	)
+
+	return data, nil
+}
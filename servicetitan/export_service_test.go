@@ -0,0 +1,72 @@
+package servicetitan
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestExportService_GetExport(t *testing.T) {
+	t.Run("fetches the export from the start", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, r.Method, http.MethodGet)
+			assert.Equal(t, r.URL.Path, "/jpm/v2/tenant/ten_1/export/jobs")
+			assert.Equal(t, r.URL.Query().Has("from"), false)
+			assert.Equal(t, r.URL.Query().Get("includeRecentChanges"), "false")
+			assert.Equal(t, r.Header.Get("Authorization"), "tok_1230")
+
+			io.WriteString(w, `{"data": [{"id": 1, "jobStatus": "Completed"}], "hasMore": true, "continueFrom": "token-2"}`)
+		})
+
+		defer server.Close()
+
+		srv := exportService{baseURL: server.URL, tenantID: "ten_1", client: buildClient()}
+		got, err := srv.GetExport(context.Background(), ExportRequest{Resource: "jpm/jobs"})
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, got, &ExportData{
+			Data:         []map[string]interface{}{{"id": float64(1), "jobStatus": "Completed"}},
+			HasMore:      true,
+			ContinueFrom: "token-2",
+		})
+	})
+
+	t.Run("continues from the token", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, r.URL.Path, "/accounting/v2/tenant/ten_1/export/invoices")
+			assert.Equal(t, r.URL.Query().Get("from"), "token-2")
+			assert.Equal(t, r.URL.Query().Get("includeRecentChanges"), "true")
+
+			io.WriteString(w, `{"data": [], "hasMore": false, "continueFrom": "token-3"}`)
+		})
+
+		defer server.Close()
+
+		srv := exportService{baseURL: server.URL, tenantID: "ten_1", client: buildClient()}
+		got, err := srv.GetExport(context.Background(), ExportRequest{Resource: "accounting/invoices", From: "token-2", IncludeRecentChanges: true})
+		assert.NilError(t, err)
+		assert.Equal(t, got.ContinueFrom, "token-3")
+	})
+
+	t.Run("returns error for an invalid resource", func(t *testing.T) {
+		srv := exportService{client: buildClient()}
+		_, err := srv.GetExport(context.Background(), ExportRequest{Resource: "jobs"})
+		assert.Error(t, err, `invalid export resource "jobs"`)
+	})
+
+	t.Run("returns error when non 200 response code", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, "error invalid token")
+		})
+
+		defer server.Close()
+
+		srv := exportService{baseURL: server.URL, tenantID: "ten_1", client: buildClient()}
+		_, err := srv.GetExport(context.Background(), ExportRequest{Resource: "crm/customers"})
+		assert.ErrorContains(t, err, "got response code 403")
+	})
+}
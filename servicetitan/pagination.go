@@ -0,0 +1,201 @@
+package servicetitan
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Seq2 is an iterator of pairs like iter.Seq2, which isn't available in the
+// Go version this module supports. It's called with a yield func which returns
+// false to stop the iterator and can be ranged over once the module allows it
+type Seq2[K, V any] func(yield func(K, V) bool)
+
+// PageOptions is how an iterator pages through a list endpoint
+type PageOptions struct {
+	// PageSize is the items fetched with each request, the default is 50
+	PageSize int
+	// MaxPages stops the iterator after the number of pages, 0 fetches every page
+	MaxPages int
+}
+
+// ReportRow is a row of report data with the fields of its values
+type ReportRow struct {
+	Fields []ReportField
+	Values []interface{}
+}
+
+const (
+	defaultPageSize = 50
+
+	// maxRateLimitRetries is how many times a rate limited page is
+	// fetched again before the iterator yields the rate limit error
+	maxRateLimitRetries = 3
+)
+
+// defaultRetryAfter is the wait for a rate limited response without a Retry-After header
+var defaultRetryAfter = 5 * time.Second
+
+// Categories iterates over every page of report categories
+func Categories(ctx context.Context, service ReportService, opts PageOptions) Seq2[Category, error] {
+	return paginate(ctx, opts, func(ctx context.Context, page *PaginationOptions, emit func(Category) bool) (bool, error) {
+		list, err := service.GetCategories(ctx, page)
+		if err != nil {
+			return false, err
+		}
+
+		emitAll(list.Items, emit)
+		return list.HasMore, nil
+	})
+}
+
+// Reports iterates over every page of reports in the category
+func Reports(ctx context.Context, service ReportService, category Category, opts PageOptions) Seq2[Report, error] {
+	return paginate(ctx, opts, func(ctx context.Context, page *PaginationOptions, emit func(Report) bool) (bool, error) {
+		list, err := service.GetReports(ctx, category, page)
+		if err != nil {
+			return false, err
+		}
+
+		emitAll(list.Items, emit)
+		return list.HasMore, nil
+	})
+}
+
+// ReportRows iterates over the rows of every page of report data, each page is
+// streamed so only the row being yielded is held in memory. Report data is rate
+// limited to a few requests every 5 minutes so MaxPages is usually set
+func ReportRows(ctx context.Context, service ReportService, req ReportDataRequest, opts PageOptions) Seq2[ReportRow, error] {
+	return paginate(ctx, opts, func(ctx context.Context, page *PaginationOptions, emit func(ReportRow) bool) (bool, error) {
+		data, err := service.StreamReportData(ctx, req, page, func(fields []ReportField, row []interface{}) error {
+			if !emit(ReportRow{Fields: fields, Values: row}) {
+				return ErrStopRows
+			}
+
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+
+		return data.HasMore, nil
+	})
+}
+
+// Collect returns every item of the iterator, or the first error
+func Collect[T any](seq Seq2[T, error]) ([]T, error) {
+	items := []T{}
+
+	var err error
+	seq(func(item T, itemErr error) bool {
+		if itemErr != nil {
+			err = itemErr
+			return false
+		}
+
+		items = append(items, item)
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// pageFunc fetches a page calling emit with each item until emit returns
+// false, it returns whether there are more pages after this one
+type pageFunc[T any] func(ctx context.Context, page *PaginationOptions, emit func(T) bool) (bool, error)
+
+// paginate yields the items of each page until there are no more pages, the
+// context is done or the max pages are fetched. An error is yielded once as
+// the last pair, and a rate limited page is fetched again after waiting
+func paginate[T any](ctx context.Context, opts PageOptions, fetch pageFunc[T]) Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		page := &PaginationOptions{Page: 1, PageSize: opts.PageSize}
+		if page.PageSize <= 0 {
+			page.PageSize = defaultPageSize
+		}
+
+		for ; opts.MaxPages <= 0 || page.Page <= opts.MaxPages; page.Page++ {
+			stopped := false
+			emit := func(item T) bool {
+				stopped = !yield(item, nil)
+				return !stopped
+			}
+
+			hasMore, err := fetchPage(ctx, page, fetch, emit)
+			if stopped {
+				return
+			}
+
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			if !hasMore {
+				return
+			}
+		}
+	}
+}
+
+// fetchPage fetches the page and fetches it again when rate limited, a rate limited
+// response is rejected before any items so no item is emitted twice
+func fetchPage[T any](ctx context.Context, page *PaginationOptions, fetch pageFunc[T], emit func(T) bool) (bool, error) {
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		hasMore, err := fetch(ctx, page, emit)
+
+		wait, limited := rateLimitWait(err)
+		if !limited || attempt == maxRateLimitRetries {
+			return hasMore, err
+		}
+
+		trace.SpanFromContext(ctx).AddEvent("rate limited, waiting to fetch the page again", trace.WithAttributes(
+			attribute.Int("servicetitan.page", page.Page),
+			attribute.String("servicetitan.retry_after", wait.String()),
+		))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// rateLimitWait returns how long to wait when the error is a rate limited response
+func rateLimitWait(err error) (time.Duration, bool) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if apiErr.RetryAfter != nil {
+		return *apiErr.RetryAfter, true
+	}
+
+	return defaultRetryAfter, true
+}
+
+// emitAll emits each item until emit returns false
+func emitAll[T any](items []T, emit func(T) bool) {
+	for _, item := range items {
+		if !emit(item) {
+			return
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package servicetitan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCategories(t *testing.T) {
+	// pagedServer responds with pages of one category and more pages until the last page
+	pagedServer := func(t *testing.T, lastPage int, pages *[]string) reportService {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			*pages = append(*pages, r.URL.Query().Get("page")+"/"+r.URL.Query().Get("pageSize"))
+
+			fmt.Fprintf(w, `{"data": [{"id": "cat-%d", "name": "Category %d"}], "page": %d, "hasMore": %t}`, page, page, page, page < lastPage)
+		})
+		t.Cleanup(server.Close)
+
+		return reportService{baseURL: server.URL, client: buildClient()}
+	}
+
+	t.Run("returns the categories of every page", func(t *testing.T) {
+		pages := []string{}
+		srv := pagedServer(t, 3, &pages)
+
+		got, err := Collect(Categories(context.Background(), srv, PageOptions{PageSize: 200}))
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, got, []Category{
+			{ID: "cat-1", Name: "Category 1"},
+			{ID: "cat-2", Name: "Category 2"},
+			{ID: "cat-3", Name: "Category 3"},
+		})
+		assert.DeepEqual(t, pages, []string{"1/200", "2/200", "3/200"})
+	})
+
+	t.Run("uses the default page size", func(t *testing.T) {
+		pages := []string{}
+		srv := pagedServer(t, 1, &pages)
+
+		_, err := Collect(Categories(context.Background(), srv, PageOptions{}))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, pages, []string{"1/50"})
+	})
+
+	t.Run("stops at the max pages", func(t *testing.T) {
+		pages := []string{}
+		srv := pagedServer(t, 5, &pages)
+
+		got, err := Collect(Categories(context.Background(), srv, PageOptions{MaxPages: 2}))
+		assert.NilError(t, err)
+		assert.Equal(t, len(got), 2)
+		assert.DeepEqual(t, pages, []string{"1/50", "2/50"})
+	})
+
+	t.Run("doesn't fetch more pages once yield returns false", func(t *testing.T) {
+		pages := []string{}
+		srv := pagedServer(t, 5, &pages)
+
+		got := []Category{}
+		Categories(context.Background(), srv, PageOptions{})(func(c Category, err error) bool {
+			assert.NilError(t, err)
+			got = append(got, c)
+			return false
+		})
+
+		assert.DeepEqual(t, got, []Category{{ID: "cat-1", Name: "Category 1"}})
+		assert.DeepEqual(t, pages, []string{"1/50"})
+	})
+
+	t.Run("returns error when the context is done", func(t *testing.T) {
+		pages := []string{}
+		srv := pagedServer(t, 5, &pages)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		got := []Category{}
+
+		var err error
+		Categories(ctx, srv, PageOptions{})(func(c Category, itemErr error) bool {
+			if itemErr != nil {
+				err = itemErr
+				return false
+			}
+
+			got = append(got, c)
+			cancel()
+			return true
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, len(got), 1)
+		assert.DeepEqual(t, pages, []string{"1/50"})
+	})
+
+	t.Run("fetches a rate limited page again", func(t *testing.T) {
+		calls := 0
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				io.WriteString(w, "too many requests")
+				return
+			}
+
+			io.WriteString(w, `{"data": [{"id": "cat-a", "name": "Category A"}], "hasMore": false}`)
+		})
+		defer server.Close()
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		got, err := Collect(Categories(context.Background(), srv, PageOptions{}))
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, got, []Category{{ID: "cat-a", Name: "Category A"}})
+		assert.Equal(t, calls, 2)
+	})
+
+	t.Run("returns the rate limit error once the retries are used up", func(t *testing.T) {
+		calls := 0
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, "too many requests")
+		})
+		defer server.Close()
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		_, err := Collect(Categories(context.Background(), srv, PageOptions{}))
+
+		var apiErr *Error
+		assert.Assert(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr.StatusCode, http.StatusTooManyRequests)
+		assert.Equal(t, calls, maxRateLimitRetries+1)
+	})
+
+	t.Run("returns error when a page fails", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, "error invalid token")
+		})
+		defer server.Close()
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		_, err := Collect(Categories(context.Background(), srv, PageOptions{}))
+		assert.ErrorContains(t, err, "error invalid token")
+	})
+}
+
+func TestReports(t *testing.T) {
+	t.Run("returns the reports of every page for the category", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, r.URL.Path, "/report-category/cat-a/reports")
+
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			fmt.Fprintf(w, `{"data": [{"id": %d, "name": "Report %d"}], "hasMore": %t}`, page, page, page < 2)
+		})
+		defer server.Close()
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		got, err := Collect(Reports(context.Background(), srv, Category{ID: "cat-a"}, PageOptions{}))
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, got, []Report{{ID: 1, Name: "Report 1"}, {ID: 2, Name: "Report 2"}})
+	})
+}
+
+func TestReportRows(t *testing.T) {
+	fields := []ReportField{{Name: "Name", Type: "String"}}
+
+	server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		fmt.Fprintf(w, `{"fields": [{"name": "Name", "dataType": "String"}], "data": [["Row %d.1"], ["Row %d.2"]], "hasMore": %t}`, page, page, page < 3)
+	})
+	defer server.Close()
+
+	srv := reportService{baseURL: server.URL, client: buildClient()}
+
+	t.Run("returns the rows of every page", func(t *testing.T) {
+		got, err := Collect(ReportRows(context.Background(), srv, ReportDataRequest{}, PageOptions{PageSize: 2}))
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(got), 6)
+		assert.DeepEqual(t, got[0], ReportRow{Fields: fields, Values: []interface{}{"Row 1.1"}})
+		assert.DeepEqual(t, got[5], ReportRow{Fields: fields, Values: []interface{}{"Row 3.2"}})
+	})
+
+	t.Run("stops reading the page once yield returns false", func(t *testing.T) {
+		got := []ReportRow{}
+		ReportRows(context.Background(), srv, ReportDataRequest{}, PageOptions{})(func(row ReportRow, err error) bool {
+			assert.NilError(t, err)
+			got = append(got, row)
+			return len(got) < 3
+		})
+
+		assert.Equal(t, len(got), 3)
+		assert.DeepEqual(t, got[2].Values, []interface{}{"Row 2.1"})
+	})
+}
@@ -0,0 +1,45 @@
+package servicetitan
+
+import (
+	"servicetitan-to-dataset/config"
+	"sync"
+)
+
+// Pool keeps a long lived client for each connection, so every entry
+// for the same tenant shares the session rather than fetching a token
+type Pool struct {
+	mu         sync.Mutex
+	clients    map[string]*Client
+	tokenCache TokenCache
+}
+
+// NewPool returns a pool whose clients use the token cache,
+// the cache is optional and can be nil
+func NewPool(cache TokenCache) *Pool {
+	return &Pool{
+		clients:    map[string]*Client{},
+		tokenCache: cache,
+	}
+}
+
+// Client returns the client for the connection, a new client is
+// created when the connection settings have changed since the last call
+func (p *Pool) Client(cfg config.ServiceTitan) (*Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := cfg.Label()
+	if c, ok := p.clients[key]; ok && c.config == cfg {
+		return c, nil
+	}
+
+	c, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c.tokenCache = p.tokenCache
+	p.clients[key] = c
+
+	return c, nil
+}
@@ -0,0 +1,62 @@
+package servicetitan
+
+import (
+	"servicetitan-to-dataset/config"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPool_Client(t *testing.T) {
+	t.Run("returns the same client for the connection", func(t *testing.T) {
+		pool := NewPool(nil)
+		cfg := config.ServiceTitan{Name: "franchise-a", TenantID: "tenant_123"}
+
+		a, err := pool.Client(cfg)
+		assert.NilError(t, err)
+		b, err := pool.Client(cfg)
+		assert.NilError(t, err)
+
+		assert.Assert(t, a == b)
+	})
+
+	t.Run("returns a client for each connection", func(t *testing.T) {
+		pool := NewPool(nil)
+
+		a, err := pool.Client(config.ServiceTitan{Name: "franchise-a", TenantID: "tenant_123"})
+		assert.NilError(t, err)
+		b, err := pool.Client(config.ServiceTitan{Name: "franchise-b", TenantID: "tenant_123"})
+		assert.NilError(t, err)
+
+		assert.Assert(t, a != b)
+	})
+
+	t.Run("returns a new client when the connection has changed", func(t *testing.T) {
+		pool := NewPool(nil)
+		cfg := config.ServiceTitan{TenantID: "tenant_123", ClientSecret: "secret_123"}
+
+		a, err := pool.Client(cfg)
+		assert.NilError(t, err)
+
+		cfg.ClientSecret = "secret_456"
+		b, err := pool.Client(cfg)
+		assert.NilError(t, err)
+
+		assert.Assert(t, a != b)
+		assert.Equal(t, b.config.ClientSecret, "secret_456")
+	})
+
+	t.Run("clients use the token cache", func(t *testing.T) {
+		cache, err := NewFileTokenCache(t.TempDir())
+		assert.NilError(t, err)
+
+		c, err := NewPool(cache).Client(config.ServiceTitan{TenantID: "tenant_123"})
+		assert.NilError(t, err)
+		assert.Equal(t, c.tokenCache, TokenCache(cache))
+	})
+
+	t.Run("returns error when the client can't be created", func(t *testing.T) {
+		_, err := NewPool(nil).Client(config.ServiceTitan{TenantID: "tenant_123", ProxyURL: "://invalid"})
+		assert.ErrorContains(t, err, "invalid proxy_url")
+	})
+}
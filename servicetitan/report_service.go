@@ -4,16 +4,27 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"servicetitan-to-dataset/tracing"
 	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("servicetitan")
+
 type ReportService interface {
 	GetCategories(context.Context, *PaginationOptions) (*CategoryList, error)
 	GetReports(context.Context, Category, *PaginationOptions) (*ReportList, error)
 	GetReport(_ context.Context, categoryID, reportID string) (*Report, error)
 	GetReportData(context.Context, ReportDataRequest, *PaginationOptions) (*ReportData, error)
+	// StreamReportData calls the func with each row as it's read from the response rather
+	// than holding every row in memory, the returned report data has no rows
+	StreamReportData(context.Context, ReportDataRequest, *PaginationOptions, RowFunc) (*ReportData, error)
 }
 
 type reportService struct {
@@ -21,7 +32,10 @@ type reportService struct {
 	client  *Client
 }
 
-func (r reportService) GetCategories(ctx context.Context, options *PaginationOptions) (*CategoryList, error) {
+func (r reportService) GetCategories(ctx context.Context, options *PaginationOptions) (_ *CategoryList, err error) {
+	ctx, span := tracer.Start(ctx, "servicetitan.GetCategories")
+	defer func() { tracing.End(span, err) }()
+
 	url := r.client.buildURL(r.baseURL, "/report-categories", r.buildParams(options))
 	req, err := r.client.buildGETRequest(url)
 	if err != nil {
@@ -36,7 +50,12 @@ func (r reportService) GetCategories(ctx context.Context, options *PaginationOpt
 	return list, nil
 }
 
-func (r reportService) GetReports(ctx context.Context, category Category, options *PaginationOptions) (*ReportList, error) {
+func (r reportService) GetReports(ctx context.Context, category Category, options *PaginationOptions) (_ *ReportList, err error) {
+	ctx, span := tracer.Start(ctx, "servicetitan.GetReports", trace.WithAttributes(
+		attribute.String("servicetitan.category_id", category.ID),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	path := fmt.Sprintf("/report-category/%s/reports", category.ID)
 	url := r.client.buildURL(r.baseURL, path, r.buildParams(options))
 	req, err := r.client.buildGETRequest(url)
@@ -52,7 +71,13 @@ func (r reportService) GetReports(ctx context.Context, category Category, option
 	return list, nil
 }
 
-func (r reportService) GetReport(ctx context.Context, categoryID, reportID string) (*Report, error) {
+func (r reportService) GetReport(ctx context.Context, categoryID, reportID string) (_ *Report, err error) {
+	ctx, span := tracer.Start(ctx, "servicetitan.GetReport", trace.WithAttributes(
+		attribute.String("servicetitan.category_id", categoryID),
+		attribute.String("servicetitan.report_id", reportID),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	path := fmt.Sprintf("/report-category/%s/reports/%s", categoryID, reportID)
 	url := r.client.buildURL(r.baseURL, path, nil)
 
@@ -69,26 +94,73 @@ func (r reportService) GetReport(ctx context.Context, categoryID, reportID strin
 	return report, nil
 }
 
-func (r reportService) GetReportData(ctx context.Context, opts ReportDataRequest, pagination *PaginationOptions) (*ReportData, error) {
-	path := fmt.Sprintf("/report-category/%s/reports/%s/data", opts.CategoryID, opts.ReportID)
-	url := r.client.buildURL(r.baseURL, path, r.buildParams(pagination))
+func (r reportService) GetReportData(ctx context.Context, opts ReportDataRequest, pagination *PaginationOptions) (_ *ReportData, err error) {
+	ctx, span := r.startReportDataSpan(ctx, "servicetitan.GetReportData", opts, pagination)
+	defer func() { tracing.End(span, err) }()
 
-	b, err := json.Marshal(opts)
+	req, err := r.buildReportDataRequest(opts, pagination)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := r.client.buildPOSTRequest(url, bytes.NewReader(b))
+	data := &ReportData{}
+	if err := r.client.doRequest(req.WithContext(ctx), data); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.Int("servicetitan.rows", len(data.Data)),
+		attribute.Int("servicetitan.total_rows", data.Total),
+		attribute.Bool("servicetitan.has_more", data.HasMore),
+	)
+
+	return data, nil
+}
+
+func (r reportService) StreamReportData(ctx context.Context, opts ReportDataRequest, pagination *PaginationOptions, fn RowFunc) (_ *ReportData, err error) {
+	ctx, span := r.startReportDataSpan(ctx, "servicetitan.StreamReportData", opts, pagination)
+	defer func() { tracing.End(span, err) }()
+
+	req, err := r.buildReportDataRequest(opts, pagination)
 	if err != nil {
 		return nil, err
 	}
 
-	data := &ReportData{}
-	if err := r.client.doRequest(req.WithContext(ctx), data); err != nil {
+	stream := &reportDataStream{info: &ReportData{}, fn: fn}
+	if err := r.client.doRequest(req.WithContext(ctx), stream); err != nil && !errors.Is(err, ErrStopRows) {
 		return nil, err
 	}
 
-	return data, nil
+	span.SetAttributes(
+		attribute.Int("servicetitan.rows", stream.rows),
+		attribute.Int("servicetitan.total_rows", stream.info.Total),
+		attribute.Bool("servicetitan.has_more", stream.info.HasMore),
+	)
+
+	return stream.info, nil
+}
+
+func (r reportService) startReportDataSpan(ctx context.Context, name string, opts ReportDataRequest, pagination *PaginationOptions) (context.Context, trace.Span) {
+	params := r.buildParams(pagination)
+
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("servicetitan.category_id", opts.CategoryID),
+		attribute.String("servicetitan.report_id", opts.ReportID),
+		attribute.String("servicetitan.page", params.Get("page")),
+		attribute.String("servicetitan.page_size", params.Get("pageSize")),
+	))
+}
+
+func (r reportService) buildReportDataRequest(opts ReportDataRequest, pagination *PaginationOptions) (*http.Request, error) {
+	path := fmt.Sprintf("/report-category/%s/reports/%s/data", opts.CategoryID, opts.ReportID)
+	url := r.client.buildURL(r.baseURL, path, r.buildParams(pagination))
+
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.client.buildPOSTRequest(url, bytes.NewReader(b))
 }
 
 func (r reportService) buildParams(options *PaginationOptions) url.Values {
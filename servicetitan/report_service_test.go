@@ -86,7 +86,7 @@ func TestReportService_GetCategories(t *testing.T) {
 
 	t.Run("returns error when non 200 response code", func(t *testing.T) {
 		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusUnauthorized)
+			w.WriteHeader(http.StatusForbidden)
 			io.WriteString(w, "error invalid token")
 		})
 		defer server.Close()
@@ -95,7 +95,7 @@ func TestReportService_GetCategories(t *testing.T) {
 		_, err := srv.GetCategories(context.Background(), nil)
 
 		want := &Error{
-			StatusCode:  http.StatusUnauthorized,
+			StatusCode:  http.StatusForbidden,
 			RequestPath: "/report-categories",
 			Message:     "error invalid token",
 		}
@@ -189,7 +189,7 @@ func TestReportService_GetReports(t *testing.T) {
 
 	t.Run("returns error when non 200 response code", func(t *testing.T) {
 		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusUnauthorized)
+			w.WriteHeader(http.StatusForbidden)
 			io.WriteString(w, "error invalid token")
 		})
 		defer server.Close()
@@ -198,7 +198,7 @@ func TestReportService_GetReports(t *testing.T) {
 		_, err := srv.GetReports(context.Background(), Category{ID: "cat-b"}, nil)
 
 		want := &Error{
-			StatusCode:  http.StatusUnauthorized,
+			StatusCode:  http.StatusForbidden,
 			RequestPath: "/report-category/cat-b/reports",
 			Message:     "error invalid token",
 		}
@@ -285,7 +285,7 @@ func TestReportService_GetReport(t *testing.T) {
 
 	t.Run("returns error when non 200 response code", func(t *testing.T) {
 		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusUnauthorized)
+			w.WriteHeader(http.StatusForbidden)
 			io.WriteString(w, "error invalid token")
 		})
 		defer server.Close()
@@ -294,7 +294,7 @@ func TestReportService_GetReport(t *testing.T) {
 		_, err := srv.GetReport(context.Background(), "cat-b", "rpt-1")
 
 		want := &Error{
-			StatusCode:  http.StatusUnauthorized,
+			StatusCode:  http.StatusForbidden,
 			RequestPath: "/report-category/cat-b/reports/rpt-1",
 			Message:     "error invalid token",
 		}
@@ -466,7 +466,7 @@ func TestReportService_GetReportData(t *testing.T) {
 
 	t.Run("returns error when non 200 response code", func(t *testing.T) {
 		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusUnauthorized)
+			w.WriteHeader(http.StatusForbidden)
 			io.WriteString(w, "error invalid token")
 		})
 		defer server.Close()
@@ -481,7 +481,7 @@ func TestReportService_GetReportData(t *testing.T) {
 		)
 
 		want := &Error{
-			StatusCode:  http.StatusUnauthorized,
+			StatusCode:  http.StatusForbidden,
 			RequestPath: "/report-category/cat-b/reports/rpt-1/data",
 			Message:     "error invalid token",
 		}
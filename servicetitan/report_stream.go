@@ -0,0 +1,142 @@
+package servicetitan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RowFunc is called with each row of the report data along with the
+// fields of the row values, returning an error stops reading the rows
+type RowFunc func(fields []ReportField, row []interface{}) error
+
+// ErrStopRows can be returned by a RowFunc to stop reading the
+// rest of the rows without StreamReportData returning an error
+var ErrStopRows = errors.New("stop reading the report data rows")
+
+// reportDataStream decodes the report data response a token at a time,
+// so only the row being read is held in memory rather than every row
+type reportDataStream struct {
+	info *ReportData
+	fn   RowFunc
+	rows int
+
+	// pending are rows read before the fields, which ServiceTitan
+	// sends first but the rows need the fields to be understood
+	pending     [][]interface{}
+	fieldsKnown bool
+}
+
+func (s *reportDataStream) decode(r io.Reader) error {
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		key, _ := tok.(string)
+
+		// Keys are matched like encoding/json which ignores the case
+		switch strings.ToLower(key) {
+		case "data":
+			err = s.decodeRows(dec)
+		case "fields":
+			if err = dec.Decode(&s.info.Fields); err == nil {
+				s.fieldsKnown = true
+				err = s.flushPending()
+			}
+		case "hasmore":
+			err = dec.Decode(&s.info.HasMore)
+		case "page":
+			err = dec.Decode(&s.info.Page)
+		case "pagesize":
+			err = dec.Decode(&s.info.PageSize)
+		case "totalcount":
+			err = dec.Decode(&s.info.Total)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+
+	// The response had rows but no fields
+	s.fieldsKnown = true
+	return s.flushPending()
+}
+
+func (s *reportDataStream) decodeRows(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil || tok == nil {
+		return err
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("report data rows are %v but expected a list", tok)
+	}
+
+	for dec.More() {
+		var row []interface{}
+		if err := dec.Decode(&row); err != nil {
+			return fmt.Errorf("report data row %d: %w", s.rows+len(s.pending)+1, err)
+		}
+
+		if !s.fieldsKnown {
+			s.pending = append(s.pending, row)
+			continue
+		}
+
+		if err := s.emit(row); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, ']')
+}
+
+func (s *reportDataStream) flushPending() error {
+	for len(s.pending) > 0 {
+		row := s.pending[0]
+		s.pending = s.pending[1:]
+
+		if err := s.emit(row); err != nil {
+			return err
+		}
+	}
+
+	s.pending = nil
+	return nil
+}
+
+func (s *reportDataStream) emit(row []interface{}) error {
+	s.rows++
+	return s.fn(s.info.Fields, row)
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("unexpected %v in report data, expected %v", tok, want)
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package servicetitan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReportService_StreamReportData(t *testing.T) {
+	fields := []ReportField{
+		{Name: "Name", Label: "Name", Type: "String"},
+		{Name: "Jobs", Label: "Completed Jobs", Type: "Number"},
+	}
+
+	collect := func(rows *[][]interface{}) RowFunc {
+		return func(got []ReportField, row []interface{}) error {
+			assert.DeepEqual(t, got, fields)
+			*rows = append(*rows, row)
+			return nil
+		}
+	}
+
+	t.Run("calls the func with each row and returns the report data without rows", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, r.Method, http.MethodPost)
+			assert.Equal(t, r.URL.Path, "/report-category/cat-a/reports/rpt-1/data")
+			assert.Equal(t, r.URL.Query().Get("page"), "2")
+			assert.Equal(t, r.URL.Query().Get("pageSize"), "500")
+			assert.Equal(t, r.Header.Get("Authorization"), "tok_1230")
+
+			io.WriteString(w, `{
+				"fields": [{"name": "Name", "label": "Name", "dataType": "String"}, {"name": "Jobs", "label": "Completed Jobs", "dataType": "Number"}],
+				"page": 2, "pageSize": 500, "hasMore": true, "totalCount": 502,
+				"data": [["John Smith", 5], ["Jane Doe", 9]],
+				"unknown": {"key": ["value"]}
+			}`)
+		})
+		defer server.Close()
+
+		rows := [][]interface{}{}
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		got, err := srv.StreamReportData(context.Background(),
+			ReportDataRequest{CategoryID: "cat-a", ReportID: "rpt-1"},
+			&PaginationOptions{Page: 2, PageSize: 500},
+			collect(&rows),
+		)
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, got, &ReportData{Fields: fields, Page: 2, PageSize: 500, HasMore: true, Total: 502})
+		assert.DeepEqual(t, rows, [][]interface{}{{"John Smith", 5.0}, {"Jane Doe", 9.0}})
+	})
+
+	t.Run("calls the func with rows read before the fields", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{
+				"data": [["John Smith", 5]],
+				"fields": [{"name": "Name", "label": "Name", "dataType": "String"}, {"name": "Jobs", "label": "Completed Jobs", "dataType": "Number"}]
+			}`)
+		})
+		defer server.Close()
+
+		rows := [][]interface{}{}
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		_, err := srv.StreamReportData(context.Background(), ReportDataRequest{}, nil, collect(&rows))
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, rows, [][]interface{}{{"John Smith", 5.0}})
+	})
+
+	t.Run("returns no rows when the data is null", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"data": null, "hasMore": false}`)
+		})
+		defer server.Close()
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		_, err := srv.StreamReportData(context.Background(), ReportDataRequest{}, nil, func([]ReportField, []interface{}) error {
+			return errors.New("not expected to be called")
+		})
+		assert.NilError(t, err)
+	})
+
+	t.Run("stops reading the rows without an error", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"fields": [], "data": [["John Smith"], ["Jane Doe"], ["Hilary"]]}`)
+		})
+		defer server.Close()
+
+		calls := 0
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		_, err := srv.StreamReportData(context.Background(), ReportDataRequest{}, nil, func([]ReportField, []interface{}) error {
+			calls++
+			if calls == 2 {
+				return ErrStopRows
+			}
+
+			return nil
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, calls, 2)
+	})
+
+	t.Run("returns the error of the func", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"fields": [], "data": [["John Smith"], ["Jane Doe"]]}`)
+		})
+		defer server.Close()
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		_, err := srv.StreamReportData(context.Background(), ReportDataRequest{}, nil, func([]ReportField, []interface{}) error {
+			return errors.New("push failed")
+		})
+		assert.Error(t, err, "push failed")
+	})
+
+	t.Run("returns error when a row isn't a list of values", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"fields": [], "data": [["John Smith"], {"name": "Jane Doe"}]}`)
+		})
+		defer server.Close()
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		_, err := srv.StreamReportData(context.Background(), ReportDataRequest{}, nil, func([]ReportField, []interface{}) error {
+			return nil
+		})
+		assert.ErrorContains(t, err, "report data row 2: json: cannot unmarshal object")
+	})
+
+	t.Run("returns error when non 200 response code", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, "error invalid token")
+		})
+		defer server.Close()
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		_, err := srv.StreamReportData(context.Background(), ReportDataRequest{CategoryID: "cat-b", ReportID: "rpt-1"}, nil, nil)
+
+		want := &Error{
+			StatusCode:  http.StatusForbidden,
+			RequestPath: "/report-category/cat-b/reports/rpt-1/data",
+			Message:     "error invalid token",
+		}
+		assert.DeepEqual(t, err, want)
+	})
+
+	t.Run("returns error when it fails parse json body", func(t *testing.T) {
+		server := buildMockServer(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "invalid json")
+		})
+		defer server.Close()
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		_, err := srv.StreamReportData(context.Background(), ReportDataRequest{}, nil, nil)
+		assert.ErrorContains(t, err, "invalid character 'i'")
+	})
+}
+
+// BenchmarkReportService_ReportData compares reading the report data in one go against
+// streaming it, the peak-heap metric is the most memory in use while reading the rows
+// which grows with the rows for GetReportData but stays flat for StreamReportData
+func BenchmarkReportService_ReportData(b *testing.B) {
+	for _, rows := range []int{1000, 10000, 100000} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeReportData(w, rows)
+		}))
+
+		srv := reportService{baseURL: server.URL, client: buildClient()}
+		req := ReportDataRequest{CategoryID: "cat-a", ReportID: "rpt-1"}
+
+		b.Run(fmt.Sprintf("GetReportData/rows=%d", rows), func(b *testing.B) {
+			b.ReportAllocs()
+			peak := newHeapPeak()
+
+			for i := 0; i < b.N; i++ {
+				data, err := srv.GetReportData(context.Background(), req, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				peak.sample()
+				runtime.KeepAlive(data)
+			}
+
+			peak.report(b)
+		})
+
+		b.Run(fmt.Sprintf("StreamReportData/rows=%d", rows), func(b *testing.B) {
+			b.ReportAllocs()
+			peak := newHeapPeak()
+
+			for i := 0; i < b.N; i++ {
+				read := 0
+				_, err := srv.StreamReportData(context.Background(), req, nil, func([]ReportField, []interface{}) error {
+					if read++; read%1000 == 0 {
+						peak.sample()
+					}
+
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			peak.report(b)
+		})
+
+		server.Close()
+	}
+}
+
+func writeReportData(w io.Writer, rows int) {
+	io.WriteString(w, `{"fields": [{"name": "Name", "dataType": "String"}, {"name": "Jobs", "dataType": "Number"}, {"name": "Completed on", "dataType": "Date"}], "data": [`)
+
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+
+		fmt.Fprintf(w, `["Technician %d %s", %d, "2021-10-13"]`, i, strings.Repeat("x", 32), i)
+	}
+
+	io.WriteString(w, `], "page": 1, "pageSize": 5000, "hasMore": false}`)
+}
+
+// heapPeak keeps the most heap memory in use above the memory in use when created
+type heapPeak struct {
+	base uint64
+	max  uint64
+}
+
+func newHeapPeak() *heapPeak {
+	runtime.GC()
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return &heapPeak{base: stats.HeapAlloc}
+}
+
+func (h *heapPeak) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	if stats.HeapAlloc > h.base && stats.HeapAlloc-h.base > h.max {
+		h.max = stats.HeapAlloc - h.base
+	}
+}
+
+func (h *heapPeak) report(b *testing.B) {
+	b.ReportMetric(float64(h.max), "peak-heap-bytes")
+}
@@ -0,0 +1,217 @@
+// Package servicetitantest provides a fake ServiceTitan API for
+// testing code using the servicetitan package
+package servicetitantest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/sandbox"
+	"servicetitan-to-dataset/servicetitan"
+	"testing"
+	"time"
+)
+
+// Server is a fake ServiceTitan API serving the categories and reports
+// added to it, it records the report data requests it receives. It's
+// the sandbox ServiceTitan API with fixtures built by the test
+type Server struct {
+	*httptest.Server
+
+	sandbox *sandbox.Sandbox
+	config  config.ServiceTitan
+}
+
+// Category is a report category on the server
+type Category struct {
+	server *Server
+	id     string
+}
+
+// Report is a report on the server, its rows are paged by the
+// page and page size of each report data request
+type Report struct {
+	server   *Server
+	category string
+	id       int
+}
+
+// DataRequest is a report data request received by the server
+type DataRequest = sandbox.DataRequest
+
+// NewServer starts a server which is closed when the test finishes
+func NewServer(tb testing.TB) *Server {
+	s := &Server{}
+
+	s.config = config.ServiceTitan{
+		AppID:        "test-app",
+		TenantID:     "test-tenant",
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+	}
+
+	s.sandbox = sandbox.New(sandbox.Fixtures{
+		AppID:        s.config.AppID,
+		TenantIDs:    []string{s.config.TenantID},
+		ClientID:     s.config.ClientID,
+		ClientSecret: s.config.ClientSecret,
+	}, nil)
+
+	s.Server = httptest.NewServer(s.sandbox.ServiceTitan())
+	tb.Cleanup(s.Close)
+
+	s.config.AuthURL, s.config.APIURL = s.URL, s.URL
+	return s
+}
+
+// Config returns the connection config for the server, only
+// the client id and secret in the config are accepted
+func (s *Server) Config() config.ServiceTitan {
+	return s.config
+}
+
+// Client returns a servicetitan client for the server
+func (s *Server) Client(tb testing.TB) *servicetitan.Client {
+	tb.Helper()
+
+	c, err := servicetitan.New(s.Config())
+	if err != nil {
+		tb.Fatalf("servicetitantest: unable to create client: %v", err)
+	}
+
+	return c
+}
+
+// AddCategory adds a report category to the server
+func (s *Server) AddCategory(id, name string) *Category {
+	s.sandbox.Update(func(f *sandbox.Fixtures) {
+		f.Categories = append(f.Categories, sandbox.Category{ID: id, Name: name})
+	})
+
+	return &Category{server: s, id: id}
+}
+
+// AddReport adds a report to the category
+func (c *Category) AddReport(id int, name string) *Report {
+	c.server.sandbox.Update(func(f *sandbox.Fixtures) {
+		for idx := range f.Categories {
+			if f.Categories[idx].ID == c.id {
+				f.Categories[idx].Reports = append(f.Categories[idx].Reports, sandbox.Report{ID: id, Name: name})
+			}
+		}
+	})
+
+	return &Report{server: c.server, category: c.id, id: id}
+}
+
+// WithField adds a field of the data type, such as String or Number, to the report
+func (r *Report) WithField(name, dataType string) *Report {
+	r.update(func(report *sandbox.Report) {
+		report.Fields = append(report.Fields, sandbox.Field{Name: name, Label: name, Type: dataType})
+	})
+
+	return r
+}
+
+// WithParameter adds a parameter to the report, report data
+// requests without the required parameters are rejected
+func (r *Report) WithParameter(name, dataType string, required bool) *Report {
+	r.update(func(report *sandbox.Report) {
+		report.Parameters = append(report.Parameters, sandbox.Parameter{
+			Name:     name,
+			Label:    name,
+			Type:     dataType,
+			Required: required,
+		})
+	})
+
+	return r
+}
+
+// WithRows adds rows of data to the report, each row
+// has a value for every field of the report in order
+func (r *Report) WithRows(rows ...[]interface{}) *Report {
+	r.update(func(report *sandbox.Report) {
+		report.Rows = append(report.Rows, rows...)
+	})
+
+	return r
+}
+
+func (r *Report) update(fn func(*sandbox.Report)) {
+	r.server.sandbox.Update(func(f *sandbox.Fixtures) {
+		for cidx := range f.Categories {
+			if f.Categories[cidx].ID != r.category {
+				continue
+			}
+
+			reports := f.Categories[cidx].Reports
+			for idx := range reports {
+				if reports[idx].ID == r.id {
+					fn(&reports[idx])
+				}
+			}
+		}
+	})
+}
+
+// SetTokenExpiresIn sets the seconds the tokens issued from now are valid for
+func (s *Server) SetTokenExpiresIn(seconds int) {
+	s.sandbox.Update(func(f *sandbox.Fixtures) {
+		f.TokenExpiresIn = seconds
+	})
+}
+
+// ExpireTokens rejects every token issued so far, as if ServiceTitan revoked
+// them, so the next request is responded to with 401 Unauthorized
+func (s *Server) ExpireTokens() {
+	s.sandbox.RevokeTokens()
+}
+
+// TokenRequests returns how many tokens have been issued
+func (s *Server) TokenRequests() int {
+	return s.sandbox.TokenRequests()
+}
+
+// RateLimitNext responds to the next n reporting requests
+// with 429 Too Many Requests and the Retry-After header
+func (s *Server) RateLimitNext(n int, retryAfter time.Duration) {
+	s.sandbox.FailNext(n, http.StatusTooManyRequests, retryAfter)
+}
+
+// FailNext responds to the next n reporting requests with the status, such as 503
+func (s *Server) FailNext(n, status int) {
+	s.sandbox.FailNext(n, status, 0)
+}
+
+// FailPath responds to the next n requests matching the path.Match pattern
+// with the status, such as /reporting/v2/tenant/*/report-category/operations/reports
+func (s *Server) FailPath(pattern string, n, status int) {
+	s.sandbox.Update(func(f *sandbox.Fixtures) {
+		f.Faults = append(f.Faults, sandbox.Fault{Path: pattern, Status: status, Count: n})
+	})
+}
+
+// DataRequests returns the report data requests received in order
+func (s *Server) DataRequests() []DataRequest {
+	return s.sandbox.DataRequests()
+}
+
+// AssertDataRequestParameters fails the test unless the last report data
+// request had the parameters, values are compared as decoded from json so
+// numbers are float64
+func (s *Server) AssertDataRequestParameters(tb testing.TB, want ...servicetitan.DataRequestParamters) {
+	tb.Helper()
+
+	requests := s.DataRequests()
+	if len(requests) == 0 {
+		tb.Errorf("servicetitantest: no report data requests received")
+		return
+	}
+
+	got := requests[len(requests)-1].Request.Parameters
+	if (len(want) > 0 || len(got) > 0) && !reflect.DeepEqual(want, got) {
+		tb.Errorf("servicetitantest: report data request parameters mismatch\nwant: %+v\ngot:  %+v", want, got)
+	}
+}
@@ -0,0 +1,216 @@
+package servicetitantest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"servicetitan-to-dataset/servicetitan"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func newTechnicianServer(t *testing.T) *Server {
+	s := NewServer(t)
+	s.AddCategory("operations", "Operations").
+		AddReport(1001, "Technician performance").
+		WithField("Name", "String").
+		WithField("CompletedJobs", "Number").
+		WithParameter("From", "Date", true).
+		WithParameter("To", "Date", false).
+		WithRows(
+			[]interface{}{"John Smith", 12},
+			[]interface{}{"Jane Doe", 9},
+			[]interface{}{"Sam Lee", 4},
+		)
+
+	s.AddCategory("marketing", "Marketing")
+	return s
+}
+
+var technicianRequest = servicetitan.ReportDataRequest{
+	CategoryID: "operations",
+	ReportID:   "1001",
+	Parameters: []servicetitan.DataRequestParamters{{Name: "From", Value: "2022-06-01"}},
+}
+
+func TestServer_Reports(t *testing.T) {
+	t.Run("returns the categories and reports", func(t *testing.T) {
+		s := newTechnicianServer(t)
+		client := s.Client(t)
+
+		categories, err := client.ReportService.GetCategories(context.Background(), nil)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, categories.Items, []servicetitan.Category{{ID: "operations", Name: "Operations"}, {ID: "marketing", Name: "Marketing"}})
+
+		reports, err := client.ReportService.GetReports(context.Background(), servicetitan.Category{ID: "operations"}, nil)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, reports.Items, []servicetitan.Report{{ID: 1001, Name: "Technician performance"}})
+
+		report, err := client.ReportService.GetReport(context.Background(), "operations", "1001")
+		assert.NilError(t, err)
+		assert.Equal(t, len(report.Fields), 2)
+		assert.DeepEqual(t, report.Parameters[0], servicetitan.ReportParameter{Name: "From", Label: "From", DataType: "Date", IsRequired: true})
+	})
+
+	t.Run("returns the categories in pages", func(t *testing.T) {
+		s := newTechnicianServer(t)
+
+		categories, err := s.Client(t).ReportService.GetCategories(context.Background(), &servicetitan.PaginationOptions{Page: 1, PageSize: 1})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, categories.Items, []servicetitan.Category{{ID: "operations", Name: "Operations"}})
+		assert.Assert(t, categories.HasMore)
+		assert.Equal(t, categories.Total, 2)
+	})
+
+	t.Run("returns the report data in pages", func(t *testing.T) {
+		s := newTechnicianServer(t)
+		client := s.Client(t)
+
+		data, err := client.ReportService.GetReportData(context.Background(), technicianRequest, &servicetitan.PaginationOptions{Page: 2, PageSize: 2})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, data.Data, []interface{}{[]interface{}{"Sam Lee", float64(4)}})
+		assert.Equal(t, data.Page, 2)
+		assert.Equal(t, data.Total, 3)
+		assert.Assert(t, !data.HasMore)
+
+		requests := s.DataRequests()
+		assert.Equal(t, len(requests), 1)
+		assert.DeepEqual(t, requests[0].Request, technicianRequest)
+		assert.DeepEqual(t, requests[0].Pagination, servicetitan.PaginationOptions{Page: 2, PageSize: 2})
+
+		s.AssertDataRequestParameters(t, servicetitan.DataRequestParamters{Name: "From", Value: "2022-06-01"})
+	})
+
+	t.Run("fails the test when the parameters don't match", func(t *testing.T) {
+		s := newTechnicianServer(t)
+
+		_, err := s.Client(t).ReportService.GetReportData(context.Background(), technicianRequest, nil)
+		assert.NilError(t, err)
+
+		tb := &recordingTB{TB: t}
+		s.AssertDataRequestParameters(tb, servicetitan.DataRequestParamters{Name: "From", Value: "2022-07-01"})
+		assert.Equal(t, len(tb.errors), 1)
+		assert.Assert(t, strings.Contains(tb.errors[0], "want: [{Name:From Value:2022-07-01}]"), tb.errors[0])
+	})
+
+	t.Run("returns error when a required parameter is missing", func(t *testing.T) {
+		s := newTechnicianServer(t)
+
+		_, err := s.Client(t).ReportService.GetReportData(context.Background(), servicetitan.ReportDataRequest{CategoryID: "operations", ReportID: "1001"}, nil)
+		assert.ErrorContains(t, err, "The From parameter is required")
+	})
+
+	t.Run("returns not found for an unknown report", func(t *testing.T) {
+		s := newTechnicianServer(t)
+
+		_, err := s.Client(t).ReportService.GetReport(context.Background(), "marketing", "1001")
+
+		var stErr *servicetitan.Error
+		assert.Assert(t, errors.As(err, &stErr))
+		assert.Equal(t, stErr.StatusCode, http.StatusNotFound)
+	})
+}
+
+func TestServer_Failures(t *testing.T) {
+	t.Run("rejects expired tokens", func(t *testing.T) {
+		s := newTechnicianServer(t)
+		client := s.Client(t)
+
+		_, err := client.ReportService.GetCategories(context.Background(), nil)
+		assert.NilError(t, err)
+
+		s.ExpireTokens()
+
+		_, err = client.ReportService.GetCategories(context.Background(), nil)
+		assert.NilError(t, err)
+		assert.Equal(t, s.TokenRequests(), 2)
+	})
+
+	t.Run("returns authentication error when the credentials are rejected", func(t *testing.T) {
+		s := newTechnicianServer(t)
+
+		conn := s.Config()
+		conn.ClientSecret = "other-secret"
+
+		client, err := servicetitan.New(conn)
+		assert.NilError(t, err)
+
+		_, err = client.ReportService.GetCategories(context.Background(), nil)
+		assert.ErrorIs(t, err, servicetitan.ErrAuthentication)
+		assert.ErrorContains(t, err, "invalid_client")
+	})
+
+	t.Run("returns not found for another tenant", func(t *testing.T) {
+		s := newTechnicianServer(t)
+
+		conn := s.Config()
+		conn.TenantID = "other-tenant"
+
+		client, err := servicetitan.New(conn)
+		assert.NilError(t, err)
+
+		_, err = client.ReportService.GetCategories(context.Background(), nil)
+
+		var stErr *servicetitan.Error
+		assert.Assert(t, errors.As(err, &stErr))
+		assert.Equal(t, stErr.StatusCode, http.StatusNotFound)
+	})
+
+	t.Run("responds with too many requests", func(t *testing.T) {
+		s := newTechnicianServer(t)
+		s.RateLimitNext(1, 30*time.Second)
+		client := s.Client(t)
+
+		_, err := client.ReportService.GetReportData(context.Background(), technicianRequest, nil)
+
+		var stErr *servicetitan.Error
+		assert.Assert(t, errors.As(err, &stErr))
+		assert.Equal(t, stErr.StatusCode, http.StatusTooManyRequests)
+
+		_, err = client.ReportService.GetReportData(context.Background(), technicianRequest, nil)
+		assert.NilError(t, err)
+	})
+
+	t.Run("responds with the failure status", func(t *testing.T) {
+		s := newTechnicianServer(t)
+		s.FailNext(2, http.StatusServiceUnavailable)
+		client := s.Client(t)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.ReportService.GetCategories(context.Background(), nil)
+			assert.ErrorContains(t, err, "got response code 503")
+		}
+
+		_, err := client.ReportService.GetCategories(context.Background(), nil)
+		assert.NilError(t, err)
+	})
+
+	t.Run("responds with the failure status for the path", func(t *testing.T) {
+		s := newTechnicianServer(t)
+		s.FailPath("/reporting/v2/tenant/*/report-category/marketing/reports", 1, http.StatusBadRequest)
+		client := s.Client(t)
+
+		_, err := client.ReportService.GetReports(context.Background(), servicetitan.Category{ID: "operations"}, nil)
+		assert.NilError(t, err)
+
+		_, err = client.ReportService.GetReports(context.Background(), servicetitan.Category{ID: "marketing"}, nil)
+		assert.ErrorContains(t, err, "got response code 400")
+
+		_, err = client.ReportService.GetReports(context.Background(), servicetitan.Category{ID: "marketing"}, nil)
+		assert.NilError(t, err)
+	})
+}
+
+// recordingTB records the errors instead of failing the test
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,143 @@
+package servicetitan
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"servicetitan-to-dataset/config"
+	"time"
+)
+
+// TokenCache stores sessions between runs, so short lived runs
+// can reuse a token until it expires rather than fetching one
+type TokenCache interface {
+	// Load returns the cached session for the connection,
+	// a nil session is returned when there isn't one
+	Load(config.ServiceTitan) (*Session, error)
+	Save(config.ServiceTitan, *Session) error
+	Delete(config.ServiceTitan) error
+}
+
+// FileTokenCache stores a file for each tenant and client id in the
+// directory, encrypted with a key derived from the client secret
+type FileTokenCache struct {
+	dir string
+}
+
+type cachedSession struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewFileTokenCache returns a token cache using the directory,
+// which is created when it doesn't exist
+func NewFileTokenCache(dir string) (FileTokenCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return FileTokenCache{}, fmt.Errorf("token cache: %w", err)
+	}
+
+	return FileTokenCache{dir: dir}, nil
+}
+
+func (f FileTokenCache) Load(cfg config.ServiceTitan) (*Session, error) {
+	b, err := os.ReadFile(f.path(cfg))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := f.cipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < gcm.NonceSize() {
+		return nil, errors.New("token cache file is invalid")
+	}
+
+	// Fails when the client secret has changed since the token was cached
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("token cache file can't be decrypted: %w", err)
+	}
+
+	cached := cachedSession{}
+	if err := json.Unmarshal(plain, &cached); err != nil {
+		return nil, err
+	}
+
+	return &Session{Token: cached.Token, ExpiresAt: cached.ExpiresAt}, nil
+}
+
+func (f FileTokenCache) Save(cfg config.ServiceTitan, session *Session) error {
+	plain, err := json.Marshal(cachedSession{Token: session.Token, ExpiresAt: session.ExpiresAt})
+	if err != nil {
+		return err
+	}
+
+	gcm, err := f.cipher(cfg)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".token-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(gcm.Seal(nonce, nonce, plain, nil)); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// Renamed so a run never reads a partially written file
+	return os.Rename(tmp.Name(), f.path(cfg))
+}
+
+func (f FileTokenCache) Delete(cfg config.ServiceTitan) error {
+	err := os.Remove(f.path(cfg))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// path is named by a hash of the tenant and client id so
+// neither are visible in the name of the file
+func (f FileTokenCache) path(cfg config.ServiceTitan) string {
+	sum := sha256.Sum256([]byte(cfg.TenantID + "\x00" + cfg.ClientID))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:16])+".token")
+}
+
+func (f FileTokenCache) cipher(cfg config.ServiceTitan) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("servicetitan-token-cache\x00" + cfg.ClientSecret))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
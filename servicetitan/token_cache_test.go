@@ -0,0 +1,105 @@
+package servicetitan
+
+import (
+	"os"
+	"path/filepath"
+	"servicetitan-to-dataset/config"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFileTokenCache(t *testing.T) {
+	cfg := config.ServiceTitan{TenantID: "tenant_123", ClientID: "cid_123", ClientSecret: "secret_123"}
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("creates the directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "tokens")
+
+		_, err := NewFileTokenCache(dir)
+		assert.NilError(t, err)
+
+		info, err := os.Stat(dir)
+		assert.NilError(t, err)
+		assert.Equal(t, info.Mode().Perm(), os.FileMode(0o700))
+	})
+
+	t.Run("returns the saved session", func(t *testing.T) {
+		cache, err := NewFileTokenCache(t.TempDir())
+		assert.NilError(t, err)
+
+		assert.NilError(t, cache.Save(cfg, &Session{Token: "tok_1234", ExpireIn: 900, ExpiresAt: expiresAt}))
+
+		got, err := cache.Load(cfg)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, &Session{Token: "tok_1234", ExpiresAt: expiresAt})
+	})
+
+	t.Run("returns no session when nothing is cached", func(t *testing.T) {
+		cache, err := NewFileTokenCache(t.TempDir())
+		assert.NilError(t, err)
+
+		got, err := cache.Load(cfg)
+		assert.NilError(t, err)
+		assert.Assert(t, got == nil)
+	})
+
+	t.Run("keys the session by the tenant and client id", func(t *testing.T) {
+		cache, err := NewFileTokenCache(t.TempDir())
+		assert.NilError(t, err)
+
+		assert.NilError(t, cache.Save(cfg, &Session{Token: "tok_1234", ExpiresAt: expiresAt}))
+
+		other := cfg
+		other.TenantID = "tenant_456"
+
+		got, err := cache.Load(other)
+		assert.NilError(t, err)
+		assert.Assert(t, got == nil)
+	})
+
+	t.Run("encrypts the token", func(t *testing.T) {
+		dir := t.TempDir()
+		cache, err := NewFileTokenCache(dir)
+		assert.NilError(t, err)
+
+		assert.NilError(t, cache.Save(cfg, &Session{Token: "tok_1234", ExpiresAt: expiresAt}))
+
+		files, err := os.ReadDir(dir)
+		assert.NilError(t, err)
+		assert.Equal(t, len(files), 1)
+
+		b, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+		assert.NilError(t, err)
+		assert.Assert(t, !strings.Contains(string(b), "tok_1234"))
+		assert.Assert(t, !strings.Contains(files[0].Name(), cfg.TenantID))
+	})
+
+	t.Run("returns error when the client secret has changed", func(t *testing.T) {
+		cache, err := NewFileTokenCache(t.TempDir())
+		assert.NilError(t, err)
+
+		assert.NilError(t, cache.Save(cfg, &Session{Token: "tok_1234", ExpiresAt: expiresAt}))
+
+		rotated := cfg
+		rotated.ClientSecret = "secret_456"
+
+		_, err = cache.Load(rotated)
+		assert.ErrorContains(t, err, "token cache file can't be decrypted")
+	})
+
+	t.Run("deletes the session", func(t *testing.T) {
+		cache, err := NewFileTokenCache(t.TempDir())
+		assert.NilError(t, err)
+
+		assert.NilError(t, cache.Save(cfg, &Session{Token: "tok_1234", ExpiresAt: expiresAt}))
+		assert.NilError(t, cache.Delete(cfg))
+		assert.NilError(t, cache.Delete(cfg))
+
+		got, err := cache.Load(cfg)
+		assert.NilError(t, err)
+		assert.Assert(t, got == nil)
+	})
+}
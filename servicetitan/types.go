@@ -74,3 +74,19 @@ type ReportData struct {
 	PageSize int  `json:"pageSize"`
 	Total    int  `json:"totalCount"`
 }
+
+// ExportRequest fetches the records of an export such as jpm/jobs,
+// From is the continuation token of the previous export or empty
+// to fetch every record from the start
+type ExportRequest struct {
+	Resource             string
+	From                 string
+	IncludeRecentChanges bool
+}
+
+type ExportData struct {
+	Data []map[string]interface{} `json:"data"`
+
+	HasMore      bool   `json:"hasMore"`
+	ContinueFrom string `json:"continueFrom"`
+}
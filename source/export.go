@@ -0,0 +1,129 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/servicetitan"
+	"strings"
+)
+
+// Export fetches the records of a ServiceTitan export feed changed since
+// the continuation token in the state, the new token is saved on Commit
+type Export struct {
+	service servicetitan.ExportService
+	config  *config.Export
+	state   ExportState
+	key     string
+	maxRows int
+
+	continueFrom string
+	fetched      bool
+}
+
+// NewExport returns the source of the export, the key identifies the
+// continuation token in the state and at most max rows are fetched
+// so a large export is pushed over several runs
+func NewExport(service servicetitan.ExportService, cfg *config.Export, state ExportState, key string, maxRows int) *Export {
+	return &Export{service: service, config: cfg, state: state, key: key, maxRows: maxRows}
+}
+
+// Open returns the export fields and loads the saved continuation
+// token, the table is named after the export such as jobs
+func (e *Export) Open(_ context.Context) (*Table, error) {
+	from, err := e.state.Load(e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	e.continueFrom, e.fetched = from, false
+
+	_, name, _ := strings.Cut(e.config.Resource, "/")
+	return &Table{Name: name, Fields: sourceFields(e.config.Fields)}, nil
+}
+
+// Rows reads the records changed since the continuation token a page at a time,
+// the token is only kept for Commit when every page up to max rows is read
+func (e *Export) Rows(ctx context.Context, fn func([]interface{}) error) error {
+	from := e.continueFrom
+
+	for rows := 0; ; {
+		resp, err := e.service.GetExport(ctx, servicetitan.ExportRequest{
+			Resource:             e.config.Resource,
+			From:                 from,
+			IncludeRecentChanges: e.config.IncludeRecentChanges,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, record := range resp.Data {
+			row := make([]interface{}, len(e.config.Fields))
+			for idx, f := range e.config.Fields {
+				row[idx] = lookupValue(record, f.Name)
+			}
+
+			// The rest of the page isn't read so the token isn't kept
+			if err := fn(row); errors.Is(err, ErrStop) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			rows++
+		}
+
+		if resp.ContinueFrom != "" {
+			from = resp.ContinueFrom
+		}
+
+		if !resp.HasMore || rows >= e.maxRows {
+			break
+		}
+	}
+
+	e.continueFrom, e.fetched = from, true
+	return nil
+}
+
+// Commit saves the continuation token of the last rows read
+func (e *Export) Commit() error {
+	if !e.fetched {
+		return nil
+	}
+
+	return e.state.Save(e.key, e.continueFrom)
+}
+
+// sourceFields returns the fields from the config, the label defaults to the name
+func sourceFields(fields []config.SourceField) []servicetitan.ReportField {
+	out := []servicetitan.ReportField{}
+
+	for _, f := range fields {
+		label := f.Label
+		if label == "" {
+			label = f.Name
+		}
+
+		out = append(out, servicetitan.ReportField{Name: f.Name, Label: label, Type: f.Type})
+	}
+
+	return out
+}
+
+// lookupValue returns the value of the record field, nested fields are
+// joined with a dot and a missing field is nil which isn't pushed
+func lookupValue(record map[string]interface{}, name string) interface{} {
+	var value interface{} = record
+
+	for _, key := range strings.Split(name, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		value = obj[key]
+	}
+
+	return value
+}
@@ -0,0 +1,92 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ExportState keeps the continuation token of each export feed,
+// so each run only fetches the records changed since the last run
+type ExportState interface {
+	Load(key string) (string, error)
+	Save(key, token string) error
+}
+
+// FileExportState keeps each continuation token in a json file in the directory
+type FileExportState struct {
+	dir string
+}
+
+type exportStateFile struct {
+	Key          string    `json:"key"`
+	ContinueFrom string    `json:"continue_from"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// NewFileExportState returns the state kept in the directory, which
+// is created when it doesn't exist
+func NewFileExportState(dir string) (*FileExportState, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create state_dir: %w", err)
+	}
+
+	return &FileExportState{dir: dir}, nil
+}
+
+// Load returns the continuation token for the key, which
+// is empty when the export hasn't been fetched before
+func (f *FileExportState) Load(key string) (string, error) {
+	b, err := os.ReadFile(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	state := exportStateFile{}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return "", fmt.Errorf("export state file %s is invalid: %w", f.path(key), err)
+	}
+
+	return state.ContinueFrom, nil
+}
+
+// Save writes the continuation token for the key, the file is replaced
+// in one step so a failed write doesn't lose the previous token
+func (f *FileExportState) Save(key, token string) error {
+	b, err := json.MarshalIndent(exportStateFile{Key: key, ContinueFrom: token, UpdatedAt: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(key))
+}
+
+func (f *FileExportState) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:16])+".json")
+}
@@ -0,0 +1,51 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFileExportState(t *testing.T) {
+	t.Run("returns an empty token before the export is saved", func(t *testing.T) {
+		state, err := NewFileExportState(filepath.Join(t.TempDir(), "state"))
+		assert.NilError(t, err)
+
+		got, err := state.Load("ten_1||export||jpm/jobs")
+		assert.NilError(t, err)
+		assert.Equal(t, got, "")
+	})
+
+	t.Run("loads the saved token of each key", func(t *testing.T) {
+		dir := t.TempDir()
+		state, err := NewFileExportState(dir)
+		assert.NilError(t, err)
+
+		assert.NilError(t, state.Save("ten_1||export||jpm/jobs", "token-1"))
+		assert.NilError(t, state.Save("ten_1||export||crm/customers", "token-2"))
+		assert.NilError(t, state.Save("ten_1||export||jpm/jobs", "token-3"))
+
+		got, err := state.Load("ten_1||export||jpm/jobs")
+		assert.NilError(t, err)
+		assert.Equal(t, got, "token-3")
+
+		got, err = state.Load("ten_1||export||crm/customers")
+		assert.NilError(t, err)
+		assert.Equal(t, got, "token-2")
+
+		files, err := os.ReadDir(dir)
+		assert.NilError(t, err)
+		assert.Equal(t, len(files), 2)
+	})
+
+	t.Run("returns error when the state file is invalid", func(t *testing.T) {
+		state, err := NewFileExportState(t.TempDir())
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(state.path("key"), []byte("{"), 0o600))
+
+		_, err = state.Load("key")
+		assert.ErrorContains(t, err, "is invalid")
+	})
+}
@@ -0,0 +1,210 @@
+package source
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"servicetitan-to-dataset/config"
+	"strconv"
+	"strings"
+)
+
+// File reads the rows of a local csv or json file
+type File struct {
+	config config.File
+}
+
+// NewFile returns the source of the file, which is read each time the
+// rows are read so changes to the file are pushed on the next run
+func NewFile(cfg config.File) *File {
+	return &File{config: cfg}
+}
+
+// Open returns the file fields, the table is named
+// after the file name without the extension
+func (f *File) Open(_ context.Context) (*Table, error) {
+	base := filepath.Base(f.config.Path)
+
+	return &Table{
+		Name:   strings.TrimSuffix(base, filepath.Ext(base)),
+		Fields: sourceFields(f.config.Fields),
+	}, nil
+}
+
+// Rows reads a row for each csv line or json object,
+// the values are converted to the field types
+func (f *File) Rows(_ context.Context, fn func([]interface{}) error) error {
+	file, err := os.Open(f.config.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch format := f.config.FileFormat(); format {
+	case "csv":
+		err = f.readCSV(file, fn)
+	case "json":
+		err = f.readJSON(file, fn)
+	default:
+		err = fmt.Errorf("unsupported file format %q", format)
+	}
+
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("file %s %w", f.config.Path, err)
+	}
+
+	return nil
+}
+
+// readCSV reads the rows after the header row, the fields are
+// picked by the column names in the header
+func (f *File) readCSV(r io.Reader, fn func([]interface{}) error) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return errors.New("is empty, a header row is required")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	columns := make([]int, len(f.config.Fields))
+	for idx, field := range f.config.Fields {
+		columns[idx] = -1
+
+		for col, name := range header {
+			if strings.TrimSpace(name) == field.Name {
+				columns[idx] = col
+				break
+			}
+		}
+
+		if columns[idx] < 0 {
+			return fmt.Errorf("has no %q column", field.Name)
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		row := make([]interface{}, len(f.config.Fields))
+		for idx, field := range f.config.Fields {
+			if row[idx], err = convertValue(record[columns[idx]], field.Type); err != nil {
+				return fmt.Errorf("line %d column %q %w", line, field.Name, err)
+			}
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// readJSON reads an array of objects an object at a time, the fields
+// are picked by name and nested fields are joined with a dot
+func (f *File) readJSON(r io.Reader, fn func([]interface{}) error) error {
+	dec := json.NewDecoder(r)
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return errors.New("must be an array of objects")
+	}
+
+	for num := 1; dec.More(); num++ {
+		record := map[string]interface{}{}
+		if err := dec.Decode(&record); err != nil {
+			return fmt.Errorf("must be an array of objects: object %d %w", num, err)
+		}
+
+		row := make([]interface{}, len(f.config.Fields))
+		for idx, field := range f.config.Fields {
+			value, err := convertValue(lookupValue(record, field.Name), field.Type)
+			if err != nil {
+				return fmt.Errorf("object %d field %q %w", num, field.Name, err)
+			}
+
+			row[idx] = value
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("must be an array of objects: %w", err)
+	}
+
+	return nil
+}
+
+// convertValue returns the value as the field type, text from a csv file
+// is parsed as numbers and booleans. Empty values are nil which isn't pushed
+func convertValue(value interface{}, fieldType string) (interface{}, error) {
+	text, isText := value.(string)
+	if value == nil || (isText && strings.TrimSpace(text) == "") {
+		return nil, nil
+	}
+
+	switch fieldType {
+	case "Number", "Percentage":
+		if !isText {
+			if _, ok := value.(float64); !ok {
+				return nil, fmt.Errorf("value %v is not a number", value)
+			}
+
+			return value, nil
+		}
+
+		n, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not a number", text)
+		}
+
+		return n, nil
+	case "Boolean":
+		if !isText {
+			if _, ok := value.(bool); !ok {
+				return nil, fmt.Errorf("value %v is not a boolean", value)
+			}
+
+			return value, nil
+		}
+
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not a boolean", text)
+		}
+
+		return b, nil
+	}
+
+	if !isText {
+		switch value.(type) {
+		case float64, bool:
+			return fmt.Sprint(value), nil
+		}
+
+		return nil, fmt.Errorf("value %v is not a %s", value, strings.ToLower(fieldType))
+	}
+
+	return text, nil
+}
@@ -0,0 +1,107 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/servicetitan"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+var targetFields = []config.SourceField{
+	{Name: "Month", Type: "Date"},
+	{Name: "Target", Label: "Sales target", Type: "Number"},
+	{Name: "Stretch", Type: "Boolean"},
+}
+
+func TestFile_Fetch(t *testing.T) {
+	t.Run("returns the csv rows with the typed values", func(t *testing.T) {
+		path := writeFile(t, "targets.csv", "Region,Month,Target,Stretch\nNorth,2022-06-01,1500.5,true\nSouth,2022-06-01,,FALSE\n")
+
+		got, err := Fetch(context.Background(), NewFile(config.File{Path: path, Fields: targetFields}))
+		assert.NilError(t, err)
+
+		assert.DeepEqual(t, got, &Table{
+			Name: "targets",
+			Fields: []servicetitan.ReportField{
+				{Name: "Month", Label: "Month", Type: "Date"},
+				{Name: "Target", Label: "Sales target", Type: "Number"},
+				{Name: "Stretch", Label: "Stretch", Type: "Boolean"},
+			},
+			Rows: [][]interface{}{
+				{"2022-06-01", 1500.5, true},
+				{"2022-06-01", nil, false},
+			},
+		})
+	})
+
+	t.Run("returns the json rows with the typed values", func(t *testing.T) {
+		path := writeFile(t, "lookup", `[
+			{"Month": "2022-06-01", "Target": 1500, "Stretch": "yes", "Region": {"Name": "North"}},
+			{"Month": "2022-07-01", "Target": "2000", "Stretch": false}
+		]`)
+
+		fields := append([]config.SourceField{{Name: "Region.Name", Type: "String"}}, targetFields[:2]...)
+		fields = append(fields, config.SourceField{Name: "Stretch", Type: "String"})
+		got, err := Fetch(context.Background(), NewFile(config.File{Path: path, Format: "json", Fields: fields}))
+		assert.NilError(t, err)
+
+		assert.Equal(t, got.Name, "lookup")
+		assert.DeepEqual(t, got.Rows, [][]interface{}{
+			{"North", "2022-06-01", float64(1500), "yes"},
+			{nil, "2022-07-01", float64(2000), "false"},
+		})
+	})
+
+	t.Run("returns error with the object of an invalid value", func(t *testing.T) {
+		path := writeFile(t, "targets.json", `[{"Month": "2022-06-01", "Target": 1500, "Stretch": "yes"}]`)
+
+		_, err := Fetch(context.Background(), NewFile(config.File{Path: path, Fields: targetFields}))
+		assert.Error(t, err, "file "+path+` object 1 field "Stretch" value "yes" is not a boolean`)
+	})
+
+	t.Run("returns error when a csv column is missing", func(t *testing.T) {
+		path := writeFile(t, "targets.csv", "Month,Target\n2022-06-01,1500\n")
+
+		_, err := Fetch(context.Background(), NewFile(config.File{Path: path, Fields: targetFields}))
+		assert.Error(t, err, "file "+path+` has no "Stretch" column`)
+	})
+
+	t.Run("returns error with the line of an invalid value", func(t *testing.T) {
+		path := writeFile(t, "targets.csv", "Month,Target,Stretch\n2022-06-01,1500,true\n2022-07-01,lots,true\n")
+
+		_, err := Fetch(context.Background(), NewFile(config.File{Path: path, Fields: targetFields}))
+		assert.Error(t, err, "file "+path+` line 3 column "Target" value "lots" is not a number`)
+	})
+
+	t.Run("returns error when the csv file is empty", func(t *testing.T) {
+		path := writeFile(t, "targets.csv", "")
+
+		_, err := Fetch(context.Background(), NewFile(config.File{Path: path, Fields: targetFields}))
+		assert.ErrorContains(t, err, "is empty, a header row is required")
+	})
+
+	t.Run("returns error when the json isn't an array of objects", func(t *testing.T) {
+		path := writeFile(t, "targets.json", `{"Month": "2022-06-01"}`)
+
+		_, err := Fetch(context.Background(), NewFile(config.File{Path: path, Fields: targetFields}))
+		assert.ErrorContains(t, err, "must be an array of objects")
+	})
+
+	t.Run("returns error when the file doesn't exist", func(t *testing.T) {
+		_, err := Fetch(context.Background(), NewFile(config.File{Path: "does-not-exist.csv", Fields: targetFields}))
+		assert.ErrorContains(t, err, "no such file or directory")
+	})
+}
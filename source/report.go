@@ -0,0 +1,170 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"servicetitan-to-dataset/config"
+	"servicetitan-to-dataset/keyword"
+	"servicetitan-to-dataset/servicetitan"
+)
+
+// reportPageSize is the rows fetched from a report, only the first
+// page is fetched as report data is rate limited to a few calls every 5 minutes
+const reportPageSize = 5000
+
+// Report fetches the data of a ServiceTitan report
+type Report struct {
+	service  servicetitan.ReportService
+	config   config.Report
+	keywords *keyword.Handler
+
+	report *servicetitan.Report
+	params []servicetitan.DataRequestParamters
+}
+
+// NewReport returns the source of the report, the parameter values
+// can use keywords and variables which are replaced on each open
+func NewReport(service servicetitan.ReportService, cfg config.Report, keywords *keyword.Handler) *Report {
+	return &Report{service: service, config: cfg, keywords: keywords}
+}
+
+// Open returns the report fields and replaces the keywords in the parameters
+func (r *Report) Open(ctx context.Context) (*Table, error) {
+	report, err := r.service.GetReport(ctx, r.config.CategoryID, r.config.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := r.buildParameters(report)
+	if err != nil {
+		return nil, err
+	}
+
+	r.report, r.params = report, params
+	return &Table{Name: report.Name, Fields: report.Fields}, nil
+}
+
+// Rows streams the first page of the report data, each row is
+// decoded from the response as it's read
+func (r *Report) Rows(ctx context.Context, fn func([]interface{}) error) error {
+	if r.report == nil {
+		return errors.New("report source must be opened before reading the rows")
+	}
+
+	req := servicetitan.ReportDataRequest{
+		CategoryID: r.config.CategoryID,
+		ReportID:   r.config.ID,
+		Parameters: r.params,
+	}
+
+	mapper := &rowMapper{fields: r.report.Fields}
+	rows := servicetitan.ReportRows(ctx, r.service, req, servicetitan.PageOptions{PageSize: reportPageSize, MaxPages: 1})
+
+	var err error
+	rows(func(row servicetitan.ReportRow, rowErr error) bool {
+		if err = rowErr; err == nil {
+			err = fn(mapper.mapRow(row.Fields, row.Values))
+		}
+
+		return err == nil
+	})
+
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+
+	return err
+}
+
+// buildParameters returns the parameters from the config as servicetitan parameters.
+// This also supports special NOW and NOW-n keywords for date fields - which if
+// the fields are of type Date will be replaced with the current time and current time -n.
+// Variables from the config are replaced for any type of field
+func (r *Report) buildParameters(report *servicetitan.Report) ([]servicetitan.DataRequestParamters, error) {
+	params := []servicetitan.DataRequestParamters{}
+
+	for _, p := range r.config.Parameters {
+		param := lookupParameter(report, p.Name)
+
+		if param == nil {
+			return nil, fmt.Errorf("invalid param %q for report %v", p.Name, report.ID)
+		}
+
+		value := p.Value
+
+		val, _ := value.(string)
+		if param.DataType == "Date" {
+			if err := r.keywords.ValidateDateValue(val); err != nil {
+				return nil, fmt.Errorf("param %q for report %v %w", p.Name, report.ID, err)
+			}
+		}
+
+		if param.DataType == "Date" || r.keywords.IsVariable(val) {
+			r.keywords.SetValue(val)
+
+			if r.keywords.HasMatched() {
+				value = r.keywords.ComputedValue()
+			}
+		}
+
+		params = append(params, servicetitan.DataRequestParamters{Name: p.Name, Value: value})
+	}
+
+	return params, nil
+}
+
+func lookupParameter(report *servicetitan.Report, key string) *servicetitan.ReportParameter {
+	for _, p := range report.Parameters {
+		if p.Name == key {
+			return &p
+		}
+	}
+
+	return nil
+}
+
+// rowMapper returns the data rows with a value for each report field in order,
+// the data fields are usually the report fields in the same order but the rows
+// are mapped by name otherwise
+type rowMapper struct {
+	fields []servicetitan.ReportField
+
+	// index is the data field index of each report field, worked
+	// out from the first row as every row has the same data fields
+	index     []int
+	sameOrder bool
+}
+
+func (m *rowMapper) mapRow(dataFields []servicetitan.ReportField, row []interface{}) []interface{} {
+	if m.index == nil {
+		m.index = make([]int, len(m.fields))
+		m.sameOrder = len(m.fields) == len(dataFields)
+
+		for idx, f := range m.fields {
+			m.index[idx] = -1
+
+			for dataIdx, df := range dataFields {
+				if df.Name == f.Name {
+					m.index[idx] = dataIdx
+					break
+				}
+			}
+
+			m.sameOrder = m.sameOrder && m.index[idx] == idx
+		}
+	}
+
+	if m.sameOrder {
+		return row
+	}
+
+	mapped := make([]interface{}, len(m.fields))
+	for idx, dataIdx := range m.index {
+		if dataIdx >= 0 && dataIdx < len(row) {
+			mapped[idx] = row[dataIdx]
+		}
+	}
+
+	return mapped
+}
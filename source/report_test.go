@@ -0,0 +1,40 @@
+package source
+
+import (
+	"servicetitan-to-dataset/servicetitan"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRowMapper(t *testing.T) {
+	fields := []servicetitan.ReportField{
+		{Name: "Name", Type: "String"},
+		{Name: "Jobs", Type: "Number"},
+	}
+
+	t.Run("returns the rows in the same order", func(t *testing.T) {
+		mapper := &rowMapper{fields: fields}
+
+		got := mapper.mapRow(fields, []interface{}{"John Smith", 5})
+		assert.DeepEqual(t, got, []interface{}{"John Smith", 5})
+	})
+
+	t.Run("maps the rows to the report fields by name", func(t *testing.T) {
+		mapper := &rowMapper{fields: fields}
+		dataFields := []servicetitan.ReportField{{Name: "Jobs"}, {Name: "Active"}, {Name: "Name"}}
+
+		got := mapper.mapRow(dataFields, []interface{}{5, true, "John Smith"})
+		assert.DeepEqual(t, got, []interface{}{"John Smith", 5})
+
+		got = mapper.mapRow(dataFields, []interface{}{2, false, "Jane Doe"})
+		assert.DeepEqual(t, got, []interface{}{"Jane Doe", 2})
+	})
+
+	t.Run("returns nil for a missing data field", func(t *testing.T) {
+		mapper := &rowMapper{fields: fields}
+
+		got := mapper.mapRow([]servicetitan.ReportField{{Name: "Name"}}, []interface{}{"John Smith"})
+		assert.DeepEqual(t, got, []interface{}{"John Smith", nil})
+	})
+}
@@ -0,0 +1,75 @@
+// Package source fetches the rows pushed to a dataset, from a ServiceTitan
+// report or export feed or from a local csv or json file
+package source
+
+import (
+	"context"
+	"errors"
+	"servicetitan-to-dataset/servicetitan"
+)
+
+// Source fetches the fields and rows of a dataset, each field declares
+// its type so every source is built into a dataset the same way
+type Source interface {
+	// Open returns the table name and fields without any rows, so
+	// the dataset can be created before the rows are read
+	Open(context.Context) (*Table, error)
+	// Rows calls the func with each row as it's read, a row has a value for
+	// every field in order and isn't kept once the func returns. Open
+	// must be called first and an error from the func is returned
+	Rows(context.Context, func(row []interface{}) error) error
+}
+
+// ErrStop can be returned by the Rows func to stop reading
+// the rest of the rows, Rows then returns without an error
+var ErrStop = errors.New("stop reading the source rows")
+
+// Fetch opens the source and returns the table with every row, which
+// holds all the rows in memory so is only used for small sources
+func Fetch(ctx context.Context, src Source) (*Table, error) {
+	table, err := src.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	table.Rows = [][]interface{}{}
+	err = src.Rows(ctx, func(row []interface{}) error {
+		table.Rows = append(table.Rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// Committer is a source which keeps track of what it has fetched, Commit is
+// called once the rows are pushed so the next fetch continues from there
+type Committer interface {
+	Commit() error
+}
+
+// Table is the fields and rows fetched from a source, each row has a
+// value for every field in order and nil values aren't pushed
+type Table struct {
+	// Name is the dataset name used when the entry doesn't set one
+	Name   string
+	Fields []servicetitan.ReportField
+	Rows   [][]interface{}
+}
+
+// Report returns the table as a ServiceTitan report and its data,
+// which the dataset builder builds the schema and rows from
+func (t *Table) Report() (*servicetitan.Report, *servicetitan.ReportData) {
+	data := &servicetitan.ReportData{
+		Fields: t.Fields,
+		Data:   make([]interface{}, len(t.Rows)),
+	}
+
+	for idx, row := range t.Rows {
+		data.Data[idx] = row
+	}
+
+	return &servicetitan.Report{Name: t.Name, Fields: t.Fields}, data
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gotest.tools/v3/assert"
+)
+
+func TestSetup(t *testing.T) {
+	t.Run("returns a no-op shutdown when there isn't an exporter", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), "", "v1")
+		assert.NilError(t, err)
+		assert.NilError(t, shutdown(context.Background()))
+	})
+
+	t.Run("returns error when the exporter is unknown", func(t *testing.T) {
+		_, err := Setup(context.Background(), "zipkin", "v1")
+		assert.Error(t, err, `unknown trace exporter "zipkin", expected otlp or stdout`)
+	})
+}
+
+func TestEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	t.Run("ends the span", func(t *testing.T) {
+		_, span := tracer.Start(context.Background(), "ok")
+		End(span, nil)
+
+		got := recorder.Ended()[0]
+		assert.Equal(t, got.Status().Code, codes.Unset)
+		assert.Equal(t, len(got.Events()), 0)
+	})
+
+	t.Run("records the error", func(t *testing.T) {
+		_, span := tracer.Start(context.Background(), "failed")
+		End(span, errors.New("request failed"))
+
+		got := recorder.Ended()[1]
+		assert.Equal(t, got.Status().Code, codes.Error)
+		assert.Equal(t, got.Status().Description, "request failed")
+		assert.Equal(t, got.Events()[0].Name, "exception")
+	})
+}